@@ -34,7 +34,7 @@ func main() {
 
 	addr := fmt.Sprintf(":%s", cfg.ServerPort)
 	log.Printf("Server starting on http://localhost%s", addr)
-	if err := http.ListenAndServe(addr, c.Handler(r)); err != nil {
+	if err := http.ListenAndServe(addr, c.Handler(router.WithCompression(router.WithResponseCasing(r)))); err != nil {
 		log.Fatalf("Server failed: %v", err)
 	}
 }
@@ -1,12 +1,17 @@
 package datasource
 
 import (
+	"bufio"
+	"compress/gzip"
 	"encoding/csv"
+	"errors"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
 	"sort"
 	"strings"
+	"unicode/utf8"
 )
 
 // FolderData represents the combined data from all CSVs in a folder
@@ -16,12 +21,41 @@ type FolderData struct {
 	Files   []string // List of CSV files that were read
 }
 
+// Ways of combining the CSV files in a folder
+const (
+	CombineAppend = "append" // Stack rows; every file must have the same columns
+	CombineJoin   = "join"   // Merge rows that share a key column value across files
+)
+
+// RowLimitError reports that a folder holds more data rows than the reader allows
+type RowLimitError struct {
+	Limit    int
+	RowsRead int    // Rows read before the limit was exceeded
+	File     string // File being read when the limit was exceeded
+}
+
+func (e *RowLimitError) Error() string {
+	return fmt.Sprintf("dataset exceeds the maximum of %d rows (stopped after %d rows while reading %s)", e.Limit, e.RowsRead, e.File)
+}
+
+// ReadOptions controls how each CSV file in a folder is parsed
+type ReadOptions struct {
+	SkipRows  int  // Records before each file's header; negative detects title lines
+	Delimiter rune // Field delimiter; 0 uses tabs for .tsv files and detects it otherwise
+}
+
+// DetectOptions detects both the header position and the delimiter of each file
+var DetectOptions = ReadOptions{SkipRows: -1}
+
 // FolderReader reads and combines CSV files from a folder
-type FolderReader struct{}
+type FolderReader struct {
+	maxRows int // 0 means unlimited
+}
 
-// NewFolderReader creates a new folder reader
-func NewFolderReader() *FolderReader {
-	return &FolderReader{}
+// NewFolderReader creates a new folder reader that reads at most maxRows data
+// rows across all files; 0 means unlimited
+func NewFolderReader(maxRows int) *FolderReader {
+	return &FolderReader{maxRows: maxRows}
 }
 
 // ValidateFolder checks that the folder exists and contains valid CSV files
@@ -57,7 +91,8 @@ func (r *FolderReader) ValidateFolder(folderPath string) error {
 // ReadFolder reads all CSV files in a folder and returns combined data
 // Files are processed in alphabetical order for deterministic results
 // All CSVs must have the same columns in the same order
-func (r *FolderReader) ReadFolder(folderPath string) (*FolderData, error) {
+// Reading stops with a *RowLimitError once the row limit is exceeded
+func (r *FolderReader) ReadFolder(folderPath string, opts ReadOptions) (*FolderData, error) {
 	absPath, err := filepath.Abs(folderPath)
 	if err != nil {
 		return nil, fmt.Errorf("invalid folder path: %w", err)
@@ -76,7 +111,14 @@ func (r *FolderReader) ReadFolder(folderPath string) (*FolderData, error) {
 
 	for i, fileName := range csvFiles {
 		filePath := filepath.Join(absPath, fileName)
-		columns, rows, err := r.readCSVFile(filePath)
+		limit := -1
+		if r.maxRows > 0 {
+			limit = r.maxRows - len(result.Rows)
+		}
+		columns, rows, err := r.readCSVFile(filePath, opts, limit)
+		if err == errRowLimit {
+			return nil, &RowLimitError{Limit: r.maxRows, RowsRead: len(result.Rows) + len(rows), File: fileName}
+		}
 		if err != nil {
 			return nil, fmt.Errorf("failed to read %s: %w", fileName, err)
 		}
@@ -99,7 +141,140 @@ func (r *FolderReader) ReadFolder(folderPath string) (*FolderData, error) {
 	return &result, nil
 }
 
-// listCSVFiles returns a sorted list of CSV files in the folder
+// JoinFolder reads all CSV files in a folder and merges them on keyColumn, which
+// every file must have. The result has the union of all files' columns, in order
+// of first appearance, and one row per distinct key in order of first appearance.
+// Cells a row has no value for are empty; where files share a column, the later
+// file's non-empty value wins. A key may appear only once per file.
+// Reading stops with a *RowLimitError once the row limit is exceeded
+func (r *FolderReader) JoinFolder(folderPath, keyColumn string, opts ReadOptions) (*FolderData, error) {
+	absPath, err := filepath.Abs(folderPath)
+	if err != nil {
+		return nil, fmt.Errorf("invalid folder path: %w", err)
+	}
+
+	csvFiles, err := r.listCSVFiles(absPath)
+	if err != nil {
+		return nil, err
+	}
+	if len(csvFiles) == 0 {
+		return nil, fmt.Errorf("folder contains no CSV files: %s", absPath)
+	}
+
+	var result FolderData
+	columnIndex := make(map[string]int)
+	rowIndex := make(map[string]int)
+
+	for _, fileName := range csvFiles {
+		limit := -1
+		if r.maxRows > 0 {
+			limit = r.maxRows
+		}
+		columns, rows, err := r.readCSVFile(filepath.Join(absPath, fileName), opts, limit)
+		if err == errRowLimit {
+			return nil, &RowLimitError{Limit: r.maxRows, RowsRead: len(rows), File: fileName}
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", fileName, err)
+		}
+
+		keyPos := -1
+		for i, col := range columns {
+			if col == keyColumn {
+				keyPos = i
+				break
+			}
+		}
+		if keyPos < 0 {
+			return nil, fmt.Errorf("join key %q is missing from %s", keyColumn, fileName)
+		}
+
+		// Add this file's new columns, widening the rows merged so far
+		positions := make([]int, len(columns))
+		for i, col := range columns {
+			pos, ok := columnIndex[col]
+			if !ok {
+				pos = len(result.Columns)
+				columnIndex[col] = pos
+				result.Columns = append(result.Columns, col)
+				for j := range result.Rows {
+					result.Rows[j] = append(result.Rows[j], "")
+				}
+			}
+			positions[i] = pos
+		}
+
+		seen := make(map[string]bool)
+		for _, row := range rows {
+			key := fmt.Sprintf("%v", row[keyPos])
+			if seen[key] {
+				return nil, fmt.Errorf("duplicate join key %q in %s", key, fileName)
+			}
+			seen[key] = true
+
+			j, ok := rowIndex[key]
+			if !ok {
+				if r.maxRows > 0 && len(result.Rows) >= r.maxRows {
+					return nil, &RowLimitError{Limit: r.maxRows, RowsRead: len(result.Rows), File: fileName}
+				}
+				j = len(result.Rows)
+				rowIndex[key] = j
+				merged := make([]any, len(result.Columns))
+				for k := range merged {
+					merged[k] = ""
+				}
+				result.Rows = append(result.Rows, merged)
+			}
+			for i, val := range row {
+				if val != "" {
+					result.Rows[j][positions[i]] = val
+				}
+			}
+		}
+		result.Files = append(result.Files, fileName)
+	}
+
+	return &result, nil
+}
+
+// ReadColumns returns the columns ReadFolder (append mode) or JoinFolder (join mode)
+// would produce, reading only each file's header row
+func (r *FolderReader) ReadColumns(folderPath, combineMode string, opts ReadOptions) ([]string, error) {
+	absPath, err := filepath.Abs(folderPath)
+	if err != nil {
+		return nil, fmt.Errorf("invalid folder path: %w", err)
+	}
+
+	csvFiles, err := r.listCSVFiles(absPath)
+	if err != nil {
+		return nil, err
+	}
+	if len(csvFiles) == 0 {
+		return nil, fmt.Errorf("folder contains no CSV files: %s", absPath)
+	}
+
+	var result []string
+	seen := make(map[string]bool)
+	for _, fileName := range csvFiles {
+		columns, _, err := r.readCSVFile(filepath.Join(absPath, fileName), opts, 0)
+		if err != nil && err != errRowLimit {
+			return nil, fmt.Errorf("failed to read %s: %w", fileName, err)
+		}
+		// Appended files all share the first file's columns
+		if combineMode != CombineJoin {
+			return columns, nil
+		}
+		for _, col := range columns {
+			if !seen[col] {
+				seen[col] = true
+				result = append(result, col)
+			}
+		}
+	}
+	return result, nil
+}
+
+// listCSVFiles returns a sorted list of CSV and TSV files (plain or gzipped) in the folder
 func (r *FolderReader) listCSVFiles(folderPath string) ([]string, error) {
 	entries, err := os.ReadDir(folderPath)
 	if err != nil {
@@ -111,9 +286,10 @@ func (r *FolderReader) listCSVFiles(folderPath string) ([]string, error) {
 		if entry.IsDir() {
 			continue
 		}
-		name := entry.Name()
-		if strings.HasSuffix(strings.ToLower(name), ".csv") {
-			csvFiles = append(csvFiles, name)
+		name := strings.ToLower(entry.Name())
+		name = strings.TrimSuffix(name, ".gz")
+		if strings.HasSuffix(name, ".csv") || strings.HasSuffix(name, ".tsv") {
+			csvFiles = append(csvFiles, entry.Name())
 		}
 	}
 
@@ -122,40 +298,97 @@ func (r *FolderReader) listCSVFiles(folderPath string) ([]string, error) {
 	return csvFiles, nil
 }
 
+// errRowLimit is returned by readCSVFile when the file has more rows than allowed
+var errRowLimit = errors.New("row limit exceeded")
+
 // readCSVFile reads a single CSV file and returns columns and rows
-func (r *FolderReader) readCSVFile(filePath string) ([]string, [][]any, error) {
+// Files ending in .gz are decompressed transparently. Unless opts sets a delimiter,
+// .tsv files are tab-delimited and for other files it is detected from the first
+// lines (see sniffDelimiter). The first opts.SkipRows records are skipped; if it is
+// negative, title or metadata lines before the header are detected and skipped
+// (see headerIndex).
+// At most limit rows are read (negative means unlimited); if the file has more,
+// the rows read so far are returned with errRowLimit
+func (r *FolderReader) readCSVFile(filePath string, opts ReadOptions, limit int) ([]string, [][]any, error) {
 	file, err := os.Open(filePath)
 	if err != nil {
 		return nil, nil, fmt.Errorf("failed to open file: %w", err)
 	}
 	defer file.Close()
 
-	reader := csv.NewReader(file)
-	reader.FieldsPerRecord = -1 // Allow variable field counts (will catch in validation)
+	var source io.Reader = file
+	if strings.HasSuffix(strings.ToLower(filePath), ".gz") {
+		gzReader, err := gzip.NewReader(file)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to open gzip stream: %w", err)
+		}
+		defer gzReader.Close()
+		source = gzReader
+	}
 
-	// Read all records
-	records, err := reader.ReadAll()
-	if err != nil {
-		return nil, nil, fmt.Errorf("failed to parse CSV: %w", err)
+	buffered := bufio.NewReader(source)
+	delimiter := opts.Delimiter
+	if delimiter == 0 {
+		if strings.HasSuffix(strings.TrimSuffix(strings.ToLower(filePath), ".gz"), ".tsv") {
+			delimiter = '\t'
+		} else {
+			delimiter = sniffDelimiter(buffered)
+		}
 	}
 
-	if len(records) == 0 {
+	reader := csv.NewReader(buffered)
+	reader.Comma = delimiter
+	reader.FieldsPerRecord = -1 // Allow variable field counts (will catch in validation)
+
+	// Read ahead to find the header row; records after it are replayed below
+	var scanned [][]string
+	for len(scanned) < headerScanRecords {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to parse CSV: %w", err)
+		}
+		scanned = append(scanned, record)
+	}
+	if len(scanned) == 0 {
 		return nil, nil, fmt.Errorf("CSV file is empty")
 	}
-
-	// First row is headers
-	headers := records[0]
+	start := opts.SkipRows
+	if start < 0 {
+		start = headerIndex(scanned)
+	} else if start >= len(scanned) {
+		return nil, nil, fmt.Errorf("CSV file has no header after skipping %d rows", opts.SkipRows)
+	}
+	headers := scanned[start]
+	pending := scanned[start+1:]
 	if len(headers) == 0 {
 		return nil, nil, fmt.Errorf("CSV has no columns")
 	}
 
 	// Use columns in their original CSV order
-	columns := headers
+	columns := uniqueColumnNames(headers)
 
-	// Convert remaining rows to []any
+	// Convert remaining rows to []any, reading one record at a time so an
+	// oversized file is rejected without loading it entirely
 	var rows [][]any
-	for i := 1; i < len(records); i++ {
-		record := records[i]
+	for {
+		var record []string
+		if len(pending) > 0 {
+			record, pending = pending[0], pending[1:]
+		} else {
+			record, err = reader.Read()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				return nil, nil, fmt.Errorf("failed to parse CSV: %w", err)
+			}
+		}
+		if limit >= 0 && len(rows) >= limit {
+			return columns, rows, errRowLimit
+		}
 		row := make([]any, len(columns))
 		for j := range columns {
 			if j < len(record) {
@@ -170,6 +403,174 @@ func (r *FolderReader) readCSVFile(filePath string) ([]string, [][]any, error) {
 	return columns, rows, nil
 }
 
+// maxColumnNameLength is PostgreSQL's identifier limit in bytes. Longer names are
+// silently truncated by the database, so they are truncated here first to keep them
+// distinct.
+const maxColumnNameLength = 63
+
+// reservedColumnNames are used by dataset storage for its own columns
+var reservedColumnNames = map[string]bool{"row_index": true}
+
+// uniqueColumnNames makes header names usable as distinct table columns. Blank
+// headers become column_<n> (by position); repeated, reserved or over-long names
+// get a numeric suffix, e.g. a second "Amount" becomes "Amount_2". Other names are
+// returned unchanged.
+func uniqueColumnNames(headers []string) []string {
+	columns := make([]string, len(headers))
+	seen := make(map[string]bool)
+	for i, header := range headers {
+		name := header
+		if strings.TrimSpace(name) == "" {
+			name = fmt.Sprintf("column_%d", i+1)
+		}
+		name = truncateName(name, maxColumnNameLength)
+
+		candidate := name
+		for n := 2; seen[candidate] || reservedColumnNames[candidate]; n++ {
+			suffix := fmt.Sprintf("_%d", n)
+			candidate = truncateName(name, maxColumnNameLength-len(suffix)) + suffix
+		}
+		seen[candidate] = true
+		columns[i] = candidate
+	}
+	return columns
+}
+
+// truncateName cuts s to at most n bytes without splitting a character
+func truncateName(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return s[:n]
+}
+
+// headerScanRecords is how many leading records are examined to find the header
+const headerScanRecords = 20
+
+// MaxSkipRows is the most records a dataset can configure to skip above the header
+const MaxSkipRows = headerScanRecords - 1
+
+// headerIndex returns the index of the header among the leading records. Bank
+// exports often put a title such as "Account Statement" above the real header;
+// those lines have fewer fields and are skipped. A leading record is skipped only
+// if it has fewer fields than the most common field count and is either a single
+// field or has fewer fields than the record after it. Nothing is skipped when the
+// first record already has several fields, so a header naming fewer columns than
+// ragged data rows fill is kept. Blank lines are already dropped by csv.Reader.
+// Trailing empty fields are not counted, so rows ending in a delimiter don't
+// outnumber the header. Datasets override detection with skip_rows.
+func headerIndex(records [][]string) int {
+	if filledLength(records[0]) > 1 {
+		return 0
+	}
+	counts := make(map[int]int)
+	common := 0
+	for _, record := range records {
+		n := filledLength(record)
+		counts[n]++
+		if counts[n] > counts[common] || (counts[n] == counts[common] && n > common) {
+			common = n
+		}
+	}
+	for i := 0; i < len(records)-1; i++ {
+		n := filledLength(records[i])
+		if n >= common || (n > 1 && n >= filledLength(records[i+1])) {
+			return i
+		}
+	}
+	return 0
+}
+
+// filledLength is the number of fields up to the last non-empty one
+func filledLength(record []string) int {
+	n := len(record)
+	for n > 0 && strings.TrimSpace(record[n-1]) == "" {
+		n--
+	}
+	return n
+}
+
+// Delimiters a dataset can be configured with; the first three are also the ones
+// sniffDelimiter chooses from, in order of preference
+var delimiterCandidates = []rune{',', ';', '\t'}
+
+// IsDelimiter reports whether r is a supported field delimiter
+func IsDelimiter(r rune) bool {
+	return r == '|' || strings.ContainsRune(string(delimiterCandidates), r)
+}
+
+// sniffDelimiterLines is how many leading records sniffDelimiter examines
+const sniffDelimiterLines = 10
+
+// sniffDelimiter picks the delimiter of a file from its first records, without
+// consuming any input. Bank exports commonly use semicolons or tabs instead of
+// commas. A file whose sampled records all split into the same number (more than
+// one) of comma-separated fields is always read as comma-separated, so delimiters
+// inside quoted values never change an ordinary CSV file. Otherwise the candidate
+// that splits the most records into one consistent field count wins; title lines
+// above the header are the records left out of that count.
+func sniffDelimiter(r *bufio.Reader) rune {
+	// Peek returns what it can along with an error when the input is shorter
+	const peekSize = 64 * 1024
+	head, _ := r.Peek(peekSize)
+	if len(head) == peekSize {
+		// Drop the last, possibly cut off, line
+		if i := strings.LastIndexByte(string(head), '\n'); i >= 0 {
+			head = head[:i+1]
+		}
+	}
+
+	best, bestScore := delimiterCandidates[0], 0
+	for _, candidate := range delimiterCandidates {
+		counts := sampleFieldCounts(string(head), candidate)
+		score := consistentRecords(counts)
+		if candidate == ',' && score > 0 && score == len(counts) {
+			return ','
+		}
+		if score > bestScore {
+			best, bestScore = candidate, score
+		}
+	}
+	return best
+}
+
+// sampleFieldCounts is the field count of each of the first records of head when
+// split on delimiter, stopping at the first record that does not parse
+func sampleFieldCounts(head string, delimiter rune) []int {
+	reader := csv.NewReader(strings.NewReader(head))
+	reader.Comma = delimiter
+	reader.FieldsPerRecord = -1
+	var counts []int
+	for len(counts) < sniffDelimiterLines {
+		record, err := reader.Read()
+		if err != nil {
+			break
+		}
+		counts = append(counts, filledLength(record))
+	}
+	return counts
+}
+
+// consistentRecords is how many records share the most common field count, or 0 if
+// that count is one field (the delimiter splits nothing)
+func consistentRecords(counts []int) int {
+	freq := make(map[int]int)
+	common := 0
+	for _, n := range counts {
+		freq[n]++
+		if freq[n] > freq[common] || (freq[n] == freq[common] && n > common) {
+			common = n
+		}
+	}
+	if common <= 1 {
+		return 0
+	}
+	return freq[common]
+}
+
 // validateColumnsMatch checks that two column sets are identical in order
 func (r *FolderReader) validateColumnsMatch(expected, actual []string, fileName string) error {
 	if len(expected) != len(actual) {
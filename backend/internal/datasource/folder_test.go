@@ -0,0 +1,226 @@
+package datasource
+
+import (
+	"bytes"
+	"compress/gzip"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func writeFile(t *testing.T, dir, name string, data []byte) {
+	t.Helper()
+	if err := os.WriteFile(filepath.Join(dir, name), data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func gzipBytes(t *testing.T, data string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	gw := gzip.NewWriter(&buf)
+	if _, err := gw.Write([]byte(data)); err != nil {
+		t.Fatal(err)
+	}
+	if err := gw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestReadFolderMixesPlainAndGzippedCSV(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, dir, "a.csv", []byte("date,amount\n2024-01-01,10\n"))
+	writeFile(t, dir, "b.csv.gz", gzipBytes(t, "date,amount\n2024-02-01,20\n"))
+	writeFile(t, dir, "notes.txt", []byte("ignored"))
+
+	data, err := NewFolderReader(-1).ReadFolder(dir, DetectOptions)
+	if err != nil {
+		t.Fatalf("ReadFolder: %v", err)
+	}
+
+	if want := []string{"date", "amount"}; !reflect.DeepEqual(data.Columns, want) {
+		t.Errorf("columns = %v, want %v", data.Columns, want)
+	}
+	wantRows := [][]any{{"2024-01-01", "10"}, {"2024-02-01", "20"}}
+	if !reflect.DeepEqual(data.Rows, wantRows) {
+		t.Errorf("rows = %v, want %v", data.Rows, wantRows)
+	}
+	if want := []string{"a.csv", "b.csv.gz"}; !reflect.DeepEqual(data.Files, want) {
+		t.Errorf("files = %v, want %v", data.Files, want)
+	}
+}
+
+func TestReadFolderRejectsCorruptGzip(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, dir, "a.csv", []byte("date,amount\n2024-01-01,10\n"))
+	writeFile(t, dir, "b.csv.gz", []byte("this is not gzip data"))
+
+	_, err := NewFolderReader(-1).ReadFolder(dir, DetectOptions)
+	if err == nil {
+		t.Fatal("ReadFolder succeeded on a corrupt gzip file")
+	}
+	if !strings.Contains(err.Error(), "b.csv.gz") {
+		t.Errorf("error %q does not name the corrupt file", err)
+	}
+}
+
+func TestUniqueColumnNames(t *testing.T) {
+	long := strings.Repeat("x", 70)
+	x61, x63 := strings.Repeat("x", 61), strings.Repeat("x", 63)
+
+	tests := []struct {
+		name    string
+		headers []string
+		want    []string
+	}{
+		{"distinct names unchanged", []string{"Date", " Amount ", "amount"}, []string{"Date", " Amount ", "amount"}},
+		{"duplicates", []string{"Amount", "Amount", "Amount"}, []string{"Amount", "Amount_2", "Amount_3"}},
+		{"suffix already taken", []string{"Amount", "Amount_2", "Amount"}, []string{"Amount", "Amount_2", "Amount_3"}},
+		{"blank", []string{"Date", "", "  "}, []string{"Date", "column_2", "column_3"}},
+		{"reserved", []string{"row_index", "row_index"}, []string{"row_index_2", "row_index_3"}},
+		{"over-long names truncate to the same prefix", []string{long, long + "y"}, []string{x63, x61 + "_2"}},
+		{"truncated name plus suffix collides again", []string{long, long, x61 + "_2"}, []string{x63, x61 + "_2", x61 + "_3"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := uniqueColumnNames(tt.headers)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("uniqueColumnNames(%q) = %q, want %q", tt.headers, got, tt.want)
+			}
+			for _, col := range got {
+				if len(col) > maxColumnNameLength {
+					t.Errorf("column %q is longer than %d bytes", col, maxColumnNameLength)
+				}
+			}
+		})
+	}
+}
+
+func TestReadFolderKeepsHeaderAboveRaggedRows(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, dir, "a.csv", []byte("date,amount\n2024-01-01,5,x\n2024-01-02,6,y\n"))
+
+	data, err := NewFolderReader(-1).ReadFolder(dir, DetectOptions)
+	if err != nil {
+		t.Fatalf("ReadFolder: %v", err)
+	}
+	if want := []string{"date", "amount"}; !reflect.DeepEqual(data.Columns, want) {
+		t.Errorf("columns = %v, want %v", data.Columns, want)
+	}
+	if len(data.Rows) != 2 {
+		t.Errorf("got %d rows, want 2", len(data.Rows))
+	}
+}
+
+func TestHeaderIndex(t *testing.T) {
+	tests := []struct {
+		name    string
+		records [][]string
+		want    int
+	}{
+		{"header first", [][]string{{"date", "amount"}, {"2024-01-01", "5"}}, 0},
+		{"ragged rows", [][]string{{"date", "amount"}, {"2024-01-01", "5", "x"}, {"2024-01-02", "6", "y"}}, 0},
+		{"title lines", [][]string{{"Account Statement"}, {"Account: 123"}, {"date", "amount"}, {"2024-01-01", "5"}}, 2},
+		{"title then metadata", [][]string{{"Statement"}, {"From", "2024-01-01"}, {"date", "amount", "balance"}, {"2024-01-01", "5", "10"}}, 2},
+		{"single column", [][]string{{"amount"}, {"5"}, {"6"}}, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := headerIndex(tt.records); got != tt.want {
+				t.Errorf("headerIndex = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReadFolderSkipRows(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, dir, "a.csv", []byte("Statement\ndate,amount\n2024-01-01,10\n"))
+
+	detected, err := NewFolderReader(-1).ReadFolder(dir, DetectOptions)
+	if err != nil {
+		t.Fatalf("ReadFolder: %v", err)
+	}
+	if want := []string{"date", "amount"}; !reflect.DeepEqual(detected.Columns, want) {
+		t.Errorf("detected columns = %v, want %v", detected.Columns, want)
+	}
+
+	// Below a title, a header naming fewer columns than the data fills is taken for
+	// another title line; skip_rows corrects that
+	writeFile(t, dir, "a.csv", []byte("Statement\ndate,amount\n2024-01-01,10,note\n2024-01-02,20,note\n"))
+	data, err := NewFolderReader(-1).ReadFolder(dir, ReadOptions{SkipRows: 1})
+	if err != nil {
+		t.Fatalf("ReadFolder: %v", err)
+	}
+	if want := []string{"date", "amount"}; !reflect.DeepEqual(data.Columns, want) {
+		t.Errorf("columns = %v, want %v", data.Columns, want)
+	}
+
+	if _, err := NewFolderReader(-1).ReadFolder(dir, ReadOptions{SkipRows: 5}); err == nil {
+		t.Error("ReadFolder succeeded skipping past the end of the file")
+	}
+}
+
+func TestReadFolderDelimiters(t *testing.T) {
+	tests := []struct {
+		name     string
+		file     string
+		data     string
+		opts     ReadOptions
+		wantCols []string
+		wantRows [][]any
+	}{
+		{
+			name:     "semicolons inside quoted comma data",
+			file:     "a.csv",
+			data:     "id,note\n1,\"a; b; c; d\"\n2,plain\n",
+			opts:     DetectOptions,
+			wantCols: []string{"id", "note"},
+			wantRows: [][]any{{"1", "a; b; c; d"}, {"2", "plain"}},
+		},
+		{
+			name:     "semicolons with decimal commas",
+			file:     "a.csv",
+			data:     "date;amount;balance\n2024-01-01;-1,50;100,00\n2024-01-02;2,25;102,25\n",
+			opts:     DetectOptions,
+			wantCols: []string{"date", "amount", "balance"},
+			wantRows: [][]any{{"2024-01-01", "-1,50", "100,00"}, {"2024-01-02", "2,25", "102,25"}},
+		},
+		{
+			name:     "tsv",
+			file:     "a.tsv",
+			data:     "id\tnote\n1\ta,b;c\n",
+			opts:     DetectOptions,
+			wantCols: []string{"id", "note"},
+			wantRows: [][]any{{"1", "a,b;c"}},
+		},
+		{
+			name:     "configured delimiter overrides detection",
+			file:     "a.csv",
+			data:     "id|note\n1|a,b,c\n",
+			opts:     ReadOptions{SkipRows: -1, Delimiter: '|'},
+			wantCols: []string{"id", "note"},
+			wantRows: [][]any{{"1", "a,b,c"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			writeFile(t, dir, tt.file, []byte(tt.data))
+
+			data, err := NewFolderReader(-1).ReadFolder(dir, tt.opts)
+			if err != nil {
+				t.Fatalf("ReadFolder: %v", err)
+			}
+			if !reflect.DeepEqual(data.Columns, tt.wantCols) {
+				t.Errorf("columns = %q, want %q", data.Columns, tt.wantCols)
+			}
+			if !reflect.DeepEqual(data.Rows, tt.wantRows) {
+				t.Errorf("rows = %q, want %q", data.Rows, tt.wantRows)
+			}
+		})
+	}
+}
@@ -7,6 +7,7 @@ import (
 
 	"finance-tracker/internal/models"
 	"finance-tracker/internal/repository"
+	"finance-tracker/internal/validation"
 
 	"github.com/gorilla/mux"
 )
@@ -66,9 +67,18 @@ func (h *AccountGroupHandler) Create(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if err := validation.ValidateFormulaOperations(req.Formula); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
 	group, err := h.groupRepo.Create(&req)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		if repository.IsValidationError(err) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		} else {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
 		return
 	}
 
@@ -96,6 +106,11 @@ func (h *AccountGroupHandler) Update(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if err := validation.ValidateFormulaOperations(req.Formula); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
 	group, err := h.groupRepo.Update(id, &req)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
@@ -106,6 +121,173 @@ func (h *AccountGroupHandler) Update(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(group)
 }
 
+func (h *AccountGroupHandler) Duplicate(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		http.Error(w, "Invalid group ID", http.StatusBadRequest)
+		return
+	}
+
+	// Body is optional; by default memberships are not copied
+	var req models.DuplicateGroupRequest
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+	}
+
+	group, err := h.groupRepo.Duplicate(id, &req)
+	if err != nil {
+		if repository.IsValidationError(err) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		} else {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+	if group == nil {
+		http.Error(w, "Group not found", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(group)
+}
+
+// SetParent nests a group under another group or moves it back to the top level
+func (h *AccountGroupHandler) SetParent(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		http.Error(w, "Invalid group ID", http.StatusBadRequest)
+		return
+	}
+
+	var req models.SetGroupParentRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	group, err := h.groupRepo.SetParent(id, req.ParentGroupID)
+	if err != nil {
+		if repository.IsValidationError(err) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		} else {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+	if group == nil {
+		http.Error(w, "Group not found", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(group)
+}
+
+// SetTarget sets or clears a group's target allocation percentage
+func (h *AccountGroupHandler) SetTarget(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		http.Error(w, "Invalid group ID", http.StatusBadRequest)
+		return
+	}
+
+	var req models.SetGroupTargetRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	found, err := h.groupRepo.SetTargetPercentage(id, req.TargetPercentage)
+	if err != nil {
+		if repository.IsValidationError(err) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		} else {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+	if !found {
+		http.Error(w, "Group not found", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(models.GroupTargetResponse{GroupID: id, TargetPercentage: req.TargetPercentage})
+}
+
+// GetAllocationDrift compares each top-level group's current share with its target
+func (h *AccountGroupHandler) GetAllocationDrift(w http.ResponseWriter, r *http.Request) {
+	drift, err := h.groupRepo.GetAllocationDrift()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(drift)
+}
+
+// GetMovers ranks a group's member accounts by balance change between ?from= and
+// ?to= (YYYY-MM-DD or RFC3339). ?sort=percentage ranks by percentage change instead.
+func (h *AccountGroupHandler) GetMovers(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		http.Error(w, "Invalid group ID", http.StatusBadRequest)
+		return
+	}
+
+	query := r.URL.Query()
+	if query.Get("from") == "" || query.Get("to") == "" {
+		http.Error(w, "from and to are required", http.StatusBadRequest)
+		return
+	}
+	from, err := parseDate(query.Get("from"), true)
+	if err != nil {
+		http.Error(w, "Invalid from date. Use YYYY-MM-DD or RFC3339", http.StatusBadRequest)
+		return
+	}
+	to, err := parseDate(query.Get("to"), true)
+	if err != nil {
+		http.Error(w, "Invalid to date. Use YYYY-MM-DD or RFC3339", http.StatusBadRequest)
+		return
+	}
+	if from.After(to) {
+		http.Error(w, "from must not be after to", http.StatusBadRequest)
+		return
+	}
+
+	sortBy := query.Get("sort")
+	if sortBy == "" {
+		sortBy = "absolute"
+	}
+	if sortBy != "absolute" && sortBy != "percentage" {
+		http.Error(w, "Invalid sort. Use absolute or percentage", http.StatusBadRequest)
+		return
+	}
+
+	movers, err := h.groupRepo.GetMovers(id, from, to, sortBy)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if movers == nil {
+		http.Error(w, "Group not found", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(movers)
+}
+
 func (h *AccountGroupHandler) Archive(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	id, err := strconv.Atoi(vars["id"])
@@ -2,8 +2,10 @@ package handlers
 
 import (
 	"encoding/json"
+	"fmt"
 	"net/http"
 	"strconv"
+	"strings"
 
 	"finance-tracker/internal/models"
 	"finance-tracker/internal/repository"
@@ -13,20 +15,33 @@ import (
 )
 
 type AccountHandler struct {
-	repo           *repository.AccountRepository
-	membershipRepo *repository.MembershipRepository
+	repo                 *repository.AccountRepository
+	membershipRepo       *repository.MembershipRepository
+	tagRepo              *repository.TagRepository
+	revealAccountNumbers bool
 }
 
-func NewAccountHandler(repo *repository.AccountRepository, membershipRepo *repository.MembershipRepository) *AccountHandler {
-	return &AccountHandler{repo: repo, membershipRepo: membershipRepo}
+// NewAccountHandler creates an account handler. Full account numbers are only
+// returned when revealAccountNumbers is true; otherwise only the masked form is.
+func NewAccountHandler(repo *repository.AccountRepository, membershipRepo *repository.MembershipRepository, tagRepo *repository.TagRepository, revealAccountNumbers bool) *AccountHandler {
+	return &AccountHandler{repo: repo, membershipRepo: membershipRepo, tagRepo: tagRepo, revealAccountNumbers: revealAccountNumbers}
 }
 
 func (h *AccountHandler) GetAll(w http.ResponseWriter, r *http.Request) {
-	accounts, err := h.repo.GetAll()
+	query := r.URL.Query()
+	if query.Has("page") || query.Has("page_size") || query.Has("sort") || query.Has("order") {
+		h.getPaginated(w, r)
+		return
+	}
+
+	accounts, err := h.repo.GetAllWithChanges()
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
+	if tag := query.Get("tag"); tag != "" {
+		accounts = repository.FilterAccountsByTag(accounts, tag)
+	}
 	if accounts == nil {
 		accounts = []models.Account{}
 	}
@@ -34,6 +49,43 @@ func (h *AccountHandler) GetAll(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(accounts)
 }
 
+// getPaginated serves GET /accounts when pagination or sorting params are given
+func (h *AccountHandler) getPaginated(w http.ResponseWriter, r *http.Request) {
+	page := 1
+	pageSize := 50
+
+	if p := r.URL.Query().Get("page"); p != "" {
+		if parsed, err := strconv.Atoi(p); err == nil && parsed > 0 {
+			page = parsed
+		}
+	}
+	if ps := r.URL.Query().Get("page_size"); ps != "" {
+		if parsed, err := strconv.Atoi(ps); err == nil && parsed > 0 && parsed <= 500 {
+			pageSize = parsed
+		}
+	}
+
+	sortBy := r.URL.Query().Get("sort")
+	if sortBy != "" && sortBy != "name" && sortBy != "balance" && sortBy != "updated_at" {
+		http.Error(w, "Invalid sort. Must be 'name', 'balance', or 'updated_at'", http.StatusBadRequest)
+		return
+	}
+	order := r.URL.Query().Get("order")
+	if order != "" && order != "asc" && order != "desc" {
+		http.Error(w, "Invalid order. Must be 'asc' or 'desc'", http.StatusBadRequest)
+		return
+	}
+
+	response, err := h.repo.GetPaginated(page, pageSize, sortBy, order, r.URL.Query().Get("tag"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
 func (h *AccountHandler) GetByID(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	id, err := strconv.Atoi(vars["id"])
@@ -68,6 +120,11 @@ func (h *AccountHandler) Create(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if err := validation.ValidateFormulaOperations(req.Formula); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
 	// Validate formula for circular dependencies
 	if req.IsCalculated && len(req.Formula) > 0 {
 		allAccounts, err := h.repo.GetAll()
@@ -92,6 +149,39 @@ func (h *AccountHandler) Create(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(account)
 }
 
+// Duplicate creates a copy of an account, optionally including its group memberships
+func (h *AccountHandler) Duplicate(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		http.Error(w, "Invalid account ID", http.StatusBadRequest)
+		return
+	}
+
+	// Body is optional; defaults copy nothing extra and keep the source balance
+	var req models.DuplicateAccountRequest
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+	}
+
+	account, err := h.repo.Duplicate(id, &req)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if account == nil {
+		http.Error(w, "Account not found", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(account)
+}
+
 func (h *AccountHandler) UpdateName(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	id, err := strconv.Atoi(vars["id"])
@@ -181,6 +271,67 @@ func (h *AccountHandler) UpdateInfo(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(account)
 }
 
+// UpdateAccountNumber sets or clears the structured account number
+func (h *AccountHandler) UpdateAccountNumber(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		http.Error(w, "Invalid account ID", http.StatusBadRequest)
+		return
+	}
+
+	var req models.UpdateAccountNumberRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	account, err := h.repo.UpdateAccountNumber(id, req.AccountNumber)
+	if err != nil {
+		if repository.IsValidationError(err) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		} else {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+	if account == nil {
+		http.Error(w, "Account not found", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(account)
+}
+
+// GetAccountNumber returns the full account number when REVEAL_ACCOUNT_NUMBERS is enabled
+func (h *AccountHandler) GetAccountNumber(w http.ResponseWriter, r *http.Request) {
+	if !h.revealAccountNumbers {
+		http.Error(w, "Full account numbers are not available", http.StatusForbidden)
+		return
+	}
+
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		http.Error(w, "Invalid account ID", http.StatusBadRequest)
+		return
+	}
+
+	accountNumber, err := h.repo.GetAccountNumber(id)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if accountNumber == nil {
+		http.Error(w, "Account not found", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(models.AccountNumberResponse{AccountNumber: *accountNumber})
+}
+
 func (h *AccountHandler) Archive(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	id, err := strconv.Atoi(vars["id"])
@@ -203,6 +354,194 @@ func (h *AccountHandler) Archive(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(account)
 }
 
+func (h *AccountHandler) BulkArchive(w http.ResponseWriter, r *http.Request) {
+	h.bulkSetArchived(w, r, true)
+}
+
+func (h *AccountHandler) BulkUnarchive(w http.ResponseWriter, r *http.Request) {
+	h.bulkSetArchived(w, r, false)
+}
+
+func (h *AccountHandler) bulkSetArchived(w http.ResponseWriter, r *http.Request, archived bool) {
+	var req models.BulkArchiveRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if len(req.IDs) == 0 {
+		http.Error(w, "IDs array is required", http.StatusBadRequest)
+		return
+	}
+
+	response, err := h.repo.BulkSetArchived(req.IDs, archived, req.Force)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+func (h *AccountHandler) GetTags(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		http.Error(w, "Invalid account ID", http.StatusBadRequest)
+		return
+	}
+
+	tags, err := h.tagRepo.GetTags(id)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(tags)
+}
+
+// GetTotalsByTag returns the total balance of accounts for each tag
+func (h *AccountHandler) GetTotalsByTag(w http.ResponseWriter, r *http.Request) {
+	totals, err := h.repo.GetTotalsByTag()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(totals)
+}
+
+func (h *AccountHandler) AddTag(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		http.Error(w, "Invalid account ID", http.StatusBadRequest)
+		return
+	}
+
+	var req models.AddTagRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	account, err := h.repo.GetByID(id)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if account == nil {
+		http.Error(w, "Account not found", http.StatusNotFound)
+		return
+	}
+
+	if err := h.tagRepo.AddTag(id, req.Name); err != nil {
+		if repository.IsValidationError(err) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		} else {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	tags, err := h.tagRepo.GetTags(id)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(tags)
+}
+
+func (h *AccountHandler) RemoveTag(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		http.Error(w, "Invalid account ID", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.tagRepo.RemoveTag(id, vars["tag"]); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	tags, err := h.tagRepo.GetTags(id)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(tags)
+}
+
+// Move repositions one account without sending the full position list
+func (h *AccountHandler) Move(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		http.Error(w, "Invalid account ID", http.StatusBadRequest)
+		return
+	}
+
+	var req models.MoveAccountRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	account, err := h.repo.Move(id, &req)
+	if err != nil {
+		if repository.IsValidationError(err) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		} else {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+	if account == nil {
+		http.Error(w, "Account not found", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(account)
+}
+
+// GetReferences lists everything that depends on an account
+func (h *AccountHandler) GetReferences(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		http.Error(w, "Invalid account ID", http.StatusBadRequest)
+		return
+	}
+
+	account, err := h.repo.GetByID(id)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if account == nil {
+		http.Error(w, "Account not found", http.StatusNotFound)
+		return
+	}
+
+	refs, err := h.repo.GetReferences(id)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(refs)
+}
+
 func (h *AccountHandler) GetHistory(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	id, err := strconv.Atoi(vars["id"])
@@ -224,6 +563,120 @@ func (h *AccountHandler) GetHistory(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(history)
 }
 
+// GetForecast projects an account's balance over ?horizon= (e.g. 90d, 12w, 3m; default 90d)
+func (h *AccountHandler) GetForecast(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		http.Error(w, "Invalid account ID", http.StatusBadRequest)
+		return
+	}
+
+	horizonDays := 90
+	if horizon := r.URL.Query().Get("horizon"); horizon != "" {
+		horizonDays, err = parseHorizonDays(horizon)
+		if err != nil {
+			http.Error(w, "Invalid horizon. Use a number of days, weeks or months like 90d, 12w or 3m", http.StatusBadRequest)
+			return
+		}
+	}
+
+	forecast, err := h.repo.GetForecast(id, horizonDays)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if forecast == nil {
+		http.Error(w, "Account not found", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(forecast)
+}
+
+// parseHorizonDays parses a horizon like "90d", "12w", "3m" or "90" into days
+func parseHorizonDays(value string) (int, error) {
+	multiplier := 1
+	switch {
+	case strings.HasSuffix(value, "d"):
+		value = strings.TrimSuffix(value, "d")
+	case strings.HasSuffix(value, "w"):
+		value = strings.TrimSuffix(value, "w")
+		multiplier = 7
+	case strings.HasSuffix(value, "m"):
+		value = strings.TrimSuffix(value, "m")
+		multiplier = 30
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, err
+	}
+	if n <= 0 || n*multiplier > 3650 {
+		return 0, fmt.Errorf("horizon out of range")
+	}
+	return n * multiplier, nil
+}
+
+// GetNetWorthHistory returns the total balance of all accounts over time
+func (h *AccountHandler) GetNetWorthHistory(w http.ResponseWriter, r *http.Request) {
+	history, err := h.repo.GetNetWorthHistory()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if history == nil {
+		history = []models.NetWorthPoint{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(history)
+}
+
+// GetTotalExcluding returns the grand total with the accounts in account_ids and the
+// groups in group_ids (comma-separated) treated as absent
+func (h *AccountHandler) GetTotalExcluding(w http.ResponseWriter, r *http.Request) {
+	accountIDs, err := parseIDList(r.URL.Query().Get("account_ids"))
+	if err != nil {
+		http.Error(w, "Invalid account_ids", http.StatusBadRequest)
+		return
+	}
+	groupIDs, err := parseIDList(r.URL.Query().Get("group_ids"))
+	if err != nil {
+		http.Error(w, "Invalid group_ids", http.StatusBadRequest)
+		return
+	}
+
+	total, err := h.repo.GetTotalExcluding(accountIDs, groupIDs)
+	if err != nil {
+		if repository.IsValidationError(err) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		} else {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(total)
+}
+
+// parseIDList parses a comma-separated list of IDs; an empty value is an empty list
+func parseIDList(value string) ([]int, error) {
+	var ids []int
+	if value == "" {
+		return ids, nil
+	}
+	for _, part := range strings.Split(value, ",") {
+		id, err := strconv.Atoi(strings.TrimSpace(part))
+		if err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
 func (h *AccountHandler) UpdatePositions(w http.ResponseWriter, r *http.Request) {
 	var req models.UpdatePositionsRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
@@ -341,6 +794,11 @@ func (h *AccountHandler) UpdateFormula(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if err := validation.ValidateFormulaOperations(req.Formula); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
 	// Validate formula for circular dependencies
 	if req.IsCalculated && len(req.Formula) > 0 {
 		allAccounts, err := h.repo.GetAll()
@@ -368,6 +826,118 @@ func (h *AccountHandler) UpdateFormula(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(account)
 }
 
+// GetBrokenReferences lists formula items that point at archived or deleted accounts
+func (h *AccountHandler) GetBrokenReferences(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		http.Error(w, "Invalid account ID", http.StatusBadRequest)
+		return
+	}
+
+	refs, err := h.repo.GetBrokenReferences(id)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if refs == nil {
+		http.Error(w, "Account not found", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(refs)
+}
+
+// GetEffectiveFormula returns a calculated account's formula expanded into
+// coefficients on base accounts
+func (h *AccountHandler) GetEffectiveFormula(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		http.Error(w, "Invalid account ID", http.StatusBadRequest)
+		return
+	}
+
+	formula, err := h.repo.GetEffectiveFormula(id)
+	if err != nil {
+		if repository.IsValidationError(err) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		} else {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+	if formula == nil {
+		http.Error(w, "Account not found", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(formula)
+}
+
+// RepairFormula remaps or removes formula items that point at archived or deleted accounts
+func (h *AccountHandler) RepairFormula(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		http.Error(w, "Invalid account ID", http.StatusBadRequest)
+		return
+	}
+
+	// Body is optional; without remaps every broken reference is removed
+	var req models.RepairFormulaRequest
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+	}
+
+	account, err := h.repo.RepairFormula(id, &req)
+	if err != nil {
+		if repository.IsValidationError(err) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		} else {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+	if account == nil {
+		http.Error(w, "Account not found", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(account)
+}
+
+// BulkUpdateFormulas sets many account formulas at once, validating them together
+func (h *AccountHandler) BulkUpdateFormulas(w http.ResponseWriter, r *http.Request) {
+	var req models.BulkUpdateFormulasRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	result, err := h.repo.BulkUpdateFormulas(req.Formulas)
+	if err != nil {
+		if repository.IsValidationError(err) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		} else {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if !result.Applied {
+		w.WriteHeader(http.StatusBadRequest)
+	}
+	json.NewEncoder(w).Encode(result)
+}
+
 func (h *AccountHandler) GetAllIncludingArchived(w http.ResponseWriter, r *http.Request) {
 	accounts, err := h.repo.GetAllIncludingArchived()
 	if err != nil {
@@ -440,7 +1010,8 @@ func (h *AccountHandler) SetInstitution(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	if err := h.membershipRepo.SetInstitution(id, req.InstitutionID); err != nil {
+	response, err := h.membershipRepo.SetInstitution(id, req.InstitutionID)
+	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
@@ -455,9 +1026,88 @@ func (h *AccountHandler) SetInstitution(w http.ResponseWriter, r *http.Request)
 		http.Error(w, "Account not found", http.StatusNotFound)
 		return
 	}
+	response.Account = account
 
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(account)
+	json.NewEncoder(w).Encode(response)
+}
+
+// AddInstitution adds an account to an additional institution (requires ALLOW_MULTIPLE_INSTITUTIONS)
+func (h *AccountHandler) AddInstitution(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		http.Error(w, "Invalid account ID", http.StatusBadRequest)
+		return
+	}
+
+	var req models.AddInstitutionRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	account, err := h.repo.GetByID(id)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if account == nil {
+		http.Error(w, "Account not found", http.StatusNotFound)
+		return
+	}
+
+	total, err := h.membershipRepo.AddInstitution(id, req.InstitutionID)
+	if err != nil {
+		if repository.IsValidationError(err) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		} else {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	h.writeInstitutionResponse(w, id, &models.SetInstitutionResponse{Institution: total})
+}
+
+// RemoveInstitution removes an account from one of its institutions
+func (h *AccountHandler) RemoveInstitution(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		http.Error(w, "Invalid account ID", http.StatusBadRequest)
+		return
+	}
+	institutionID, err := strconv.Atoi(vars["institutionId"])
+	if err != nil {
+		http.Error(w, "Invalid institution ID", http.StatusBadRequest)
+		return
+	}
+
+	total, err := h.membershipRepo.RemoveInstitution(id, institutionID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	h.writeInstitutionResponse(w, id, &models.SetInstitutionResponse{PreviousInstitution: total})
+}
+
+// writeInstitutionResponse attaches the updated account to an institution change response
+func (h *AccountHandler) writeInstitutionResponse(w http.ResponseWriter, id int, response *models.SetInstitutionResponse) {
+	account, err := h.repo.GetByID(id)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if account == nil {
+		http.Error(w, "Account not found", http.StatusNotFound)
+		return
+	}
+	response.Account = account
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
 }
 
 func HealthCheck(w http.ResponseWriter, r *http.Request) {
@@ -67,6 +67,30 @@ func (h *DashboardHandler) GetByID(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(dashboard)
 }
 
+// Clone copies a dashboard with its items and formula as a new, non-main dashboard
+func (h *DashboardHandler) Clone(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		http.Error(w, "Invalid dashboard ID", http.StatusBadRequest)
+		return
+	}
+
+	dashboard, err := h.repo.Clone(id)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if dashboard == nil {
+		http.Error(w, "Dashboard not found", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(dashboard)
+}
+
 func (h *DashboardHandler) Create(w http.ResponseWriter, r *http.Request) {
 	var req models.CreateDashboardRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
@@ -81,7 +105,11 @@ func (h *DashboardHandler) Create(w http.ResponseWriter, r *http.Request) {
 
 	dashboard, err := h.repo.Create(&req)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		if repository.IsValidationError(err) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		} else {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
 		return
 	}
 
@@ -111,7 +139,11 @@ func (h *DashboardHandler) Update(w http.ResponseWriter, r *http.Request) {
 
 	dashboard, err := h.repo.Update(id, &req)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		if repository.IsValidationError(err) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		} else {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
 		return
 	}
 	if dashboard == nil {
@@ -220,6 +252,73 @@ func (h *DashboardHandler) GetHistory(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(history)
 }
 
+// Compare shows how a dashboard's items and total changed between ?from= and ?to=
+// (YYYY-MM-DD or RFC3339). Dates without a time cover the whole day.
+func (h *DashboardHandler) Compare(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		http.Error(w, "Invalid dashboard ID", http.StatusBadRequest)
+		return
+	}
+
+	query := r.URL.Query()
+	if query.Get("from") == "" || query.Get("to") == "" {
+		http.Error(w, "from and to are required", http.StatusBadRequest)
+		return
+	}
+	from, err := parseDate(query.Get("from"), true)
+	if err != nil {
+		http.Error(w, "Invalid from date. Use YYYY-MM-DD or RFC3339", http.StatusBadRequest)
+		return
+	}
+	to, err := parseDate(query.Get("to"), true)
+	if err != nil {
+		http.Error(w, "Invalid to date. Use YYYY-MM-DD or RFC3339", http.StatusBadRequest)
+		return
+	}
+	if from.After(to) {
+		http.Error(w, "from must not be after to", http.StatusBadRequest)
+		return
+	}
+
+	comparison, err := h.repo.Compare(id, from, to)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if comparison == nil {
+		http.Error(w, "Dashboard not found", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(comparison)
+}
+
+// UpdatePositions reorders dashboards in the dashboard list
+func (h *DashboardHandler) UpdatePositions(w http.ResponseWriter, r *http.Request) {
+	var req models.UpdateDashboardPositionsRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if len(req.Positions) == 0 {
+		http.Error(w, "Positions array is required", http.StatusBadRequest)
+		return
+	}
+
+	order, err := h.repo.UpdatePositions(req.Positions)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(order)
+}
+
 func (h *DashboardHandler) UpdateItemPositions(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	id, err := strconv.Atoi(vars["id"])
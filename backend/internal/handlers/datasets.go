@@ -1,11 +1,15 @@
 package handlers
 
 import (
+	"bytes"
 	"encoding/csv"
 	"encoding/json"
 	"fmt"
+	"io"
+	"log"
 	"net/http"
 	"strconv"
+	"strings"
 
 	"finance-tracker/internal/models"
 	"finance-tracker/internal/repository"
@@ -47,6 +51,36 @@ func (h *DatasetHandler) GetAll(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(response)
 }
 
+// GetSyncOverview returns sync health for all folder-backed datasets
+func (h *DatasetHandler) GetSyncOverview(w http.ResponseWriter, r *http.Request) {
+	overview, err := h.repo.GetSyncOverview()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if overview == nil {
+		overview = []models.DatasetSyncOverview{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(overview)
+}
+
+// GetFailures lists datasets whose most recent sync failed
+func (h *DatasetHandler) GetFailures(w http.ResponseWriter, r *http.Request) {
+	failures, err := h.repo.GetFailures()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if failures == nil {
+		failures = []models.DatasetFailure{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(failures)
+}
+
 func (h *DatasetHandler) GetByID(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	id, err := strconv.Atoi(vars["id"])
@@ -96,8 +130,9 @@ func (h *DatasetHandler) Create(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// The initial sync runs in the background; the dataset is returned as pending
 	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusCreated)
+	w.WriteHeader(http.StatusAccepted)
 	json.NewEncoder(w).Encode(dataset)
 }
 
@@ -144,8 +179,23 @@ func (h *DatasetHandler) GetData(w http.ResponseWriter, r *http.Request) {
 	sortColumn := r.URL.Query().Get("sort_column")
 	sortDirection := r.URL.Query().Get("sort_direction")
 
-	response, err := h.repo.GetData(id, page, pageSize, sortColumn, sortDirection)
+	filter := models.DataFilter{
+		Column: r.URL.Query().Get("filter_column"),
+		Op:     r.URL.Query().Get("filter_op"),
+		Value:  r.URL.Query().Get("filter_value"),
+		Query:  strings.TrimSpace(r.URL.Query().Get("q")),
+	}
+
+	response, err := h.repo.GetData(id, page, pageSize, sortColumn, sortDirection, filter)
 	if err != nil {
+		if repository.IsValidationError(err) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if repository.IsQueryTimeout(err) {
+			http.Error(w, "Dataset query timed out", http.StatusGatewayTimeout)
+			return
+		}
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
@@ -154,6 +204,11 @@ func (h *DatasetHandler) GetData(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// NULL cells are JSON null unless a display value is requested
+	if r.URL.Query().Has("null_value") {
+		response.ReplaceNulls(r.URL.Query().Get("null_value"))
+	}
+
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(response)
 }
@@ -167,7 +222,11 @@ func (h *DatasetHandler) Sync(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if err := h.repo.SyncDataset(id); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		if repository.IsAlreadySyncing(err) {
+			http.Error(w, err.Error(), http.StatusConflict)
+		} else {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
 		return
 	}
 
@@ -182,6 +241,519 @@ func (h *DatasetHandler) Sync(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(dataset)
 }
 
+// UpdateAutoSync configures whether reads of a dataset trigger a background sync
+func (h *DatasetHandler) UpdateAutoSync(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		http.Error(w, "Invalid dataset ID", http.StatusBadRequest)
+		return
+	}
+
+	var req models.UpdateAutoSyncRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	dataset, err := h.repo.UpdateAutoSync(id, &req)
+	if err != nil {
+		if repository.IsValidationError(err) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		} else {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+	if dataset == nil {
+		http.Error(w, "Dataset not found", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(dataset)
+}
+
+// GetDateRange returns the earliest and latest dates in a date column
+func (h *DatasetHandler) GetDateRange(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		http.Error(w, "Invalid dataset ID", http.StatusBadRequest)
+		return
+	}
+
+	dateRange, err := h.repo.GetDateRange(id, r.URL.Query().Get("column"))
+	if err != nil {
+		if repository.IsValidationError(err) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if repository.IsQueryTimeout(err) {
+			http.Error(w, "Dataset query timed out", http.StatusGatewayTimeout)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if dateRange == nil {
+		http.Error(w, "Dataset not found", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(dateRange)
+}
+
+// GetStats returns per-column summary statistics for a ready dataset
+func (h *DatasetHandler) GetStats(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		http.Error(w, "Invalid dataset ID", http.StatusBadRequest)
+		return
+	}
+
+	topN := repository.DefaultStatsTopValues
+	if t := r.URL.Query().Get("top"); t != "" {
+		if parsed, err := strconv.Atoi(t); err == nil && parsed > 0 && parsed <= 50 {
+			topN = parsed
+		}
+	}
+
+	stats, err := h.repo.GetStats(id, topN)
+	if err != nil {
+		if repository.IsNotReady(err) {
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
+		if repository.IsQueryTimeout(err) {
+			http.Error(w, "Dataset query timed out", http.StatusGatewayTimeout)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if stats == nil {
+		http.Error(w, "Dataset not found", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(stats)
+}
+
+// UpdateColumnSettings renames and reorders a dataset's columns
+func (h *DatasetHandler) UpdateColumnSettings(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		http.Error(w, "Invalid dataset ID", http.StatusBadRequest)
+		return
+	}
+
+	var req models.UpdateColumnSettingsRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	settings, err := h.repo.UpdateColumnSettings(id, &req)
+	if err != nil {
+		if repository.IsValidationError(err) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		} else {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+	if settings == nil {
+		http.Error(w, "Dataset not found", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(settings)
+}
+
+// UpdateColumnDescription sets or clears the description of a dataset column
+func (h *DatasetHandler) UpdateColumnDescription(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		http.Error(w, "Invalid dataset ID", http.StatusBadRequest)
+		return
+	}
+
+	var req models.UpdateColumnDescriptionRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	description, err := h.repo.UpdateColumnDescription(id, vars["column"], &req)
+	if err != nil {
+		if repository.IsValidationError(err) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		} else {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+	if description == nil {
+		http.Error(w, "Dataset not found", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(description)
+}
+
+// UpdateCombineMode changes how a dataset combines its folder's CSV files and resyncs it
+func (h *DatasetHandler) UpdateCombineMode(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		http.Error(w, "Invalid dataset ID", http.StatusBadRequest)
+		return
+	}
+
+	var req models.UpdateCombineModeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	dataset, err := h.repo.UpdateCombineMode(id, &req)
+	if err != nil {
+		if repository.IsValidationError(err) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		} else {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+	if dataset == nil {
+		http.Error(w, "Dataset not found", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(dataset)
+}
+
+// UpdateSkipRows sets how many records precede each file's header and resyncs the dataset
+func (h *DatasetHandler) UpdateSkipRows(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		http.Error(w, "Invalid dataset ID", http.StatusBadRequest)
+		return
+	}
+
+	var req models.UpdateSkipRowsRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	dataset, err := h.repo.UpdateSkipRows(id, &req)
+	if err != nil {
+		if repository.IsValidationError(err) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		} else {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+	if dataset == nil {
+		http.Error(w, "Dataset not found", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(dataset)
+}
+
+// UpdateDelimiter sets the field delimiter of a dataset's CSV files and resyncs it
+func (h *DatasetHandler) UpdateDelimiter(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		http.Error(w, "Invalid dataset ID", http.StatusBadRequest)
+		return
+	}
+
+	var req models.UpdateDelimiterRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	dataset, err := h.repo.UpdateDelimiter(id, &req)
+	if err != nil {
+		if repository.IsValidationError(err) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		} else {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+	if dataset == nil {
+		http.Error(w, "Dataset not found", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(dataset)
+}
+
+// UpdateDedupe configures dropping duplicate rows and resyncs the dataset
+func (h *DatasetHandler) UpdateDedupe(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		http.Error(w, "Invalid dataset ID", http.StatusBadRequest)
+		return
+	}
+
+	var req models.UpdateDedupeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	dataset, err := h.repo.UpdateDedupe(id, &req)
+	if err != nil {
+		if repository.IsValidationError(err) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		} else {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+	if dataset == nil {
+		http.Error(w, "Dataset not found", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(dataset)
+}
+
+// UpdateDerivedColumns replaces the dataset's expression-based columns and resyncs it
+func (h *DatasetHandler) UpdateDerivedColumns(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		http.Error(w, "Invalid dataset ID", http.StatusBadRequest)
+		return
+	}
+
+	var req models.UpdateDerivedColumnsRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	dataset, err := h.repo.UpdateDerivedColumns(id, &req)
+	if err != nil {
+		if repository.IsValidationError(err) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		} else {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+	if dataset == nil {
+		http.Error(w, "Dataset not found", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(dataset)
+}
+
+// GetTransformations lists a dataset's transformation pipeline in order
+func (h *DatasetHandler) GetTransformations(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		http.Error(w, "Invalid dataset ID", http.StatusBadRequest)
+		return
+	}
+
+	steps, err := h.repo.GetTransformations(id)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if steps == nil {
+		http.Error(w, "Dataset not found", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(steps)
+}
+
+// CreateTransformation appends a step to a dataset's pipeline and resyncs it
+func (h *DatasetHandler) CreateTransformation(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		http.Error(w, "Invalid dataset ID", http.StatusBadRequest)
+		return
+	}
+
+	var req models.CreateTransformationRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	step, err := h.repo.CreateTransformation(id, &req)
+	if err != nil {
+		if repository.IsValidationError(err) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		} else {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+	if step == nil {
+		http.Error(w, "Dataset not found", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(step)
+}
+
+// DeleteTransformation removes a step from a dataset's pipeline and resyncs it
+func (h *DatasetHandler) DeleteTransformation(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		http.Error(w, "Invalid dataset ID", http.StatusBadRequest)
+		return
+	}
+	stepID, err := strconv.Atoi(vars["stepId"])
+	if err != nil {
+		http.Error(w, "Invalid transformation ID", http.StatusBadRequest)
+		return
+	}
+
+	found, err := h.repo.DeleteTransformation(id, stepID)
+	if err != nil {
+		if repository.IsValidationError(err) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		} else {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+	if !found {
+		http.Error(w, "Transformation not found", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]bool{"success": true})
+}
+
+// UpdateTransformationPositions reorders a dataset's pipeline and resyncs it
+func (h *DatasetHandler) UpdateTransformationPositions(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		http.Error(w, "Invalid dataset ID", http.StatusBadRequest)
+		return
+	}
+
+	var req models.UpdateTransformationPositionsRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if len(req.Positions) == 0 {
+		http.Error(w, "Positions array is required", http.StatusBadRequest)
+		return
+	}
+
+	steps, err := h.repo.UpdateTransformationPositions(id, req.Positions)
+	if err != nil {
+		if repository.IsValidationError(err) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		} else {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+	if steps == nil {
+		http.Error(w, "Dataset not found", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(steps)
+}
+
+// GetAggregationOptions returns the valid aggregation operators for each column
+func (h *DatasetHandler) GetAggregationOptions(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		http.Error(w, "Invalid dataset ID", http.StatusBadRequest)
+		return
+	}
+
+	options, err := h.repo.GetAggregationOptions(id)
+	if err != nil {
+		if repository.IsQueryTimeout(err) {
+			http.Error(w, "Dataset query timed out", http.StatusGatewayTimeout)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if options == nil {
+		http.Error(w, "Dataset not found", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(options)
+}
+
+// Recount reconciles the stored row count with the dataset's table
+func (h *DatasetHandler) Recount(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		http.Error(w, "Invalid dataset ID", http.StatusBadRequest)
+		return
+	}
+
+	dataset, err := h.repo.Recount(id)
+	if err != nil {
+		if repository.IsQueryTimeout(err) {
+			http.Error(w, "Dataset query timed out", http.StatusGatewayTimeout)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if dataset == nil {
+		http.Error(w, "Dataset not found", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(dataset)
+}
+
 func (h *DatasetHandler) GetSyncStatus(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	id, err := strconv.Atoi(vars["id"])
@@ -196,6 +768,8 @@ func (h *DatasetHandler) GetSyncStatus(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(map[string]bool{"syncing": syncing})
 }
 
+// Export streams all rows of a dataset as CSV (default) or, with ?format=json, as a
+// JSON array of objects keyed by column name
 func (h *DatasetHandler) Export(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	id, err := strconv.Atoi(vars["id"])
@@ -204,6 +778,15 @@ func (h *DatasetHandler) Export(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = "csv"
+	}
+	if format != "csv" && format != "json" {
+		http.Error(w, "Invalid format: must be csv or json", http.StatusBadRequest)
+		return
+	}
+
 	// Get dataset for filename
 	dataset, err := h.repo.GetByID(id)
 	if err != nil {
@@ -215,44 +798,148 @@ func (h *DatasetHandler) Export(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Get all data
-	response, err := h.repo.GetAllData(id)
+	// NULL cells are empty CSV fields and JSON nulls unless a display value is requested
+	replaceNulls := format == "csv" || r.URL.Query().Has("null_value")
+	nullValue := r.URL.Query().Get("null_value")
+
+	var exporter datasetExporter
+	if format == "json" {
+		exporter = &jsonExporter{w: w}
+	} else {
+		exporter = &csvExporter{writer: csv.NewWriter(w)}
+	}
+
+	// Headers are only sent once the query has started, so earlier failures
+	// can still be reported with an error status
+	started := false
+	onColumns := func(columns []string) error {
+		started = true
+		w.Header().Set("Content-Type", exporter.contentType())
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s.%s\"", dataset.Name, format))
+		return exporter.writeHeader(columns)
+	}
+	onRow := func(row []any) error {
+		if replaceNulls {
+			models.ReplaceNullCells(row, nullValue)
+		}
+		return exporter.writeRow(row)
+	}
+
+	found, err := h.repo.StreamAllData(r.Context(), id, onColumns, onRow)
+	if err == nil && found {
+		err = exporter.finish()
+	}
 	if err != nil {
+		if started {
+			// The 200 status is already sent, so mark the body as broken and abort the
+			// connection; the client then sees an incomplete transfer, not a short file
+			log.Printf("Failed to export dataset %d: %v", id, err)
+			exporter.abort(err)
+			panic(http.ErrAbortHandler)
+		}
+		if repository.IsQueryTimeout(err) {
+			http.Error(w, "Dataset query timed out", http.StatusGatewayTimeout)
+			return
+		}
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
-	if response == nil {
-		http.Error(w, "No data available", http.StatusNotFound)
-		return
+	if !found {
+		http.Error(w, "Dataset not found", http.StatusNotFound)
 	}
+}
 
-	// Set headers for CSV download
-	w.Header().Set("Content-Type", "text/csv")
-	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s.csv\"", dataset.Name))
+// datasetExporter writes streamed dataset rows in an export format
+type datasetExporter interface {
+	contentType() string
+	writeHeader(columns []string) error
+	writeRow(row []any) error
+	finish() error
+	// abort ends an export that failed part way through
+	abort(err error)
+}
 
-	// Write CSV
-	writer := csv.NewWriter(w)
-	defer writer.Flush()
+type csvExporter struct {
+	writer *csv.Writer
+}
 
-	// Write header row
-	if err := writer.Write(response.Columns); err != nil {
-		http.Error(w, "Failed to write CSV", http.StatusInternalServerError)
-		return
+func (e *csvExporter) contentType() string { return "text/csv" }
+
+func (e *csvExporter) writeHeader(columns []string) error {
+	return e.writer.Write(columns)
+}
+
+func (e *csvExporter) writeRow(row []any) error {
+	record := make([]string, len(row))
+	for i, val := range row {
+		record[i] = fmt.Sprintf("%v", val)
+	}
+	return e.writer.Write(record)
+}
+
+func (e *csvExporter) finish() error {
+	e.writer.Flush()
+	return e.writer.Error()
+}
+
+// abort flushes the rows written so far; an error row would read as data
+func (e *csvExporter) abort(err error) {
+	e.writer.Flush()
+}
+
+// jsonExporter writes a JSON array of objects, keeping keys in column order
+type jsonExporter struct {
+	w       io.Writer
+	keys    [][]byte // JSON-encoded column names
+	written int
+}
+
+func (e *jsonExporter) contentType() string { return "application/json" }
+
+func (e *jsonExporter) writeHeader(columns []string) error {
+	e.keys = make([][]byte, len(columns))
+	for i, col := range columns {
+		key, err := json.Marshal(col)
+		if err != nil {
+			return err
+		}
+		e.keys[i] = key
 	}
+	_, err := io.WriteString(e.w, "[")
+	return err
+}
 
-	// Write data rows
-	for _, row := range response.Rows {
-		record := make([]string, len(row))
-		for i, val := range row {
-			if val == nil {
-				record[i] = ""
-			} else {
-				record[i] = fmt.Sprintf("%v", val)
-			}
+func (e *jsonExporter) writeRow(row []any) error {
+	var buf bytes.Buffer
+	if e.written > 0 {
+		buf.WriteString(",")
+	}
+	buf.WriteString("\n{")
+	for i, val := range row {
+		if i > 0 {
+			buf.WriteString(",")
 		}
-		if err := writer.Write(record); err != nil {
-			http.Error(w, "Failed to write CSV", http.StatusInternalServerError)
-			return
+		value, err := json.Marshal(val)
+		if err != nil {
+			return err
 		}
+		buf.Write(e.keys[i])
+		buf.WriteString(":")
+		buf.Write(value)
 	}
+	buf.WriteString("}")
+	e.written++
+	_, err := e.w.Write(buf.Bytes())
+	return err
+}
+
+func (e *jsonExporter) finish() error {
+	_, err := io.WriteString(e.w, "\n]\n")
+	return err
+}
+
+// abort leaves the array unclosed and appends an error line that is not JSON, so a
+// truncated export fails to parse instead of passing for a shorter one
+func (e *jsonExporter) abort(err error) {
+	fmt.Fprintf(e.w, "\nexport failed: %v\n", err)
 }
@@ -0,0 +1,16 @@
+package handlers
+
+import "time"
+
+// parseDate parses a date in YYYY-MM-DD or RFC3339 format. With endOfDay, a bare
+// YYYY-MM-DD resolves to the last moment of that day so balances recorded during
+// it are included; otherwise it is midnight UTC.
+func parseDate(value string, endOfDay bool) (time.Time, error) {
+	if t, err := time.Parse("2006-01-02", value); err == nil {
+		if endOfDay {
+			return t.Add(24*time.Hour - time.Microsecond), nil
+		}
+		return t, nil
+	}
+	return time.Parse(time.RFC3339, value)
+}
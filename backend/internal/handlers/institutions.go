@@ -7,6 +7,7 @@ import (
 
 	"finance-tracker/internal/models"
 	"finance-tracker/internal/repository"
+	"finance-tracker/internal/validation"
 
 	"github.com/gorilla/mux"
 )
@@ -32,6 +33,17 @@ func (h *InstitutionHandler) GetAll(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(institutions)
 }
 
+// GetAccountsMap returns a flat institution -> accounts mapping for navigation
+func (h *InstitutionHandler) GetAccountsMap(w http.ResponseWriter, r *http.Request) {
+	accountsMap, err := h.groupRepo.GetInstitutionAccountsMap()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(accountsMap)
+}
+
 func (h *InstitutionHandler) GetByID(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	id, err := strconv.Atoi(vars["id"])
@@ -66,6 +78,11 @@ func (h *InstitutionHandler) Create(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if err := validation.ValidateFormulaOperations(req.Formula); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
 	institution, err := h.groupRepo.CreateInstitution(&req)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
@@ -96,6 +113,11 @@ func (h *InstitutionHandler) Update(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if err := validation.ValidateFormulaOperations(req.Formula); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
 	institution, err := h.groupRepo.UpdateInstitution(id, &req)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
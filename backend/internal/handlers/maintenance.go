@@ -0,0 +1,91 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"finance-tracker/internal/models"
+	"finance-tracker/internal/repository"
+)
+
+type MaintenanceHandler struct {
+	historyRepo  *repository.HistoryRepository
+	positionRepo *repository.PositionRepository
+}
+
+func NewMaintenanceHandler(historyRepo *repository.HistoryRepository, positionRepo *repository.PositionRepository) *MaintenanceHandler {
+	return &MaintenanceHandler{historyRepo: historyRepo, positionRepo: positionRepo}
+}
+
+// DownsampleHistory thins out old balance history to one entry per entity per day or week
+func (h *MaintenanceHandler) DownsampleHistory(w http.ResponseWriter, r *http.Request) {
+	var req models.DownsampleHistoryRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if req.OlderThan == "" {
+		http.Error(w, "older_than is required", http.StatusBadRequest)
+		return
+	}
+	olderThan, err := parseDate(req.OlderThan, false)
+	if err != nil {
+		http.Error(w, "Invalid older_than date. Use YYYY-MM-DD or RFC3339", http.StatusBadRequest)
+		return
+	}
+
+	keep := req.Keep
+	if keep == "" {
+		keep = "day"
+	}
+
+	deleted, err := h.historyRepo.Downsample(olderThan, keep)
+	if err != nil {
+		if repository.IsValidationError(err) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		} else {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(models.DownsampleHistoryResponse{Deleted: deleted})
+}
+
+// HistoryStats reports how many balance history rows each entity has and how many were propagated
+func (h *MaintenanceHandler) HistoryStats(w http.ResponseWriter, r *http.Request) {
+	stats, err := h.historyRepo.GetStats()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(stats)
+}
+
+// PositionHealth reports accounts, groups and institutions with duplicate or gapped positions
+func (h *MaintenanceHandler) PositionHealth(w http.ResponseWriter, r *http.Request) {
+	health, err := h.positionRepo.CheckHealth()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(health)
+}
+
+// FixPositions renumbers every ordering scope so positions are unique and contiguous
+func (h *MaintenanceHandler) FixPositions(w http.ResponseWriter, r *http.Request) {
+	result, err := h.positionRepo.FixPositions()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
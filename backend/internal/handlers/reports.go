@@ -0,0 +1,212 @@
+package handlers
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+
+	"finance-tracker/internal/models"
+	"finance-tracker/internal/repository"
+)
+
+type ReportHandler struct {
+	accountRepo *repository.AccountRepository
+	groupRepo   *repository.AccountGroupRepository
+}
+
+func NewReportHandler(accountRepo *repository.AccountRepository, groupRepo *repository.AccountGroupRepository) *ReportHandler {
+	return &ReportHandler{accountRepo: accountRepo, groupRepo: groupRepo}
+}
+
+// ExportList writes the account list as a sectioned report: groups with their
+// accounts and subtotals, ungrouped accounts, institutions, then the grand total
+func (h *ReportHandler) ExportList(w http.ResponseWriter, r *http.Request) {
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = "csv"
+	}
+	if format != "csv" {
+		http.Error(w, "Invalid format. Must be 'csv'", http.StatusBadRequest)
+		return
+	}
+
+	accounts, err := h.accountRepo.GetAll()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	groups, err := h.groupRepo.GetAllWithAccountsByType("group")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	institutions, err := h.groupRepo.GetAllInstitutions()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	var records [][]string
+	records = append(records, []string{"Type", "Name", "Balance"})
+
+	// Groups, with nested child groups under their parents
+	records = append(records, []string{"Section", "Groups", ""})
+	for _, g := range groups {
+		if g.ParentGroupID == nil {
+			records = appendGroupRecords(records, g, 0)
+		}
+	}
+
+	// Accounts that aren't in any group
+	records = append(records, []string{"Section", "Ungrouped Accounts", ""})
+	var ungroupedTotal float64
+	for _, a := range accounts {
+		if len(a.GroupIDs) == 0 {
+			records = append(records, []string{"Account", a.AccountName, formatAmount(a.CurrentBalance)})
+			ungroupedTotal += a.CurrentBalance
+		}
+	}
+	records = append(records, []string{"Subtotal", "Ungrouped Accounts", formatAmount(ungroupedTotal)})
+
+	// Institutions
+	records = append(records, []string{"Section", "Institutions", ""})
+	for _, inst := range institutions {
+		records = appendGroupRecords(records, inst, 0)
+	}
+
+	var grandTotal float64
+	for _, a := range accounts {
+		grandTotal += a.CurrentBalance
+	}
+	records = append(records, []string{"Total", "Grand Total", formatAmount(grandTotal)})
+
+	w.Header().Set("Content-Type", "text/csv")
+	w.Header().Set("Content-Disposition", "attachment; filename=\"accounts-report.csv\"")
+
+	writer := csv.NewWriter(w)
+	if err := writer.WriteAll(records); err != nil {
+		http.Error(w, "Failed to write CSV", http.StatusInternalServerError)
+		return
+	}
+}
+
+// appendGroupRecords adds a group header, its accounts, its child groups and a subtotal row,
+// indenting names by depth so the hierarchy is visible in a spreadsheet
+func appendGroupRecords(records [][]string, group models.AccountGroupWithAccounts, depth int) [][]string {
+	indent := strings.Repeat("    ", depth)
+	typeLabel := "Group"
+	if group.EntityType == "institution" {
+		typeLabel = "Institution"
+	}
+
+	records = append(records, []string{typeLabel, indent + group.Name, ""})
+	for _, a := range group.Accounts {
+		records = append(records, []string{"Account", indent + "    " + a.AccountName, formatAmount(a.CurrentBalance)})
+	}
+	for _, child := range group.ChildGroups {
+		records = appendGroupRecords(records, child, depth+1)
+	}
+	records = append(records, []string{"Subtotal", indent + group.Name, formatAmount(group.TotalBalance)})
+	return records
+}
+
+func formatAmount(amount float64) string {
+	return fmt.Sprintf("%.2f", amount)
+}
+
+// periodReportMovers is how many accounts the period report lists as top movers
+const periodReportMovers = 10
+
+// GetPeriodReport compares the portfolio between two dates: the grand total, each
+// group and institution, each account, and the accounts that moved most
+func (h *ReportHandler) GetPeriodReport(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	format := query.Get("format")
+	if format == "" {
+		format = "json"
+	}
+	if format != "json" && format != "csv" {
+		http.Error(w, "Invalid format. Must be 'json' or 'csv'", http.StatusBadRequest)
+		return
+	}
+
+	if query.Get("from") == "" || query.Get("to") == "" {
+		http.Error(w, "from and to are required", http.StatusBadRequest)
+		return
+	}
+	from, err := parseDate(query.Get("from"), true)
+	if err != nil {
+		http.Error(w, "Invalid from date. Use YYYY-MM-DD or RFC3339", http.StatusBadRequest)
+		return
+	}
+	to, err := parseDate(query.Get("to"), true)
+	if err != nil {
+		http.Error(w, "Invalid to date. Use YYYY-MM-DD or RFC3339", http.StatusBadRequest)
+		return
+	}
+	if from.After(to) {
+		http.Error(w, "from must not be after to", http.StatusBadRequest)
+		return
+	}
+
+	report, err := h.accountRepo.GetPeriodReport(from, to, periodReportMovers)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if format == "json" {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(report)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/csv")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"period-report-%s-%s.csv\"",
+		from.Format("2006-01-02"), to.Format("2006-01-02")))
+
+	// Rows are flushed as they are written so large portfolios stream
+	writer := csv.NewWriter(w)
+	writer.Write([]string{"Section", "Type", "ID", "Name", "From Balance", "To Balance", "Change", "Percent Change"})
+	writer.Write([]string{"Total", "", "", "Grand Total", formatAmount(report.FromTotal), formatAmount(report.ToTotal),
+		formatAmount(report.Change), formatPercent(report.PercentChange)})
+	sections := []struct {
+		name    string
+		changes []models.PeriodChange
+	}{
+		{"Groups", report.Groups},
+		{"Institutions", report.Institutions},
+		{"Accounts", report.Accounts},
+		{"Top Movers", report.TopMovers},
+	}
+	for _, section := range sections {
+		for _, c := range section.changes {
+			writer.Write([]string{section.name, c.EntityType, fmt.Sprint(c.ID), c.Name, formatOptionalAmount(c.FromBalance),
+				formatOptionalAmount(c.ToBalance), formatAmount(c.Change), formatPercent(c.PercentChange)})
+		}
+		writer.Flush()
+	}
+	writer.Flush()
+	if err := writer.Error(); err != nil {
+		// Headers are already sent, so the error can only be logged
+		log.Printf("period report: failed to write CSV: %v", err)
+	}
+}
+
+// formatOptionalAmount formats a balance, leaving it blank when there is none
+func formatOptionalAmount(amount *float64) string {
+	if amount == nil {
+		return ""
+	}
+	return formatAmount(*amount)
+}
+
+func formatPercent(percent *float64) string {
+	if percent == nil {
+		return ""
+	}
+	return fmt.Sprintf("%.2f", *percent)
+}
@@ -10,11 +10,22 @@ type Account struct {
 	IsArchived     bool          `json:"is_archived"`
 	Position       int           `json:"position"`
 	GroupIDs       []int         `json:"group_ids"`
-	InstitutionID  *int          `json:"institution_id"`
+	InstitutionID  *int          `json:"institution_id"`  // Primary (lowest ID) institution
+	InstitutionIDs []int         `json:"institution_ids"` // All institutions, when multiple are enabled
+	Tags           []string      `json:"tags"`
 	IsCalculated   bool          `json:"is_calculated"`
 	Formula        []FormulaItem `json:"formula,omitempty"`
-	CreatedAt      time.Time     `json:"created_at"`
-	UpdatedAt      time.Time     `json:"updated_at"`
+	DivisionByZero bool          `json:"division_by_zero,omitempty"` // Set when a formula divided by zero
+	// Set when formula terms for deleted or archived accounts were skipped
+	MissingReferences bool `json:"missing_references,omitempty"`
+	// Last 4 digits of the structured account number; the full number is never listed
+	MaskedAccountNumber string `json:"masked_account_number,omitempty"`
+	// Change between the two most recent history entries (list responses only)
+	ChangeAmount     *float64  `json:"change_amount,omitempty"`
+	ChangePercent    *float64  `json:"change_percent,omitempty"`
+	ChangePeriodDays *float64  `json:"change_period_days,omitempty"`
+	CreatedAt        time.Time `json:"created_at"`
+	UpdatedAt        time.Time `json:"updated_at"`
 }
 
 // AccountInGroup represents an account within a specific group context
@@ -44,6 +55,12 @@ type CreateAccountRequest struct {
 	Formula        []FormulaItem `json:"formula,omitempty"`
 }
 
+// DuplicateAccountRequest controls what is copied when duplicating an account
+type DuplicateAccountRequest struct {
+	CopyGroups  bool `json:"copy_groups"`
+	ZeroBalance bool `json:"zero_balance"`
+}
+
 type UpdateNameRequest struct {
 	AccountName string `json:"account_name"`
 }
@@ -56,11 +73,83 @@ type UpdateInfoRequest struct {
 	AccountInfo string `json:"account_info"`
 }
 
+type UpdateAccountNumberRequest struct {
+	AccountNumber string `json:"account_number"`
+}
+
+type AccountNumberResponse struct {
+	AccountNumber string `json:"account_number"`
+}
+
 type UpdateFormulaRequest struct {
 	IsCalculated bool          `json:"is_calculated"`
 	Formula      []FormulaItem `json:"formula,omitempty"`
 }
 
+// BrokenFormulaReference is a formula item that points at an archived or deleted account
+// and so contributes nothing to the calculated balance
+type BrokenFormulaReference struct {
+	Index       int     `json:"index"` // Position of the item in the formula
+	AccountID   int     `json:"account_id"`
+	AccountName string  `json:"account_name,omitempty"`
+	Coefficient float64 `json:"coefficient"`
+	Operation   string  `json:"operation,omitempty"`
+	Reason      string  `json:"reason"` // "archived" or "deleted"
+}
+
+// EffectiveFormulaTerm is a base (non-calculated) account's total coefficient once a
+// calculated account's nested formulas are expanded
+type EffectiveFormulaTerm struct {
+	AccountID   int     `json:"account_id"`
+	AccountName string  `json:"account_name"`
+	Coefficient float64 `json:"coefficient"`
+	Balance     float64 `json:"balance"`
+}
+
+// EffectiveFormulaResponse is a calculated account's formula flattened to base accounts.
+// Balance is the sum of each term's coefficient * balance.
+type EffectiveFormulaResponse struct {
+	AccountID   int                    `json:"account_id"`
+	AccountName string                 `json:"account_name"`
+	Terms       []EffectiveFormulaTerm `json:"terms"`
+	Balance     float64                `json:"balance"`
+}
+
+type FormulaRemap struct {
+	FromAccountID int `json:"from_account_id"`
+	ToAccountID   int `json:"to_account_id"`
+}
+
+// RepairFormulaRequest fixes broken formula references. Remapped references keep their
+// coefficient and operation; every other broken reference is removed.
+type RepairFormulaRequest struct {
+	Remap []FormulaRemap `json:"remap,omitempty"`
+}
+
+// BulkFormulaUpdate sets one account's formula as part of a bulk update
+type BulkFormulaUpdate struct {
+	AccountID    int           `json:"account_id"`
+	IsCalculated bool          `json:"is_calculated"`
+	Formula      []FormulaItem `json:"formula,omitempty"`
+}
+
+type BulkUpdateFormulasRequest struct {
+	Formulas []BulkFormulaUpdate `json:"formulas"`
+}
+
+type BulkFormulaResult struct {
+	AccountID int    `json:"account_id"`
+	Status    string `json:"status"` // "updated", "valid" (not applied because another entry failed), or "error"
+	Error     string `json:"error,omitempty"`
+}
+
+// BulkUpdateFormulasResponse reports each account's outcome. Applied is false when
+// any entry failed validation, in which case nothing was changed.
+type BulkUpdateFormulasResponse struct {
+	Applied bool                `json:"applied"`
+	Results []BulkFormulaResult `json:"results"`
+}
+
 type UpdatePositionsRequest struct {
 	Positions []AccountPosition `json:"positions"`
 }
@@ -70,6 +159,126 @@ type AccountPosition struct {
 	Position int `json:"position"`
 }
 
+// MoveAccountRequest moves one account in the main list, which interleaves accounts
+// and groups. Set exactly one of BeforeID, AfterID, or To ("top" or "bottom").
+type MoveAccountRequest struct {
+	BeforeID     *int   `json:"before_id,omitempty"`
+	AfterID      *int   `json:"after_id,omitempty"`
+	NeighborType string `json:"neighbor_type,omitempty"` // "account" (default) or "group"
+	To           string `json:"to,omitempty"`
+}
+
+// ForecastResponse is a linear-trend projection of an account's balance
+type ForecastResponse struct {
+	AccountID        int      `json:"account_id"`
+	HorizonDays      int      `json:"horizon_days"`
+	CurrentBalance   float64  `json:"current_balance"`
+	ProjectedBalance *float64 `json:"projected_balance"`
+	SlopePerDay      *float64 `json:"slope_per_day"`
+	RSquared         *float64 `json:"r_squared"`
+	Confidence       string   `json:"confidence"` // "high", "medium", "low", or "insufficient_data"
+	Points           int      `json:"points"`
+	Message          string   `json:"message,omitempty"`
+}
+
 type SetInstitutionRequest struct {
 	InstitutionID *int `json:"institution_id"`
 }
+
+type AddInstitutionRequest struct {
+	InstitutionID int `json:"institution_id"`
+}
+
+// InstitutionTotal is an institution's total balance after a membership change
+type InstitutionTotal struct {
+	ID           int     `json:"id"`
+	TotalBalance float64 `json:"total_balance"`
+}
+
+// SetInstitutionResponse is returned after moving an account between institutions
+type SetInstitutionResponse struct {
+	Account             *Account          `json:"account"`
+	PreviousInstitution *InstitutionTotal `json:"previous_institution,omitempty"`
+	Institution         *InstitutionTotal `json:"institution,omitempty"`
+}
+
+// BulkArchiveRequest archives several accounts in one call
+type BulkArchiveRequest struct {
+	IDs   []int `json:"ids"`
+	Force bool  `json:"force"` // archive even if calculated entities depend on the account
+}
+
+// BulkArchiveSkipped is an account a bulk archive left alone, and why
+type BulkArchiveSkipped struct {
+	ID     int    `json:"id"`
+	Reason string `json:"reason"`
+}
+
+// BulkArchiveResponse lists the accounts archived and those skipped
+type BulkArchiveResponse struct {
+	Accounts []Account            `json:"accounts"`
+	Skipped  []BulkArchiveSkipped `json:"skipped"`
+}
+
+// EntityReference is an entity that uses an account, and how it uses it
+type EntityReference struct {
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+	Via  string `json:"via"` // "membership", "formula", or "item"
+}
+
+// AccountReferences lists everything that depends on an account, grouped by type
+type AccountReferences struct {
+	Groups             []EntityReference `json:"groups"`
+	Institutions       []EntityReference `json:"institutions"`
+	Dashboards         []EntityReference `json:"dashboards"`
+	CalculatedAccounts []EntityReference `json:"calculated_accounts"`
+}
+
+// AccountListResponse is one page of accounts
+type AccountListResponse struct {
+	Accounts []Account `json:"accounts"`
+	Total    int       `json:"total"`
+	Page     int       `json:"page"`
+	PageSize int       `json:"page_size"`
+}
+
+// NetWorthPoint represents the total balance across all accounts at a point in time
+type NetWorthPoint struct {
+	Date  time.Time `json:"date"`
+	Total float64   `json:"total"`
+}
+
+// TotalExcludingResponse compares the grand total of active accounts with the total
+// when some accounts or groups are treated as absent
+type TotalExcludingResponse struct {
+	Total         float64 `json:"total"`
+	AdjustedTotal float64 `json:"adjusted_total"`
+	Difference    float64 `json:"difference"` // Total - AdjustedTotal: what the excluded entities contribute
+}
+
+// PeriodChange is an entity's balance change over a report period, using the latest
+// recorded balance at or before each end. A missing balance counts as 0.
+type PeriodChange struct {
+	EntityType    string   `json:"entity_type"` // "account", "group", or "institution"
+	ID            int      `json:"id"`
+	Name          string   `json:"name"`
+	FromBalance   *float64 `json:"from_balance"`
+	ToBalance     *float64 `json:"to_balance"`
+	Change        float64  `json:"change"`
+	PercentChange *float64 `json:"percent_change"` // null when the from balance is missing or zero
+}
+
+// PeriodReport compares the portfolio between two dates. Totals cover active accounts.
+type PeriodReport struct {
+	From          time.Time      `json:"from"`
+	To            time.Time      `json:"to"`
+	FromTotal     float64        `json:"from_total"`
+	ToTotal       float64        `json:"to_total"`
+	Change        float64        `json:"change"`
+	PercentChange *float64       `json:"percent_change"`
+	Groups        []PeriodChange `json:"groups"`
+	Institutions  []PeriodChange `json:"institutions"`
+	Accounts      []PeriodChange `json:"accounts"`
+	TopMovers     []PeriodChange `json:"top_movers"` // Accounts with the largest absolute change
+}
@@ -2,37 +2,109 @@ package models
 
 import "time"
 
+// Formula operations, applied left-to-right to the running total
+const (
+	FormulaOpAdd      = "add"
+	FormulaOpSubtract = "subtract"
+	FormulaOpMultiply = "multiply"
+	FormulaOpDivide   = "divide"
+)
+
 type FormulaItem struct {
 	AccountID   int     `json:"account_id"`
 	Coefficient float64 `json:"coefficient"`
+	Operation   string  `json:"operation,omitempty"` // "add" (default), "subtract", "multiply", or "divide"
 }
 
 type AccountGroup struct {
-	ID           int           `json:"id"`
-	Name         string        `json:"name"`
-	Description  string        `json:"description"`
-	Color        string        `json:"color"`
-	Position     int           `json:"position"`
-	IsArchived   bool          `json:"is_archived"`
-	IsCalculated bool          `json:"is_calculated"`
-	Formula      []FormulaItem `json:"formula,omitempty"`
-	EntityType   string        `json:"entity_type"`
-	CreatedAt    time.Time     `json:"created_at"`
-	UpdatedAt    time.Time     `json:"updated_at"`
+	ID            int           `json:"id"`
+	Name          string        `json:"name"`
+	Description   string        `json:"description"`
+	Color         string        `json:"color"`
+	Position      int           `json:"position"`
+	IsArchived    bool          `json:"is_archived"`
+	IsCalculated  bool          `json:"is_calculated"`
+	Formula       []FormulaItem `json:"formula,omitempty"`
+	EntityType    string        `json:"entity_type"`
+	ParentGroupID *int          `json:"parent_group_id"` // Only groups can be nested
+	CreatedAt     time.Time     `json:"created_at"`
+	UpdatedAt     time.Time     `json:"updated_at"`
 }
 
 type AccountGroupWithAccounts struct {
 	AccountGroup
-	TotalBalance float64          `json:"total_balance"`
-	Accounts     []AccountInGroup `json:"accounts"`
+	TotalBalance      float64                    `json:"total_balance"`
+	DivisionByZero    bool                       `json:"division_by_zero,omitempty"`   // Set when a formula divided by zero
+	MissingReferences bool                       `json:"missing_references,omitempty"` // Set when formula terms for deleted or archived accounts were skipped
+	Accounts          []AccountInGroup           `json:"accounts"`
+	ChildGroups       []AccountGroupWithAccounts `json:"child_groups,omitempty"`
 }
 
 type CreateGroupRequest struct {
-	Name         string        `json:"name"`
-	Description  string        `json:"description"`
-	Color        string        `json:"color"`
-	IsCalculated bool          `json:"is_calculated"`
-	Formula      []FormulaItem `json:"formula,omitempty"`
+	Name          string        `json:"name"`
+	Description   string        `json:"description"`
+	Color         string        `json:"color"`
+	IsCalculated  bool          `json:"is_calculated"`
+	Formula       []FormulaItem `json:"formula,omitempty"`
+	ParentGroupID *int          `json:"parent_group_id,omitempty"`
+}
+
+// DuplicateGroupRequest controls what is copied when duplicating a group
+type DuplicateGroupRequest struct {
+	CopyMemberships bool `json:"copy_memberships"`
+}
+
+// SetGroupTargetRequest sets a group's target share of the total (0-100), or clears it when nil
+type SetGroupTargetRequest struct {
+	TargetPercentage *float64 `json:"target_percentage"`
+}
+
+type GroupTargetResponse struct {
+	GroupID          int      `json:"group_id"`
+	TargetPercentage *float64 `json:"target_percentage"`
+}
+
+// GroupAllocationDrift compares a top-level group's share of the total with its target.
+// RebalanceAmount is how much to move into (positive) or out of (negative) the group.
+type GroupAllocationDrift struct {
+	GroupID           int      `json:"group_id"`
+	Name              string   `json:"name"`
+	Balance           float64  `json:"balance"`
+	CurrentPercentage float64  `json:"current_percentage"`
+	TargetPercentage  *float64 `json:"target_percentage"`
+	DriftPercentage   *float64 `json:"drift_percentage"`
+	RebalanceAmount   *float64 `json:"rebalance_amount"`
+}
+
+type AllocationDriftResponse struct {
+	Total            float64                `json:"total"`
+	TargetPercentage float64                `json:"target_percentage"` // Sum of all group targets
+	Groups           []GroupAllocationDrift `json:"groups"`
+}
+
+// GroupMover is a member account's balance change over a period. An account with
+// no balance at one end (e.g. opened during the period) counts as 0 there.
+type GroupMover struct {
+	AccountID     int      `json:"account_id"`
+	AccountName   string   `json:"account_name"`
+	FromBalance   *float64 `json:"from_balance"`
+	ToBalance     *float64 `json:"to_balance"`
+	Change        float64  `json:"change"`
+	PercentChange *float64 `json:"percent_change"` // null when the from balance is missing or zero
+}
+
+// GroupMoversResponse ranks a group's member accounts by balance change
+type GroupMoversResponse struct {
+	GroupID int          `json:"group_id"`
+	From    time.Time    `json:"from"`
+	To      time.Time    `json:"to"`
+	SortBy  string       `json:"sort_by"` // absolute or percentage
+	Movers  []GroupMover `json:"movers"`
+}
+
+// SetGroupParentRequest moves a group under another group, or to the top level when nil
+type SetGroupParentRequest struct {
+	ParentGroupID *int `json:"parent_group_id"`
 }
 
 type UpdateGroupRequest struct {
@@ -88,6 +160,20 @@ type ListItem struct {
 	Institution *AccountGroupWithAccounts `json:"institution,omitempty"`
 }
 
+// AccountSummary is a slim account view for navigation lists
+type AccountSummary struct {
+	ID             int     `json:"id"`
+	AccountName    string  `json:"account_name"`
+	CurrentBalance float64 `json:"current_balance"`
+}
+
+// InstitutionAccountsMap maps institution IDs to their member accounts,
+// plus accounts not assigned to any institution
+type InstitutionAccountsMap struct {
+	Institutions map[int][]AccountSummary `json:"institutions"`
+	Unassigned   []AccountSummary         `json:"unassigned"`
+}
+
 type GroupedAccountsResponse struct {
 	Items        []ListItem `json:"items"`
 	TotalBalance float64    `json:"total_balance"`
@@ -5,7 +5,7 @@ import "time"
 // DashboardFormulaItem represents an item in a dashboard formula (account, group, or institution)
 type DashboardFormulaItem struct {
 	ID          int     `json:"id"`
-	Type        string  `json:"type"` // "account", "group", or "institution"
+	Type        string  `json:"type"` // "account", "group", "institution", or "dashboard"
 	Coefficient float64 `json:"coefficient"`
 }
 
@@ -73,5 +73,36 @@ type UpdateDashboardItemPositionsRequest struct {
 	Positions []DashboardItemPosition `json:"positions"`
 }
 
+type DashboardPosition struct {
+	ID       int `json:"id"`
+	Position int `json:"position"`
+}
+
+type UpdateDashboardPositionsRequest struct {
+	Positions []DashboardPosition `json:"positions"`
+}
+
+// DashboardItemComparison is one dashboard item's balance at two points in time.
+// A side is null when the item has no history at or before that time.
+type DashboardItemComparison struct {
+	ItemType    string   `json:"item_type"`
+	ItemID      int      `json:"item_id"`
+	Name        string   `json:"name"`
+	FromBalance *float64 `json:"from_balance"`
+	ToBalance   *float64 `json:"to_balance"`
+	Delta       *float64 `json:"delta"`
+}
+
+// DashboardComparison compares a dashboard's items and total between two points in time
+type DashboardComparison struct {
+	DashboardID int                       `json:"dashboard_id"`
+	From        time.Time                 `json:"from"`
+	To          time.Time                 `json:"to"`
+	Items       []DashboardItemComparison `json:"items"`
+	FromTotal   *float64                  `json:"from_total"`
+	ToTotal     *float64                  `json:"to_total"`
+	TotalDelta  *float64                  `json:"total_delta"`
+}
+
 // DashboardBalanceHistory is an alias for EntityBalanceHistory for backward compatibility
 type DashboardBalanceHistory = EntityBalanceHistory
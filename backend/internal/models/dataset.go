@@ -3,23 +3,111 @@ package models
 import "time"
 
 type Dataset struct {
-	ID             int        `json:"id"`
-	Name           string     `json:"name"`
-	Description    string     `json:"description"`
-	FolderPath     string     `json:"folder_path"`
-	RowCount       int        `json:"row_count"`
-	Status         string     `json:"status"` // pending, syncing, ready, error
-	ErrorMessage   string     `json:"error_message,omitempty"`
-	LastCommitHash string     `json:"last_commit_hash,omitempty"`
-	LastSyncedAt   *time.Time `json:"last_synced_at,omitempty"`
-	CreatedAt      time.Time  `json:"created_at"`
-	UpdatedAt      time.Time  `json:"updated_at"`
+	ID                      int             `json:"id"`
+	Name                    string          `json:"name"`
+	Description             string          `json:"description"`
+	FolderPath              string          `json:"folder_path"`
+	RowCount                int             `json:"row_count"`
+	Status                  string          `json:"status"` // pending, syncing, ready, error
+	ErrorMessage            string          `json:"error_message,omitempty"`
+	LastCommitHash          string          `json:"last_commit_hash,omitempty"`
+	LastSyncedAt            *time.Time      `json:"last_synced_at,omitempty"`
+	AutoSync                bool            `json:"auto_sync"`
+	AutoSyncIntervalSeconds int             `json:"auto_sync_interval_seconds"`
+	CombineMode             string          `json:"combine_mode"` // append or join
+	JoinKey                 string          `json:"join_key,omitempty"`
+	SkipRows                *int            `json:"skip_rows"` // Records above each file's header; null detects title lines
+	Delimiter               *string         `json:"delimiter"` // Field delimiter; null detects it
+	Dedupe                  bool            `json:"dedupe"`
+	DedupeKeys              []string        `json:"dedupe_keys"`        // Empty compares every column
+	DuplicatesDropped       int             `json:"duplicates_dropped"` // Rows dropped as duplicates by the last sync
+	DerivedColumns          []DerivedColumn `json:"derived_columns"`
+	CreatedAt               time.Time       `json:"created_at"`
+	UpdatedAt               time.Time       `json:"updated_at"`
+
+	// ColumnDescriptions maps column name to its description (detail view only)
+	ColumnDescriptions map[string]string `json:"column_descriptions,omitempty"`
 }
 
 type CreateDatasetRequest struct {
-	Name        string `json:"name"`
+	Name                    string   `json:"name"`
+	Description             string   `json:"description"`
+	FolderPath              string   `json:"folder_path"`
+	AutoSync                *bool    `json:"auto_sync,omitempty"`
+	AutoSyncIntervalSeconds *int     `json:"auto_sync_interval_seconds,omitempty"`
+	CombineMode             string   `json:"combine_mode,omitempty"` // Defaults to append
+	JoinKey                 string   `json:"join_key,omitempty"`     // Required in join mode
+	SkipRows                *int     `json:"skip_rows,omitempty"`    // Defaults to detecting title lines
+	Delimiter               *string  `json:"delimiter,omitempty"`    // Defaults to detecting the delimiter
+	Dedupe                  bool     `json:"dedupe,omitempty"`
+	DedupeKeys              []string `json:"dedupe_keys,omitempty"`
+}
+
+// UpdateDedupeRequest configures dropping duplicate rows when the dataset syncs
+type UpdateDedupeRequest struct {
+	Dedupe     bool     `json:"dedupe"`
+	DedupeKeys []string `json:"dedupe_keys"` // Source columns compared; empty compares every column
+}
+
+// DerivedColumn is a column computed from an arithmetic expression over other
+// columns, e.g. {"name": "total", "expression": "price * quantity"}. Column names
+// with spaces or symbols are double-quoted: "\"Unit Price\" * 2".
+type DerivedColumn struct {
+	Name       string `json:"name"`
+	Expression string `json:"expression"`
+}
+
+// UpdateDerivedColumnsRequest replaces a dataset's derived columns, applied in order
+// after the transformation pipeline when the dataset syncs
+type UpdateDerivedColumnsRequest struct {
+	DerivedColumns []DerivedColumn `json:"derived_columns"`
+}
+
+// UpdateCombineModeRequest changes how a dataset combines the CSV files in its folder
+type UpdateCombineModeRequest struct {
+	CombineMode string `json:"combine_mode"`
+	JoinKey     string `json:"join_key,omitempty"`
+}
+
+// UpdateSkipRowsRequest sets how many records precede each file's header; null
+// restores automatic detection of title lines
+type UpdateSkipRowsRequest struct {
+	SkipRows *int `json:"skip_rows"`
+}
+
+// UpdateDelimiterRequest sets the field delimiter of the dataset's CSV files (",",
+// ";", tab or "|"); null restores automatic detection
+type UpdateDelimiterRequest struct {
+	Delimiter *string `json:"delimiter"`
+}
+
+// UpdateColumnDescriptionRequest sets a column's description; empty clears it
+type UpdateColumnDescriptionRequest struct {
+	Description string `json:"description"`
+}
+
+// DatasetColumnDescription is a column's stored description
+type DatasetColumnDescription struct {
+	Column      string `json:"column"`
 	Description string `json:"description"`
-	FolderPath  string `json:"folder_path"`
+}
+
+// DatasetColumnSetting sets a column's label; its index in the request sets its position
+type DatasetColumnSetting struct {
+	Name        string `json:"name"`                   // Physical column name
+	DisplayName string `json:"display_name,omitempty"` // Empty shows the physical name
+}
+
+// UpdateColumnSettingsRequest renames and reorders dataset columns. Columns not
+// listed keep their physical name and follow the listed ones.
+type UpdateColumnSettingsRequest struct {
+	Columns []DatasetColumnSetting `json:"columns"`
+}
+
+// UpdateAutoSyncRequest configures whether reads trigger a background sync
+type UpdateAutoSyncRequest struct {
+	AutoSync                bool `json:"auto_sync"`
+	AutoSyncIntervalSeconds int  `json:"auto_sync_interval_seconds"`
 }
 
 type DatasetListResponse struct {
@@ -29,11 +117,170 @@ type DatasetListResponse struct {
 	PageSize int       `json:"page_size"`
 }
 
+// DatasetSyncOverview summarizes the sync health of a folder-backed dataset
+type DatasetSyncOverview struct {
+	ID                   int        `json:"id"`
+	Name                 string     `json:"name"`
+	FolderPath           string     `json:"folder_path"`
+	Status               string     `json:"status"`
+	ErrorMessage         string     `json:"error_message,omitempty"`
+	LastCommitHash       string     `json:"last_commit_hash,omitempty"`
+	LastSyncedAt         *time.Time `json:"last_synced_at,omitempty"`
+	SecondsSinceLastSync *int64     `json:"seconds_since_last_sync"` // nil if never synced
+	Syncing              bool       `json:"syncing"`
+	HasChanges           bool       `json:"has_changes"`                  // True if NeedsSync reports changes
+	ChangeCheckError     string     `json:"change_check_error,omitempty"` // Set if the change check failed
+}
+
+// DatasetFailure describes a dataset whose most recent sync failed
+type DatasetFailure struct {
+	ID             int        `json:"id"`
+	Name           string     `json:"name"`
+	FolderPath     string     `json:"folder_path"`
+	ErrorMessage   string     `json:"error_message"`
+	FailedAt       time.Time  `json:"failed_at"`                // When the failing sync was attempted
+	LastSyncedAt   *time.Time `json:"last_synced_at,omitempty"` // Last successful sync, if any
+	LastCommitHash string     `json:"last_commit_hash,omitempty"`
+}
+
 type DatasetDataResponse struct {
-	Columns  []string `json:"columns"`
-	Rows     [][]any  `json:"rows"`
-	Total    int      `json:"total"`
-	Page     int      `json:"page"`
-	PageSize int      `json:"page_size"`
-	Syncing  bool     `json:"syncing"` // True if data is currently being synced
+	Columns      []string `json:"columns"`
+	DisplayNames []string `json:"display_names,omitempty"` // Labels for Columns, in the same order
+	Rows         [][]any  `json:"rows"`
+	Total        int      `json:"total"`
+	Page         int      `json:"page"`
+	PageSize     int      `json:"page_size"`
+	Syncing      bool     `json:"syncing"` // True if data is currently being synced
+}
+
+// ReplaceNulls substitutes value for every NULL cell. By default NULLs are
+// returned as JSON null; callers opt into a display value (e.g. "" or "-").
+func (d *DatasetDataResponse) ReplaceNulls(value string) {
+	for _, row := range d.Rows {
+		ReplaceNullCells(row, value)
+	}
+}
+
+// ReplaceNullCells substitutes value for the NULL cells of one row. Paged reads and
+// exports both use it so they represent missing values the same way.
+func ReplaceNullCells(row []any, value string) {
+	for i, v := range row {
+		if v == nil {
+			row[i] = value
+		}
+	}
+}
+
+// Filter operators supported by DataFilter and by filter transformations
+const (
+	FilterEq       = "eq"
+	FilterNeq      = "neq"
+	FilterContains = "contains"
+	FilterGt       = "gt"
+	FilterGte      = "gte"
+	FilterLt       = "lt"
+	FilterLte      = "lte"
+)
+
+// DataFilter restricts the rows of a paged data read. The zero value matches every row.
+type DataFilter struct {
+	Column string // Column compared against Value with Op; empty disables the column filter
+	Op     string // One of the Filter* operators; defaults to FilterEq
+	Value  string
+	Query  string // Case-insensitive text searched for in every column
+}
+
+// DatasetColumnOptions describes a column's inferred type and the aggregations valid for it
+type DatasetColumnOptions struct {
+	Name      string   `json:"name"`
+	Type      string   `json:"type"` // "numeric", "date", or "text"
+	Operators []string `json:"operators"`
+
+	Description string `json:"description,omitempty"`
+}
+
+// DatasetDateRange is the span of dates in a date column; Min and Max are
+// YYYY-MM-DD, or null if the column has no valid dates
+type DatasetDateRange struct {
+	Column string  `json:"column"`
+	Min    *string `json:"min"`
+	Max    *string `json:"max"`
+}
+
+// DatasetColumnStats summarizes one column. Empty values count as nulls and are
+// excluded from the other figures. Numeric columns report min/max/avg/sum (null
+// when every value is empty); other columns report distinct and top values.
+type DatasetColumnStats struct {
+	Name          string              `json:"name"`
+	Type          string              `json:"type"` // numeric, date, or text
+	NullCount     int                 `json:"null_count"`
+	Min           *float64            `json:"min,omitempty"`
+	Max           *float64            `json:"max,omitempty"`
+	Avg           *float64            `json:"avg,omitempty"`
+	Sum           *float64            `json:"sum,omitempty"`
+	DistinctCount *int                `json:"distinct_count,omitempty"`
+	TopValues     []DatasetValueCount `json:"top_values,omitempty"`
+}
+
+// DatasetValueCount is a column value and how many rows hold it
+type DatasetValueCount struct {
+	Value string `json:"value"`
+	Count int    `json:"count"`
+}
+
+// DatasetStatsResponse is the data-quality summary of a ready dataset
+type DatasetStatsResponse struct {
+	DatasetID int                  `json:"dataset_id"`
+	RowCount  int                  `json:"row_count"`
+	Columns   []DatasetColumnStats `json:"columns"`
+}
+
+type AggregationOptionsResponse struct {
+	Columns []DatasetColumnOptions `json:"columns"`
+}
+
+// Transformation operations
+const (
+	TransformRename  = "rename"  // Rename Column to To
+	TransformCompute = "compute" // Add Column = Left Op Right
+	TransformFilter  = "filter"  // Keep rows where Column Op Value
+	TransformDedup   = "dedup"   // Drop rows repeating an earlier row's Columns
+)
+
+// DatasetTransformation is one step of a dataset's transformation pipeline. Steps
+// are replayed in position order on the folder data at every sync.
+type DatasetTransformation struct {
+	ID        int       `json:"id"`
+	DatasetID int       `json:"dataset_id"`
+	Position  int       `json:"position"`
+	Operation string    `json:"operation"`
+	Column    string    `json:"column,omitempty"`  // rename/filter: target column; compute: new column
+	To        string    `json:"to,omitempty"`      // rename: new name
+	Op        string    `json:"op,omitempty"`      // filter: eq, neq, contains, gt, gte, lt, lte; compute: add, subtract, multiply, divide, concat
+	Value     string    `json:"value,omitempty"`   // filter: value compared against
+	Left      string    `json:"left,omitempty"`    // compute: left operand column
+	Right     string    `json:"right,omitempty"`   // compute: right operand column
+	Columns   []string  `json:"columns,omitempty"` // dedup: columns compared; empty compares all
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// CreateTransformationRequest appends a step to a dataset's pipeline
+type CreateTransformationRequest struct {
+	Operation string   `json:"operation"`
+	Column    string   `json:"column,omitempty"`
+	To        string   `json:"to,omitempty"`
+	Op        string   `json:"op,omitempty"`
+	Value     string   `json:"value,omitempty"`
+	Left      string   `json:"left,omitempty"`
+	Right     string   `json:"right,omitempty"`
+	Columns   []string `json:"columns,omitempty"`
+}
+
+type TransformationPosition struct {
+	ID       int `json:"id"`
+	Position int `json:"position"`
+}
+
+type UpdateTransformationPositionsRequest struct {
+	Positions []TransformationPosition `json:"positions"`
 }
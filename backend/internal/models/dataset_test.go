@@ -0,0 +1,44 @@
+package models
+
+import (
+	"reflect"
+	"testing"
+)
+
+func nullRows() [][]any {
+	return [][]any{
+		{"a", nil, ""},
+		{nil, "b", nil},
+	}
+}
+
+// The paged data path (ReplaceNulls) and the export path (ReplaceNullCells per
+// streamed row) must treat NULL cells the same way
+func TestNullHandlingMatchesOnPagedAndExportPaths(t *testing.T) {
+	for _, value := range []string{"", "-", "N/A"} {
+		paged := &DatasetDataResponse{Rows: nullRows()}
+		paged.ReplaceNulls(value)
+
+		exported := nullRows()
+		for _, row := range exported {
+			ReplaceNullCells(row, value)
+		}
+
+		if !reflect.DeepEqual(paged.Rows, exported) {
+			t.Errorf("null_value %q: paged rows %#v differ from exported rows %#v", value, paged.Rows, exported)
+		}
+		want := [][]any{{"a", value, ""}, {value, "b", value}}
+		if !reflect.DeepEqual(paged.Rows, want) {
+			t.Errorf("null_value %q: rows = %#v, want %#v", value, paged.Rows, want)
+		}
+	}
+}
+
+// An empty string cell is a value, not a NULL, and is never replaced
+func TestReplaceNullCellsKeepsEmptyStrings(t *testing.T) {
+	row := []any{"", nil}
+	ReplaceNullCells(row, "-")
+	if want := []any{"", "-"}; !reflect.DeepEqual(row, want) {
+		t.Errorf("row = %#v, want %#v", row, want)
+	}
+}
@@ -0,0 +1,57 @@
+package models
+
+import "time"
+
+// DownsampleHistoryRequest configures a balance history downsampling run
+type DownsampleHistoryRequest struct {
+	OlderThan string `json:"older_than"` // YYYY-MM-DD or RFC3339
+	Keep      string `json:"keep"`       // "day" (default) or "week"
+}
+
+type DownsampleHistoryResponse struct {
+	Deleted int64 `json:"deleted"`
+}
+
+// PositionIssue describes an entity whose position is duplicated or out of sequence
+// within its ordering scope
+type PositionIssue struct {
+	Scope            string `json:"scope"`              // "main_list", "institutions", "group", or "institution"
+	ScopeID          *int   `json:"scope_id,omitempty"` // Group or institution ID for membership scopes
+	EntityType       string `json:"entity_type"`        // "account", "group", or "institution"
+	EntityID         int    `json:"entity_id"`
+	Name             string `json:"name"`
+	Position         int    `json:"position"`
+	ExpectedPosition int    `json:"expected_position"`
+	Problem          string `json:"problem"` // "duplicate" or "gap"
+}
+
+type PositionHealthResponse struct {
+	Healthy bool            `json:"healthy"`
+	Issues  []PositionIssue `json:"issues"`
+}
+
+type FixPositionsResponse struct {
+	Fixed []PositionIssue `json:"fixed"`
+}
+
+// EntityHistoryStats is the balance history volume for one entity. Rows for regular
+// accounts are written directly by balance updates; rows for calculated accounts,
+// groups, institutions and dashboards are written by propagation.
+type EntityHistoryStats struct {
+	EntityType      string    `json:"entity_type"`
+	EntityID        int       `json:"entity_id"`
+	Name            string    `json:"name"`
+	Rows            int64     `json:"rows"`
+	DirectRows      int64     `json:"direct_rows"`
+	PropagatedRows  int64     `json:"propagated_rows"`
+	FirstRecordedAt time.Time `json:"first_recorded_at"`
+	LastRecordedAt  time.Time `json:"last_recorded_at"`
+}
+
+type HistoryStatsResponse struct {
+	TotalRows      int64                `json:"total_rows"`
+	DirectRows     int64                `json:"direct_rows"`
+	PropagatedRows int64                `json:"propagated_rows"`
+	RowsByType     map[string]int64     `json:"rows_by_type"`
+	Entities       []EntityHistoryStats `json:"entities"`
+}
@@ -0,0 +1,13 @@
+package models
+
+type AddTagRequest struct {
+	Name string `json:"name"`
+}
+
+// TagTotal is the combined balance of the non-archived accounts carrying a tag
+type TagTotal struct {
+	Tag          string  `json:"tag"`
+	Total        float64 `json:"total"`
+	AccountCount int     `json:"account_count"`
+	AccountIDs   []int   `json:"account_ids"`
+}
@@ -4,8 +4,12 @@ import (
 	"database/sql"
 	"encoding/json"
 	"fmt"
+	"math"
+	"sort"
+	"time"
 
 	"finance-tracker/internal/models"
+	"finance-tracker/internal/validation"
 )
 
 type AccountGroupRepository struct {
@@ -24,7 +28,7 @@ func (r *AccountGroupRepository) GetAll() ([]models.AccountGroup, error) {
 
 func (r *AccountGroupRepository) GetAllByType(entityType string) ([]models.AccountGroup, error) {
 	query := `
-		SELECT id, name, description, color, position, is_archived, is_calculated, formula, entity_type, created_at, updated_at
+		SELECT id, name, description, color, position, is_archived, is_calculated, formula, entity_type, parent_group_id, created_at, updated_at
 		FROM account_groups
 		WHERE is_archived = false AND entity_type = $1
 		ORDER BY position ASC, name ASC
@@ -39,7 +43,7 @@ func (r *AccountGroupRepository) GetAllByType(entityType string) ([]models.Accou
 	for rows.Next() {
 		var g models.AccountGroup
 		var formulaJSON []byte
-		err := rows.Scan(&g.ID, &g.Name, &g.Description, &g.Color, &g.Position, &g.IsArchived, &g.IsCalculated, &formulaJSON, &g.EntityType, &g.CreatedAt, &g.UpdatedAt)
+		err := rows.Scan(&g.ID, &g.Name, &g.Description, &g.Color, &g.Position, &g.IsArchived, &g.IsCalculated, &formulaJSON, &g.EntityType, &g.ParentGroupID, &g.CreatedAt, &g.UpdatedAt)
 		if err != nil {
 			return nil, err
 		}
@@ -53,13 +57,13 @@ func (r *AccountGroupRepository) GetAllByType(entityType string) ([]models.Accou
 
 func (r *AccountGroupRepository) GetByID(id int) (*models.AccountGroup, error) {
 	query := `
-		SELECT id, name, description, color, position, is_archived, is_calculated, formula, entity_type, created_at, updated_at
+		SELECT id, name, description, color, position, is_archived, is_calculated, formula, entity_type, parent_group_id, created_at, updated_at
 		FROM account_groups
 		WHERE id = $1
 	`
 	var g models.AccountGroup
 	var formulaJSON []byte
-	err := r.db.QueryRow(query, id).Scan(&g.ID, &g.Name, &g.Description, &g.Color, &g.Position, &g.IsArchived, &g.IsCalculated, &formulaJSON, &g.EntityType, &g.CreatedAt, &g.UpdatedAt)
+	err := r.db.QueryRow(query, id).Scan(&g.ID, &g.Name, &g.Description, &g.Color, &g.Position, &g.IsArchived, &g.IsCalculated, &formulaJSON, &g.EntityType, &g.ParentGroupID, &g.CreatedAt, &g.UpdatedAt)
 	if err != nil {
 		return nil, err
 	}
@@ -77,7 +81,7 @@ func (r *AccountGroupRepository) GetWithAccounts(id int) (*models.AccountGroupWi
 
 	// Get ALL accounts to resolve formula dependencies
 	allAccountsQuery := `
-		SELECT id, account_name, account_info, current_balance, is_archived, position, is_calculated, formula, created_at, updated_at
+		SELECT id, account_name, account_info, current_balance, is_archived, position, is_calculated, formula, COALESCE(account_number, ''), created_at, updated_at
 		FROM account_balances
 		WHERE is_archived = false
 	`
@@ -91,13 +95,15 @@ func (r *AccountGroupRepository) GetWithAccounts(id int) (*models.AccountGroupWi
 	for allRows.Next() {
 		var a models.Account
 		var formulaJSON []byte
-		err := allRows.Scan(&a.ID, &a.AccountName, &a.AccountInfo, &a.CurrentBalance, &a.IsArchived, &a.Position, &a.IsCalculated, &formulaJSON, &a.CreatedAt, &a.UpdatedAt)
+		var accountNumber string
+		err := allRows.Scan(&a.ID, &a.AccountName, &a.AccountInfo, &a.CurrentBalance, &a.IsArchived, &a.Position, &a.IsCalculated, &formulaJSON, &accountNumber, &a.CreatedAt, &a.UpdatedAt)
 		if err != nil {
 			return nil, err
 		}
 		if len(formulaJSON) > 0 {
 			json.Unmarshal(formulaJSON, &a.Formula)
 		}
+		a.MaskedAccountNumber = MaskAccountNumber(accountNumber)
 		a.GroupIDs = []int{}
 		allAccounts = append(allAccounts, a)
 	}
@@ -144,13 +150,10 @@ func (r *AccountGroupRepository) GetWithAccounts(id int) (*models.AccountGroupWi
 
 	// Calculate total balance
 	var totalBalance float64
+	var divisionByZero, missingRefs bool
 	if group.IsCalculated && len(group.Formula) > 0 {
 		// Calculate formula-based balance using resolved account values
-		for _, item := range group.Formula {
-			if acc, ok := accountMap[item.AccountID]; ok {
-				totalBalance += item.Coefficient * acc.CurrentBalance
-			}
-		}
+		totalBalance, divisionByZero, missingRefs = calculateFormulaBalance(group.Formula, formulaBalances(group.Formula, accountMap))
 	} else {
 		// Default: sum all account balances in this group
 		for _, a := range accounts {
@@ -158,11 +161,35 @@ func (r *AccountGroupRepository) GetWithAccounts(id int) (*models.AccountGroupWi
 		}
 	}
 
-	return &models.AccountGroupWithAccounts{
-		AccountGroup: *group,
-		TotalBalance: totalBalance,
-		Accounts:     accounts,
-	}, nil
+	result := &models.AccountGroupWithAccounts{
+		AccountGroup:      *group,
+		TotalBalance:      totalBalance,
+		DivisionByZero:    divisionByZero,
+		MissingReferences: missingRefs,
+		Accounts:          accounts,
+	}
+
+	// Nested groups need every group's total, so resolve the whole hierarchy
+	var hasChildren bool
+	err = r.db.QueryRow("SELECT EXISTS(SELECT 1 FROM account_groups WHERE parent_group_id = $1 AND is_archived = false)", id).Scan(&hasChildren)
+	if err != nil {
+		return nil, err
+	}
+	if hasChildren {
+		groups, err := r.GetAllWithAccountsByType(group.EntityType)
+		if err != nil {
+			return nil, err
+		}
+		for _, g := range groups {
+			if g.ID == id {
+				result.TotalBalance = g.TotalBalance
+				result.ChildGroups = g.ChildGroups
+				break
+			}
+		}
+	}
+
+	return result, nil
 }
 
 func (r *AccountGroupRepository) Create(req *models.CreateGroupRequest) (*models.AccountGroup, error) {
@@ -188,6 +215,17 @@ func (r *AccountGroupRepository) Create(req *models.CreateGroupRequest) (*models
 		color = "#3b82f6"
 	}
 
+	if req.ParentGroupID != nil {
+		var parentIsGroup bool
+		err = tx.QueryRow("SELECT EXISTS(SELECT 1 FROM account_groups WHERE id = $1 AND entity_type = 'group')", *req.ParentGroupID).Scan(&parentIsGroup)
+		if err != nil {
+			return nil, err
+		}
+		if !parentIsGroup {
+			return nil, &ValidationError{Message: "Parent group not found"}
+		}
+	}
+
 	var formulaJSON interface{}
 	if req.IsCalculated && len(req.Formula) > 0 {
 		formulaJSON, err = json.Marshal(req.Formula)
@@ -197,14 +235,14 @@ func (r *AccountGroupRepository) Create(req *models.CreateGroupRequest) (*models
 	}
 
 	query := `
-		INSERT INTO account_groups (name, description, color, position, is_calculated, formula, entity_type)
-		VALUES ($1, $2, $3, $4, $5, $6, 'group')
-		RETURNING id, name, description, color, position, is_archived, is_calculated, formula, entity_type, created_at, updated_at
+		INSERT INTO account_groups (name, description, color, position, is_calculated, formula, entity_type, parent_group_id)
+		VALUES ($1, $2, $3, $4, $5, $6, 'group', $7)
+		RETURNING id, name, description, color, position, is_archived, is_calculated, formula, entity_type, parent_group_id, created_at, updated_at
 	`
 	var g models.AccountGroup
 	var returnedFormula []byte
-	err = tx.QueryRow(query, req.Name, req.Description, color, newPos, req.IsCalculated, formulaJSON).Scan(
-		&g.ID, &g.Name, &g.Description, &g.Color, &g.Position, &g.IsArchived, &g.IsCalculated, &returnedFormula, &g.EntityType, &g.CreatedAt, &g.UpdatedAt,
+	err = tx.QueryRow(query, req.Name, req.Description, color, newPos, req.IsCalculated, formulaJSON, req.ParentGroupID).Scan(
+		&g.ID, &g.Name, &g.Description, &g.Color, &g.Position, &g.IsArchived, &g.IsCalculated, &returnedFormula, &g.EntityType, &g.ParentGroupID, &g.CreatedAt, &g.UpdatedAt,
 	)
 	if err != nil {
 		return nil, err
@@ -233,12 +271,12 @@ func (r *AccountGroupRepository) Update(id int, req *models.UpdateGroupRequest)
 		UPDATE account_groups
 		SET name = $1, description = $2, color = $3, is_calculated = $4, formula = $5, updated_at = NOW()
 		WHERE id = $6
-		RETURNING id, name, description, color, position, is_archived, is_calculated, formula, entity_type, created_at, updated_at
+		RETURNING id, name, description, color, position, is_archived, is_calculated, formula, entity_type, parent_group_id, created_at, updated_at
 	`
 	var g models.AccountGroup
 	var returnedFormula []byte
 	err = r.db.QueryRow(query, req.Name, req.Description, req.Color, req.IsCalculated, formulaJSON, id).Scan(
-		&g.ID, &g.Name, &g.Description, &g.Color, &g.Position, &g.IsArchived, &g.IsCalculated, &returnedFormula, &g.EntityType, &g.CreatedAt, &g.UpdatedAt,
+		&g.ID, &g.Name, &g.Description, &g.Color, &g.Position, &g.IsArchived, &g.IsCalculated, &returnedFormula, &g.EntityType, &g.ParentGroupID, &g.CreatedAt, &g.UpdatedAt,
 	)
 	if err != nil {
 		return nil, err
@@ -249,17 +287,297 @@ func (r *AccountGroupRepository) Update(id int, req *models.UpdateGroupRequest)
 	return &g, nil
 }
 
+// Duplicate copies a group's color, description, formula and parent into a new group
+// at the end of the position order, optionally re-adding its account memberships.
+// Returns nil if the source group does not exist.
+func (r *AccountGroupRepository) Duplicate(id int, req *models.DuplicateGroupRequest) (*models.AccountGroupWithAccounts, error) {
+	source, err := r.GetByID(id)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	// Copying an institution's memberships would put its accounts in two institutions
+	if source.EntityType != "group" {
+		return nil, &ValidationError{Message: "Only groups can be duplicated"}
+	}
+
+	tx, err := r.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var maxPos sql.NullInt64
+	err = tx.QueryRow("SELECT MAX(position) FROM account_groups WHERE is_archived = false AND entity_type = $1", source.EntityType).Scan(&maxPos)
+	if err != nil {
+		return nil, err
+	}
+	newPos := 1
+	if maxPos.Valid {
+		newPos = int(maxPos.Int64) + 1
+	}
+
+	var formulaJSON interface{}
+	if source.IsCalculated && len(source.Formula) > 0 {
+		formulaJSON, err = json.Marshal(source.Formula)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var newID int
+	err = tx.QueryRow(`
+		INSERT INTO account_groups (name, description, color, position, is_calculated, formula, entity_type, parent_group_id)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		RETURNING id
+	`, source.Name+" (copy)", source.Description, source.Color, newPos, source.IsCalculated, formulaJSON, source.EntityType, source.ParentGroupID).Scan(&newID)
+	if err != nil {
+		return nil, err
+	}
+
+	if req.CopyMemberships {
+		_, err = tx.Exec(`
+			INSERT INTO account_group_memberships (account_id, group_id, position_in_group)
+			SELECT account_id, $1, position_in_group
+			FROM account_group_memberships
+			WHERE group_id = $2
+		`, newID, id)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return r.GetWithAccounts(newID)
+}
+
+// SetParent nests a group under another group, or makes it top-level when parentID is nil
+func (r *AccountGroupRepository) SetParent(id int, parentID *int) (*models.AccountGroup, error) {
+	group, err := r.GetByID(id)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if group.EntityType != "group" {
+		return nil, &ValidationError{Message: "Only groups can be nested"}
+	}
+
+	if parentID != nil {
+		parent, err := r.GetByID(*parentID)
+		if err == sql.ErrNoRows || (err == nil && parent.EntityType != "group") {
+			return nil, &ValidationError{Message: "Parent group not found"}
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		allGroups, err := r.GetAllIncludingArchivedByType("group")
+		if err != nil {
+			return nil, err
+		}
+		if err := validation.ValidateGroupParentForCycles(id, *parentID, allGroups); err != nil {
+			return nil, &ValidationError{Message: err.Error()}
+		}
+	}
+
+	query := `
+		UPDATE account_groups
+		SET parent_group_id = $1, updated_at = NOW()
+		WHERE id = $2
+		RETURNING id, name, description, color, position, is_archived, is_calculated, formula, entity_type, parent_group_id, created_at, updated_at
+	`
+	var g models.AccountGroup
+	var formulaJSON []byte
+	err = r.db.QueryRow(query, parentID, id).Scan(
+		&g.ID, &g.Name, &g.Description, &g.Color, &g.Position, &g.IsArchived, &g.IsCalculated, &formulaJSON, &g.EntityType, &g.ParentGroupID, &g.CreatedAt, &g.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	if len(formulaJSON) > 0 {
+		json.Unmarshal(formulaJSON, &g.Formula)
+	}
+	return &g, nil
+}
+
+// SetTargetPercentage sets or clears a group's target allocation percentage.
+// Returns false if the group does not exist.
+func (r *AccountGroupRepository) SetTargetPercentage(id int, target *float64) (bool, error) {
+	if target != nil && (*target < 0 || *target > 100) {
+		return false, &ValidationError{Message: "target_percentage must be between 0 and 100"}
+	}
+
+	result, err := r.db.Exec(`
+		UPDATE account_groups SET target_percentage = $1, updated_at = NOW()
+		WHERE id = $2 AND entity_type = 'group'
+	`, target, id)
+	if err != nil {
+		return false, err
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return rowsAffected > 0, nil
+}
+
+// GetAllocationDrift compares each top-level group's share of the combined top-level
+// group total with its target percentage. Nested groups are counted in their parent.
+func (r *AccountGroupRepository) GetAllocationDrift() (*models.AllocationDriftResponse, error) {
+	groups, err := r.GetAllWithAccountsByType("group")
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := r.db.Query("SELECT id, target_percentage FROM account_groups WHERE entity_type = 'group' AND target_percentage IS NOT NULL")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	targets := make(map[int]float64)
+	for rows.Next() {
+		var id int
+		var target float64
+		if err := rows.Scan(&id, &target); err != nil {
+			return nil, err
+		}
+		targets[id] = target
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	response := &models.AllocationDriftResponse{Groups: []models.GroupAllocationDrift{}}
+	for _, g := range groups {
+		if g.ParentGroupID == nil {
+			response.Total += g.TotalBalance
+		}
+	}
+
+	for _, g := range groups {
+		if g.ParentGroupID != nil {
+			continue
+		}
+		drift := models.GroupAllocationDrift{GroupID: g.ID, Name: g.Name, Balance: g.TotalBalance}
+		if response.Total != 0 {
+			drift.CurrentPercentage = g.TotalBalance / response.Total * 100
+		}
+		if target, ok := targets[g.ID]; ok {
+			response.TargetPercentage += target
+			difference := drift.CurrentPercentage - target
+			rebalance := target/100*response.Total - g.TotalBalance
+			drift.TargetPercentage = &target
+			drift.DriftPercentage = &difference
+			drift.RebalanceAmount = &rebalance
+		}
+		response.Groups = append(response.Groups, drift)
+	}
+	return response, nil
+}
+
+// GetMovers ranks the group's member accounts by how much their balance changed
+// between from and to, using the latest recorded balance at or before each time.
+// sortBy is "absolute" or "percentage"; accounts without a percentage change sort
+// last. Returns nil if the group does not exist.
+func (r *AccountGroupRepository) GetMovers(groupID int, from, to time.Time, sortBy string) (*models.GroupMoversResponse, error) {
+	group, err := r.GetByID(groupID)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if group == nil || group.EntityType != "group" {
+		return nil, nil
+	}
+
+	query := `
+		SELECT a.id, a.account_name,
+		       (SELECT h.balance FROM entity_balance_history h
+		        WHERE h.entity_type = 'account' AND h.entity_id = a.id AND h.recorded_at <= $2
+		        ORDER BY h.recorded_at DESC, h.id DESC LIMIT 1),
+		       (SELECT h.balance FROM entity_balance_history h
+		        WHERE h.entity_type = 'account' AND h.entity_id = a.id AND h.recorded_at <= $3
+		        ORDER BY h.recorded_at DESC, h.id DESC LIMIT 1)
+		FROM account_group_memberships m
+		JOIN account_balances a ON a.id = m.account_id
+		WHERE m.group_id = $1
+		ORDER BY m.position_in_group
+	`
+	rows, err := r.db.Query(query, groupID, from, to)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	movers := []models.GroupMover{}
+	for rows.Next() {
+		var m models.GroupMover
+		var fromBalance, toBalance sql.NullFloat64
+		if err := rows.Scan(&m.AccountID, &m.AccountName, &fromBalance, &toBalance); err != nil {
+			return nil, err
+		}
+		// Accounts with no balance at either end had no part in the period
+		if !fromBalance.Valid && !toBalance.Valid {
+			continue
+		}
+		if fromBalance.Valid {
+			m.FromBalance = &fromBalance.Float64
+		}
+		if toBalance.Valid {
+			m.ToBalance = &toBalance.Float64
+		}
+		m.Change = toBalance.Float64 - fromBalance.Float64
+		if fromBalance.Valid && fromBalance.Float64 != 0 {
+			percent := m.Change / math.Abs(fromBalance.Float64) * 100
+			m.PercentChange = &percent
+		}
+		movers = append(movers, m)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if sortBy == "percentage" {
+		sort.SliceStable(movers, func(i, j int) bool {
+			pi, pj := movers[i].PercentChange, movers[j].PercentChange
+			if pi == nil || pj == nil {
+				return pi != nil
+			}
+			return math.Abs(*pi) > math.Abs(*pj)
+		})
+	} else {
+		sort.SliceStable(movers, func(i, j int) bool {
+			return math.Abs(movers[i].Change) > math.Abs(movers[j].Change)
+		})
+	}
+
+	return &models.GroupMoversResponse{
+		GroupID: groupID,
+		From:    from,
+		To:      to,
+		SortBy:  sortBy,
+		Movers:  movers,
+	}, nil
+}
+
 func (r *AccountGroupRepository) Archive(id int) (*models.AccountGroup, error) {
 	query := `
 		UPDATE account_groups
 		SET is_archived = true, updated_at = NOW()
 		WHERE id = $1
-		RETURNING id, name, description, color, position, is_archived, is_calculated, formula, entity_type, created_at, updated_at
+		RETURNING id, name, description, color, position, is_archived, is_calculated, formula, entity_type, parent_group_id, created_at, updated_at
 	`
 	var g models.AccountGroup
 	var formulaJSON []byte
 	err := r.db.QueryRow(query, id).Scan(
-		&g.ID, &g.Name, &g.Description, &g.Color, &g.Position, &g.IsArchived, &g.IsCalculated, &formulaJSON, &g.EntityType, &g.CreatedAt, &g.UpdatedAt,
+		&g.ID, &g.Name, &g.Description, &g.Color, &g.Position, &g.IsArchived, &g.IsCalculated, &formulaJSON, &g.EntityType, &g.ParentGroupID, &g.CreatedAt, &g.UpdatedAt,
 	)
 	if err != nil {
 		return nil, err
@@ -317,7 +635,7 @@ func (r *AccountGroupRepository) GetAllIncludingArchived() ([]models.AccountGrou
 
 func (r *AccountGroupRepository) GetAllIncludingArchivedByType(entityType string) ([]models.AccountGroup, error) {
 	query := `
-		SELECT id, name, description, color, position, is_archived, is_calculated, formula, entity_type, created_at, updated_at
+		SELECT id, name, description, color, position, is_archived, is_calculated, formula, entity_type, parent_group_id, created_at, updated_at
 		FROM account_groups
 		WHERE entity_type = $1
 		ORDER BY name ASC
@@ -332,7 +650,7 @@ func (r *AccountGroupRepository) GetAllIncludingArchivedByType(entityType string
 	for rows.Next() {
 		var g models.AccountGroup
 		var formulaJSON []byte
-		err := rows.Scan(&g.ID, &g.Name, &g.Description, &g.Color, &g.Position, &g.IsArchived, &g.IsCalculated, &formulaJSON, &g.EntityType, &g.CreatedAt, &g.UpdatedAt)
+		err := rows.Scan(&g.ID, &g.Name, &g.Description, &g.Color, &g.Position, &g.IsArchived, &g.IsCalculated, &formulaJSON, &g.EntityType, &g.ParentGroupID, &g.CreatedAt, &g.UpdatedAt)
 		if err != nil {
 			return nil, err
 		}
@@ -349,12 +667,12 @@ func (r *AccountGroupRepository) Unarchive(id int) (*models.AccountGroup, error)
 		UPDATE account_groups
 		SET is_archived = false, updated_at = NOW()
 		WHERE id = $1
-		RETURNING id, name, description, color, position, is_archived, is_calculated, formula, entity_type, created_at, updated_at
+		RETURNING id, name, description, color, position, is_archived, is_calculated, formula, entity_type, parent_group_id, created_at, updated_at
 	`
 	var g models.AccountGroup
 	var formulaJSON []byte
 	err := r.db.QueryRow(query, id).Scan(
-		&g.ID, &g.Name, &g.Description, &g.Color, &g.Position, &g.IsArchived, &g.IsCalculated, &formulaJSON, &g.EntityType, &g.CreatedAt, &g.UpdatedAt,
+		&g.ID, &g.Name, &g.Description, &g.Color, &g.Position, &g.IsArchived, &g.IsCalculated, &formulaJSON, &g.EntityType, &g.ParentGroupID, &g.CreatedAt, &g.UpdatedAt,
 	)
 	if err != nil {
 		return nil, err
@@ -434,7 +752,7 @@ func (r *AccountGroupRepository) GetAllWithAccountsByType(entityType string) ([]
 
 	// Get all accounts with full details
 	allAccountsQuery := `
-		SELECT id, account_name, account_info, current_balance, is_archived, position, is_calculated, formula, created_at, updated_at
+		SELECT id, account_name, account_info, current_balance, is_archived, position, is_calculated, formula, COALESCE(account_number, ''), created_at, updated_at
 		FROM account_balances
 		WHERE is_archived = false
 	`
@@ -448,13 +766,15 @@ func (r *AccountGroupRepository) GetAllWithAccountsByType(entityType string) ([]
 	for accountRows.Next() {
 		var a models.Account
 		var formulaJSON []byte
-		err := accountRows.Scan(&a.ID, &a.AccountName, &a.AccountInfo, &a.CurrentBalance, &a.IsArchived, &a.Position, &a.IsCalculated, &formulaJSON, &a.CreatedAt, &a.UpdatedAt)
+		var accountNumber string
+		err := accountRows.Scan(&a.ID, &a.AccountName, &a.AccountInfo, &a.CurrentBalance, &a.IsArchived, &a.Position, &a.IsCalculated, &formulaJSON, &accountNumber, &a.CreatedAt, &a.UpdatedAt)
 		if err != nil {
 			return nil, err
 		}
 		if len(formulaJSON) > 0 {
 			json.Unmarshal(formulaJSON, &a.Formula)
 		}
+		a.MaskedAccountNumber = MaskAccountNumber(accountNumber)
 		a.GroupIDs = []int{}
 		allAccounts = append(allAccounts, a)
 	}
@@ -508,13 +828,10 @@ func (r *AccountGroupRepository) GetAllWithAccountsByType(entityType string) ([]
 	var result []models.AccountGroupWithAccounts
 	for _, group := range groups {
 		var totalBalance float64
+		var divisionByZero, missingRefs bool
 		if group.IsCalculated && len(group.Formula) > 0 {
 			// Use formula to calculate balance
-			for _, item := range group.Formula {
-				if acc, ok := accountMap[item.AccountID]; ok {
-					totalBalance += acc.CurrentBalance * item.Coefficient
-				}
-			}
+			totalBalance, divisionByZero, missingRefs = calculateFormulaBalance(group.Formula, formulaBalances(group.Formula, accountMap))
 		} else {
 			// Sum balances of member accounts
 			for _, accInGroup := range groupAccounts[group.ID] {
@@ -528,20 +845,156 @@ func (r *AccountGroupRepository) GetAllWithAccountsByType(entityType string) ([]
 		}
 
 		result = append(result, models.AccountGroupWithAccounts{
-			AccountGroup: group,
-			TotalBalance: totalBalance,
-			Accounts:     accounts,
+			AccountGroup:      group,
+			TotalBalance:      totalBalance,
+			DivisionByZero:    divisionByZero,
+			MissingReferences: missingRefs,
+			Accounts:          accounts,
 		})
 	}
 
+	groupMap := make(map[int]*models.AccountGroupWithAccounts)
+	for i := range result {
+		groupMap[result[i].ID] = &result[i]
+	}
+	nestChildGroups(groupMap)
+
 	return result, nil
 }
 
+// nestChildGroups attaches child groups to their parents and adds the children's
+// totals to each non-calculated parent, recursively. Formula groups keep their
+// formula total. Cycles in the parent chain are ignored rather than followed.
+func nestChildGroups(groupMap map[int]*models.AccountGroupWithAccounts) {
+	children := make(map[int][]int)
+	for id, g := range groupMap {
+		if g.ParentGroupID == nil {
+			continue
+		}
+		if _, ok := groupMap[*g.ParentGroupID]; ok {
+			children[*g.ParentGroupID] = append(children[*g.ParentGroupID], id)
+		}
+	}
+	for _, ids := range children {
+		sort.Slice(ids, func(i, j int) bool {
+			a, b := groupMap[ids[i]], groupMap[ids[j]]
+			if a.Position != b.Position {
+				return a.Position < b.Position
+			}
+			return a.Name < b.Name
+		})
+	}
+
+	done := make(map[int]bool)
+	visiting := make(map[int]bool)
+	var build func(id int)
+	build = func(id int) {
+		if done[id] || visiting[id] {
+			return
+		}
+		visiting[id] = true
+
+		g := groupMap[id]
+		for _, childID := range children[id] {
+			if visiting[childID] {
+				continue
+			}
+			build(childID)
+			child := groupMap[childID]
+			if !g.IsCalculated || len(g.Formula) == 0 {
+				g.TotalBalance += child.TotalBalance
+			}
+			g.ChildGroups = append(g.ChildGroups, *child)
+		}
+
+		visiting[id] = false
+		done[id] = true
+	}
+	for id := range groupMap {
+		build(id)
+	}
+}
+
 // GetAllInstitutions returns all institutions with their accounts (wrapper for backward compatibility)
 func (r *AccountGroupRepository) GetAllInstitutions() ([]models.AccountGroupWithAccounts, error) {
 	return r.GetAllWithAccountsByType("institution")
 }
 
+// GetInstitutionAccountsMap returns each non-archived institution's accounts (with
+// resolved balances) and the unassigned accounts, without computing institution totals
+func (r *AccountGroupRepository) GetInstitutionAccountsMap() (*models.InstitutionAccountsMap, error) {
+	accountsQuery := `
+		SELECT id, account_name, current_balance, is_calculated, formula
+		FROM account_balances
+		WHERE is_archived = false
+		ORDER BY position ASC, account_name ASC
+	`
+	accountRows, err := r.db.Query(accountsQuery)
+	if err != nil {
+		return nil, err
+	}
+	defer accountRows.Close()
+
+	var accounts []models.Account
+	for accountRows.Next() {
+		var a models.Account
+		var formulaJSON []byte
+		if err := accountRows.Scan(&a.ID, &a.AccountName, &a.CurrentBalance, &a.IsCalculated, &formulaJSON); err != nil {
+			return nil, err
+		}
+		if len(formulaJSON) > 0 {
+			json.Unmarshal(formulaJSON, &a.Formula)
+		}
+		accounts = append(accounts, a)
+	}
+	if err := accountRows.Err(); err != nil {
+		return nil, err
+	}
+
+	// Resolve calculated account balances
+	ResolveCalculatedBalances(accounts)
+
+	membershipQuery := `
+		SELECT agm.account_id, agm.group_id
+		FROM account_group_memberships agm
+		JOIN account_groups ag ON agm.group_id = ag.id
+		WHERE ag.entity_type = 'institution' AND ag.is_archived = false
+	`
+	membershipRows, err := r.db.Query(membershipQuery)
+	if err != nil {
+		return nil, err
+	}
+	defer membershipRows.Close()
+
+	institutionsByAccount := make(map[int][]int)
+	for membershipRows.Next() {
+		var accountID, institutionID int
+		if err := membershipRows.Scan(&accountID, &institutionID); err != nil {
+			return nil, err
+		}
+		institutionsByAccount[accountID] = append(institutionsByAccount[accountID], institutionID)
+	}
+	if err := membershipRows.Err(); err != nil {
+		return nil, err
+	}
+
+	result := &models.InstitutionAccountsMap{
+		Institutions: make(map[int][]models.AccountSummary),
+		Unassigned:   []models.AccountSummary{},
+	}
+	for _, a := range accounts {
+		summary := models.AccountSummary{ID: a.ID, AccountName: a.AccountName, CurrentBalance: a.CurrentBalance}
+		if institutionIDs, ok := institutionsByAccount[a.ID]; ok {
+			for _, institutionID := range institutionIDs {
+				result.Institutions[institutionID] = append(result.Institutions[institutionID], summary)
+			}
+		} else {
+			result.Unassigned = append(result.Unassigned, summary)
+		}
+	}
+	return result, nil
+}
+
 // GetAllInstitutionsIncludingArchived returns all institutions including archived ones
 func (r *AccountGroupRepository) GetAllInstitutionsIncludingArchived() ([]models.AccountGroup, error) {
 	return r.GetAllIncludingArchivedByType("institution")
@@ -599,12 +1052,12 @@ func (r *AccountGroupRepository) CreateByType(req *models.CreateGroupRequest, en
 	query := `
 		INSERT INTO account_groups (name, description, color, position, is_calculated, formula, entity_type)
 		VALUES ($1, $2, $3, $4, $5, $6, $7)
-		RETURNING id, name, description, color, position, is_archived, is_calculated, formula, entity_type, created_at, updated_at
+		RETURNING id, name, description, color, position, is_archived, is_calculated, formula, entity_type, parent_group_id, created_at, updated_at
 	`
 	var g models.AccountGroup
 	var returnedFormula []byte
 	err = tx.QueryRow(query, req.Name, req.Description, color, newPos, req.IsCalculated, formulaJSON, entityType).Scan(
-		&g.ID, &g.Name, &g.Description, &g.Color, &g.Position, &g.IsArchived, &g.IsCalculated, &returnedFormula, &g.EntityType, &g.CreatedAt, &g.UpdatedAt,
+		&g.ID, &g.Name, &g.Description, &g.Color, &g.Position, &g.IsArchived, &g.IsCalculated, &returnedFormula, &g.EntityType, &g.ParentGroupID, &g.CreatedAt, &g.UpdatedAt,
 	)
 	if err != nil {
 		return nil, err
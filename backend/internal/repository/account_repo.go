@@ -4,6 +4,11 @@ import (
 	"database/sql"
 	"encoding/json"
 	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
 
 	"finance-tracker/internal/models"
 	"finance-tracker/internal/validation"
@@ -49,10 +54,11 @@ func ResolveCalculatedBalances(accounts []models.Account) {
 				continue
 			}
 
-			// Check if all dependencies are resolved
+			// Check if all dependencies are resolved; missing accounts are skipped
+			// by evaluateFormula, so they don't hold the account back
 			allResolved := true
 			for _, item := range acc.Formula {
-				if !resolved[item.AccountID] {
+				if _, ok := accountMap[item.AccountID]; ok && !resolved[item.AccountID] {
 					allResolved = false
 					break
 				}
@@ -60,13 +66,7 @@ func ResolveCalculatedBalances(accounts []models.Account) {
 
 			if allResolved {
 				// Calculate balance from formula
-				var total float64
-				for _, item := range acc.Formula {
-					if dep, ok := accountMap[item.AccountID]; ok {
-						total += item.Coefficient * dep.CurrentBalance
-					}
-				}
-				acc.CurrentBalance = total
+				acc.CurrentBalance, acc.DivisionByZero, acc.MissingReferences = evaluateFormula(acc.Formula, formulaBalances(acc.Formula, accountMap))
 				resolved[id] = true
 				progress = true
 			}
@@ -83,7 +83,7 @@ func NewAccountRepository(db *sql.DB) *AccountRepository {
 
 func (r *AccountRepository) GetAll() ([]models.Account, error) {
 	query := `
-		SELECT id, account_name, account_info, current_balance, is_archived, position, is_calculated, formula, created_at, updated_at
+		SELECT id, account_name, account_info, COALESCE(account_number, ''), current_balance, is_archived, position, is_calculated, formula, created_at, updated_at
 		FROM account_balances
 		WHERE is_archived = false
 		ORDER BY position ASC, account_name ASC
@@ -97,13 +97,15 @@ func (r *AccountRepository) GetAll() ([]models.Account, error) {
 	var accounts []models.Account
 	for rows.Next() {
 		var a models.Account
+		var accountNumber string
 		var formulaJSON []byte
-		if err := rows.Scan(&a.ID, &a.AccountName, &a.AccountInfo, &a.CurrentBalance, &a.IsArchived, &a.Position, &a.IsCalculated, &formulaJSON, &a.CreatedAt, &a.UpdatedAt); err != nil {
+		if err := rows.Scan(&a.ID, &a.AccountName, &a.AccountInfo, &accountNumber, &a.CurrentBalance, &a.IsArchived, &a.Position, &a.IsCalculated, &formulaJSON, &a.CreatedAt, &a.UpdatedAt); err != nil {
 			return nil, fmt.Errorf("failed to scan account: %w", err)
 		}
 		if len(formulaJSON) > 0 {
 			json.Unmarshal(formulaJSON, &a.Formula)
 		}
+		a.MaskedAccountNumber = MaskAccountNumber(accountNumber)
 		accounts = append(accounts, a)
 	}
 
@@ -134,7 +136,7 @@ func (r *AccountRepository) GetAll() ([]models.Account, error) {
 		SELECT m.account_id, m.group_id FROM account_group_memberships m
 		JOIN account_groups g ON m.group_id = g.id
 		WHERE g.entity_type = 'institution'
-		ORDER BY m.account_id
+		ORDER BY m.account_id, m.group_id
 	`
 	institutionRows, err := r.db.Query(institutionQuery)
 	if err != nil {
@@ -142,24 +144,37 @@ func (r *AccountRepository) GetAll() ([]models.Account, error) {
 	}
 	defer institutionRows.Close()
 
-	institutionMemberships := make(map[int]int)
+	institutionMemberships := make(map[int][]int)
 	for institutionRows.Next() {
 		var accountID, institutionID int
 		if err := institutionRows.Scan(&accountID, &institutionID); err != nil {
 			return nil, fmt.Errorf("failed to scan institution membership: %w", err)
 		}
-		institutionMemberships[accountID] = institutionID
+		institutionMemberships[accountID] = append(institutionMemberships[accountID], institutionID)
 	}
 
-	// Assign group IDs and institution ID to accounts
+	tags, err := r.getTagsForAllAccounts()
+	if err != nil {
+		return nil, err
+	}
+
+	// Assign group IDs, institution ID and tags to accounts
 	for i := range accounts {
+		if accountTags, ok := tags[accounts[i].ID]; ok {
+			accounts[i].Tags = accountTags
+		} else {
+			accounts[i].Tags = []string{}
+		}
 		if groupIDs, ok := memberships[accounts[i].ID]; ok {
 			accounts[i].GroupIDs = groupIDs
 		} else {
 			accounts[i].GroupIDs = []int{}
 		}
-		if institutionID, ok := institutionMemberships[accounts[i].ID]; ok {
-			accounts[i].InstitutionID = &institutionID
+		if institutionIDs, ok := institutionMemberships[accounts[i].ID]; ok {
+			accounts[i].InstitutionID = &institutionIDs[0]
+			accounts[i].InstitutionIDs = institutionIDs
+		} else {
+			accounts[i].InstitutionIDs = []int{}
 		}
 	}
 
@@ -169,15 +184,164 @@ func (r *AccountRepository) GetAll() ([]models.Account, error) {
 	return accounts, nil
 }
 
+// GetAllWithChanges is GetAll plus each account's most recent balance change, for
+// list responses. Internal callers use GetAll to skip the extra history query.
+func (r *AccountRepository) GetAllWithChanges() ([]models.Account, error) {
+	accounts, err := r.GetAll()
+	if err != nil {
+		return nil, err
+	}
+	if err := r.attachBalanceChanges(accounts); err != nil {
+		return nil, err
+	}
+	return accounts, nil
+}
+
+// attachBalanceChanges sets the change amount, percent and period on each account
+// from its two most recent history entries. Accounts with fewer than two entries
+// are left without a change; percent is omitted when the previous balance is zero.
+func (r *AccountRepository) attachBalanceChanges(accounts []models.Account) error {
+	query := `
+		SELECT entity_id, balance, recorded_at
+		FROM (
+			SELECT entity_id, balance, recorded_at,
+			       ROW_NUMBER() OVER (PARTITION BY entity_id ORDER BY recorded_at DESC, id DESC) AS rn
+			FROM entity_balance_history
+			WHERE entity_type = 'account'
+		) recent
+		WHERE rn <= 2
+		ORDER BY entity_id, rn
+	`
+	rows, err := r.db.Query(query)
+	if err != nil {
+		return fmt.Errorf("failed to query balance changes: %w", err)
+	}
+	defer rows.Close()
+
+	type historyPoint struct {
+		balance    float64
+		recordedAt time.Time
+	}
+	recent := make(map[int][]historyPoint)
+	for rows.Next() {
+		var accountID int
+		var p historyPoint
+		if err := rows.Scan(&accountID, &p.balance, &p.recordedAt); err != nil {
+			return fmt.Errorf("failed to scan balance change: %w", err)
+		}
+		recent[accountID] = append(recent[accountID], p)
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to read balance changes: %w", err)
+	}
+
+	for i := range accounts {
+		points := recent[accounts[i].ID]
+		if len(points) < 2 {
+			continue
+		}
+		latest, previous := points[0], points[1]
+		amount := latest.balance - previous.balance
+		days := latest.recordedAt.Sub(previous.recordedAt).Hours() / 24
+		accounts[i].ChangeAmount = &amount
+		accounts[i].ChangePeriodDays = &days
+		if previous.balance != 0 {
+			percent := amount / math.Abs(previous.balance) * 100
+			accounts[i].ChangePercent = &percent
+		}
+	}
+	return nil
+}
+
+// FilterAccountsByTag returns the accounts carrying the given tag
+func FilterAccountsByTag(accounts []models.Account, tag string) []models.Account {
+	tag = NormalizeTagName(tag)
+	filtered := []models.Account{}
+	for _, a := range accounts {
+		for _, t := range a.Tags {
+			if t == tag {
+				filtered = append(filtered, a)
+				break
+			}
+		}
+	}
+	return filtered
+}
+
+// GetPaginated returns one page of non-archived accounts sorted by sortBy
+// ("name", "balance", "updated_at" or "" for position) in the given order.
+// Calculated balances depend on other accounts, so every account is still
+// fetched and resolved before sorting and slicing out the requested page.
+func (r *AccountRepository) GetPaginated(page, pageSize int, sortBy, order, tag string) (*models.AccountListResponse, error) {
+	accounts, err := r.GetAll()
+	if err != nil {
+		return nil, err
+	}
+	if tag != "" {
+		accounts = FilterAccountsByTag(accounts, tag)
+	}
+
+	desc := strings.EqualFold(order, "desc")
+	var less func(a, b *models.Account) bool
+	switch sortBy {
+	case "name":
+		less = func(a, b *models.Account) bool {
+			return strings.ToLower(a.AccountName) < strings.ToLower(b.AccountName)
+		}
+	case "balance":
+		less = func(a, b *models.Account) bool { return a.CurrentBalance < b.CurrentBalance }
+	case "updated_at":
+		less = func(a, b *models.Account) bool { return a.UpdatedAt.Before(b.UpdatedAt) }
+	}
+	if less != nil {
+		sort.SliceStable(accounts, func(i, j int) bool {
+			if desc {
+				return less(&accounts[j], &accounts[i])
+			}
+			return less(&accounts[i], &accounts[j])
+		})
+	} else if desc {
+		for i, j := 0, len(accounts)-1; i < j; i, j = i+1, j-1 {
+			accounts[i], accounts[j] = accounts[j], accounts[i]
+		}
+	}
+
+	total := len(accounts)
+	start := (page - 1) * pageSize
+	if start > total {
+		start = total
+	}
+	end := start + pageSize
+	if end > total {
+		end = total
+	}
+
+	pageAccounts := accounts[start:end]
+	if pageAccounts == nil {
+		pageAccounts = []models.Account{}
+	}
+	if err := r.attachBalanceChanges(pageAccounts); err != nil {
+		return nil, err
+	}
+
+	return &models.AccountListResponse{
+		Accounts: pageAccounts,
+		Total:    total,
+		Page:     page,
+		PageSize: pageSize,
+	}, nil
+}
+
 func (r *AccountRepository) GetByID(id int) (*models.Account, error) {
 	query := `
-		SELECT id, account_name, account_info, current_balance, is_archived, position, is_calculated, formula, created_at, updated_at
+		SELECT id, account_name, account_info, COALESCE(account_number, ''), current_balance, is_archived, position, is_calculated, formula, created_at, updated_at
 		FROM account_balances
 		WHERE id = $1
 	`
 	var a models.Account
+	var accountNumber string
 	var formulaJSON []byte
-	err := r.db.QueryRow(query, id).Scan(&a.ID, &a.AccountName, &a.AccountInfo, &a.CurrentBalance, &a.IsArchived, &a.Position, &a.IsCalculated, &formulaJSON, &a.CreatedAt, &a.UpdatedAt)
+	err := r.db.QueryRow(query, id).Scan(&a.ID, &a.AccountName, &a.AccountInfo, &accountNumber, &a.CurrentBalance, &a.IsArchived, &a.Position, &a.IsCalculated, &formulaJSON, &a.CreatedAt, &a.UpdatedAt)
 	if err == sql.ErrNoRows {
 		return nil, nil
 	}
@@ -187,6 +351,7 @@ func (r *AccountRepository) GetByID(id int) (*models.Account, error) {
 	if len(formulaJSON) > 0 {
 		json.Unmarshal(formulaJSON, &a.Formula)
 	}
+	a.MaskedAccountNumber = MaskAccountNumber(accountNumber)
 
 	// Fetch group memberships for this account (only groups, not institutions)
 	membershipQuery := `
@@ -210,20 +375,52 @@ func (r *AccountRepository) GetByID(id int) (*models.Account, error) {
 		a.GroupIDs = append(a.GroupIDs, groupID)
 	}
 
-	// Fetch institution membership for this account
+	// Fetch institution memberships for this account
 	institutionQuery := `
 		SELECT m.group_id FROM account_group_memberships m
 		JOIN account_groups g ON m.group_id = g.id
 		WHERE m.account_id = $1 AND g.entity_type = 'institution'
-		LIMIT 1
+		ORDER BY m.group_id
 	`
-	var institutionID int
-	err = r.db.QueryRow(institutionQuery, id).Scan(&institutionID)
-	if err == nil {
-		a.InstitutionID = &institutionID
-	} else if err != sql.ErrNoRows {
+	institutionRows, err := r.db.Query(institutionQuery, id)
+	if err != nil {
 		return nil, fmt.Errorf("failed to query institution membership: %w", err)
 	}
+	defer institutionRows.Close()
+
+	a.InstitutionIDs = []int{}
+	for institutionRows.Next() {
+		var institutionID int
+		if err := institutionRows.Scan(&institutionID); err != nil {
+			return nil, fmt.Errorf("failed to scan institution membership: %w", err)
+		}
+		a.InstitutionIDs = append(a.InstitutionIDs, institutionID)
+	}
+	if len(a.InstitutionIDs) > 0 {
+		a.InstitutionID = &a.InstitutionIDs[0]
+	}
+
+	// Fetch tags for this account
+	tagQuery := `
+		SELECT t.name FROM account_tag_memberships m
+		JOIN account_tags t ON m.tag_id = t.id
+		WHERE m.account_id = $1
+		ORDER BY t.name
+	`
+	tagRows, err := r.db.Query(tagQuery, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query tags: %w", err)
+	}
+	defer tagRows.Close()
+
+	a.Tags = []string{}
+	for tagRows.Next() {
+		var name string
+		if err := tagRows.Scan(&name); err != nil {
+			return nil, fmt.Errorf("failed to scan tag: %w", err)
+		}
+		a.Tags = append(a.Tags, name)
+	}
 
 	// If this is a calculated account, resolve its balance using all accounts
 	if a.IsCalculated && len(a.Formula) > 0 {
@@ -274,16 +471,18 @@ func (r *AccountRepository) Create(req *models.CreateAccountRequest) (*models.Ac
 	query := `
 		INSERT INTO account_balances (account_name, account_info, current_balance, position, is_calculated, formula)
 		VALUES ($1, $2, $3, $4, $5, $6)
-		RETURNING id, account_name, account_info, current_balance, is_archived, position, is_calculated, formula, created_at, updated_at
+		RETURNING id, account_name, account_info, COALESCE(account_number, ''), current_balance, is_archived, position, is_calculated, formula, created_at, updated_at
 	`
 	var a models.Account
+	var accountNumber string
 	var returnedFormula []byte
 	err = tx.QueryRow(query, req.AccountName, req.AccountInfo, req.CurrentBalance, newPosition, req.IsCalculated, formulaJSON).Scan(
-		&a.ID, &a.AccountName, &a.AccountInfo, &a.CurrentBalance, &a.IsArchived, &a.Position, &a.IsCalculated, &returnedFormula, &a.CreatedAt, &a.UpdatedAt,
+		&a.ID, &a.AccountName, &a.AccountInfo, &accountNumber, &a.CurrentBalance, &a.IsArchived, &a.Position, &a.IsCalculated, &returnedFormula, &a.CreatedAt, &a.UpdatedAt,
 	)
 	if len(returnedFormula) > 0 {
 		json.Unmarshal(returnedFormula, &a.Formula)
 	}
+	a.MaskedAccountNumber = MaskAccountNumber(accountNumber)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create account: %w", err)
 	}
@@ -308,17 +507,123 @@ func (r *AccountRepository) Create(req *models.CreateAccountRequest) (*models.Ac
 	return &a, nil
 }
 
+// Duplicate creates a copy of an account at the end of the position order.
+// Name (suffixed " (copy)"), info, is_calculated and formula are copied; balance history is not.
+// Group memberships are copied when requested.
+func (r *AccountRepository) Duplicate(id int, req *models.DuplicateAccountRequest) (*models.Account, error) {
+	source, err := r.GetByID(id)
+	if err != nil {
+		return nil, err
+	}
+	if source == nil {
+		return nil, nil
+	}
+
+	tx, err := r.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	// Get max position for new account
+	var maxPosition sql.NullInt64
+	err = tx.QueryRow("SELECT MAX(position) FROM account_balances WHERE is_archived = false").Scan(&maxPosition)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get max position: %w", err)
+	}
+	newPosition := 1
+	if maxPosition.Valid {
+		newPosition = int(maxPosition.Int64) + 1
+	}
+
+	// Account names are unique, so find a free "(copy)" name
+	baseName := source.AccountName + " (copy)"
+	name := baseName
+	for suffix := 2; ; suffix++ {
+		var exists bool
+		err := tx.QueryRow("SELECT EXISTS(SELECT 1 FROM account_balances WHERE account_name = $1)", name).Scan(&exists)
+		if err != nil {
+			return nil, fmt.Errorf("failed to check account name: %w", err)
+		}
+		if !exists {
+			break
+		}
+		name = fmt.Sprintf("%s %d", baseName, suffix)
+	}
+
+	var formulaJSON interface{}
+	if source.IsCalculated && len(source.Formula) > 0 {
+		formulaJSON, err = json.Marshal(source.Formula)
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal formula: %w", err)
+		}
+	}
+
+	balance := source.CurrentBalance
+	if req.ZeroBalance {
+		balance = 0
+	}
+
+	var newID int
+	err = tx.QueryRow(`
+		INSERT INTO account_balances (account_name, account_info, current_balance, position, is_calculated, formula)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING id
+	`, name, source.AccountInfo, balance, newPosition, source.IsCalculated, formulaJSON).Scan(&newID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create account: %w", err)
+	}
+
+	// Create initial history record
+	_, err = tx.Exec(`
+		INSERT INTO entity_balance_history (entity_type, entity_id, entity_name_snapshot, balance)
+		VALUES ('account', $1, $2, $3)
+	`, newID, name, balance)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create initial history: %w", err)
+	}
+
+	if req.CopyGroups {
+		for _, groupID := range source.GroupIDs {
+			var maxPos sql.NullInt64
+			err := tx.QueryRow("SELECT MAX(position_in_group) FROM account_group_memberships WHERE group_id = $1", groupID).Scan(&maxPos)
+			if err != nil {
+				return nil, fmt.Errorf("failed to get max position: %w", err)
+			}
+			newPos := 1
+			if maxPos.Valid {
+				newPos = int(maxPos.Int64) + 1
+			}
+
+			_, err = tx.Exec(`
+				INSERT INTO account_group_memberships (account_id, group_id, position_in_group)
+				VALUES ($1, $2, $3)
+			`, newID, groupID, newPos)
+			if err != nil {
+				return nil, fmt.Errorf("failed to add membership to group %d: %w", groupID, err)
+			}
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return r.GetByID(newID)
+}
+
 func (r *AccountRepository) UpdateName(id int, name string) (*models.Account, error) {
 	query := `
 		UPDATE account_balances
 		SET account_name = $1, updated_at = NOW()
 		WHERE id = $2
-		RETURNING id, account_name, account_info, current_balance, is_archived, position, is_calculated, formula, created_at, updated_at
+		RETURNING id, account_name, account_info, COALESCE(account_number, ''), current_balance, is_archived, position, is_calculated, formula, created_at, updated_at
 	`
 	var a models.Account
+	var accountNumber string
 	var formulaJSON []byte
 	err := r.db.QueryRow(query, name, id).Scan(
-		&a.ID, &a.AccountName, &a.AccountInfo, &a.CurrentBalance, &a.IsArchived, &a.Position, &a.IsCalculated, &formulaJSON, &a.CreatedAt, &a.UpdatedAt,
+		&a.ID, &a.AccountName, &a.AccountInfo, &accountNumber, &a.CurrentBalance, &a.IsArchived, &a.Position, &a.IsCalculated, &formulaJSON, &a.CreatedAt, &a.UpdatedAt,
 	)
 	if err == sql.ErrNoRows {
 		return nil, nil
@@ -329,6 +634,7 @@ func (r *AccountRepository) UpdateName(id int, name string) (*models.Account, er
 	if len(formulaJSON) > 0 {
 		json.Unmarshal(formulaJSON, &a.Formula)
 	}
+	a.MaskedAccountNumber = MaskAccountNumber(accountNumber)
 
 	// Fetch group memberships
 	a.GroupIDs = []int{}
@@ -368,16 +674,18 @@ func (r *AccountRepository) UpdateBalance(id int, balance float64) (*models.Acco
 		UPDATE account_balances
 		SET current_balance = $1, updated_at = NOW()
 		WHERE id = $2
-		RETURNING id, account_name, account_info, current_balance, is_archived, position, is_calculated, formula, created_at, updated_at
+		RETURNING id, account_name, account_info, COALESCE(account_number, ''), current_balance, is_archived, position, is_calculated, formula, created_at, updated_at
 	`
 	var a models.Account
+	var accountNumber string
 	var formulaJSON []byte
 	err = tx.QueryRow(updateQuery, balance, id).Scan(
-		&a.ID, &a.AccountName, &a.AccountInfo, &a.CurrentBalance, &a.IsArchived, &a.Position, &a.IsCalculated, &formulaJSON, &a.CreatedAt, &a.UpdatedAt,
+		&a.ID, &a.AccountName, &a.AccountInfo, &accountNumber, &a.CurrentBalance, &a.IsArchived, &a.Position, &a.IsCalculated, &formulaJSON, &a.CreatedAt, &a.UpdatedAt,
 	)
 	if len(formulaJSON) > 0 {
 		json.Unmarshal(formulaJSON, &a.Formula)
 	}
+	a.MaskedAccountNumber = MaskAccountNumber(accountNumber)
 	if err != nil {
 		return nil, fmt.Errorf("failed to update balance: %w", err)
 	}
@@ -429,7 +737,7 @@ func (r *AccountRepository) UpdateBalance(id int, balance float64) (*models.Acco
 			}
 
 			// Calculate new balance from formula
-			newBalance := calculateFormulaBalance(depAccount.Formula, balanceMap)
+			newBalance, _, _ := calculateFormulaBalance(depAccount.Formula, balanceMap)
 			// Update balance map for subsequent calculations
 			balanceMap[depID] = newBalance
 
@@ -509,12 +817,13 @@ func (r *AccountRepository) Archive(id int) (*models.Account, error) {
 		UPDATE account_balances
 		SET is_archived = true, updated_at = NOW()
 		WHERE id = $1
-		RETURNING id, account_name, account_info, current_balance, is_archived, position, is_calculated, formula, created_at, updated_at
+		RETURNING id, account_name, account_info, COALESCE(account_number, ''), current_balance, is_archived, position, is_calculated, formula, created_at, updated_at
 	`
 	var a models.Account
+	var accountNumber string
 	var formulaJSON []byte
 	err := r.db.QueryRow(query, id).Scan(
-		&a.ID, &a.AccountName, &a.AccountInfo, &a.CurrentBalance, &a.IsArchived, &a.Position, &a.IsCalculated, &formulaJSON, &a.CreatedAt, &a.UpdatedAt,
+		&a.ID, &a.AccountName, &a.AccountInfo, &accountNumber, &a.CurrentBalance, &a.IsArchived, &a.Position, &a.IsCalculated, &formulaJSON, &a.CreatedAt, &a.UpdatedAt,
 	)
 	if err == sql.ErrNoRows {
 		return nil, nil
@@ -525,6 +834,7 @@ func (r *AccountRepository) Archive(id int) (*models.Account, error) {
 	if len(formulaJSON) > 0 {
 		json.Unmarshal(formulaJSON, &a.Formula)
 	}
+	a.MaskedAccountNumber = MaskAccountNumber(accountNumber)
 	a.GroupIDs = []int{}
 	return &a, nil
 }
@@ -534,12 +844,13 @@ func (r *AccountRepository) UpdateInfo(id int, info string) (*models.Account, er
 		UPDATE account_balances
 		SET account_info = $1, updated_at = NOW()
 		WHERE id = $2
-		RETURNING id, account_name, account_info, current_balance, is_archived, position, is_calculated, formula, created_at, updated_at
+		RETURNING id, account_name, account_info, COALESCE(account_number, ''), current_balance, is_archived, position, is_calculated, formula, created_at, updated_at
 	`
 	var a models.Account
+	var accountNumber string
 	var formulaJSON []byte
 	err := r.db.QueryRow(query, info, id).Scan(
-		&a.ID, &a.AccountName, &a.AccountInfo, &a.CurrentBalance, &a.IsArchived, &a.Position, &a.IsCalculated, &formulaJSON, &a.CreatedAt, &a.UpdatedAt,
+		&a.ID, &a.AccountName, &a.AccountInfo, &accountNumber, &a.CurrentBalance, &a.IsArchived, &a.Position, &a.IsCalculated, &formulaJSON, &a.CreatedAt, &a.UpdatedAt,
 	)
 	if err == sql.ErrNoRows {
 		return nil, nil
@@ -550,6 +861,7 @@ func (r *AccountRepository) UpdateInfo(id int, info string) (*models.Account, er
 	if len(formulaJSON) > 0 {
 		json.Unmarshal(formulaJSON, &a.Formula)
 	}
+	a.MaskedAccountNumber = MaskAccountNumber(accountNumber)
 
 	// Fetch group memberships
 	a.GroupIDs = []int{}
@@ -567,6 +879,67 @@ func (r *AccountRepository) UpdateInfo(id int, info string) (*models.Account, er
 	return &a, nil
 }
 
+// UpdateAccountNumber sets or clears (empty string) the structured account number.
+// Only digits, spaces and dashes are accepted. Returns nil if the account does not exist.
+func (r *AccountRepository) UpdateAccountNumber(id int, accountNumber string) (*models.Account, error) {
+	accountNumber = strings.TrimSpace(accountNumber)
+	if len(accountNumber) > 64 {
+		return nil, &ValidationError{Message: "account number must be at most 64 characters"}
+	}
+	for _, c := range accountNumber {
+		if !unicode.IsDigit(c) && c != ' ' && c != '-' {
+			return nil, &ValidationError{Message: "account number may only contain digits, spaces and dashes"}
+		}
+	}
+
+	var value interface{}
+	if accountNumber != "" {
+		value = accountNumber
+	}
+	result, err := r.db.Exec("UPDATE account_balances SET account_number = $1, updated_at = NOW() WHERE id = $2", value, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update account number: %w", err)
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if rowsAffected == 0 {
+		return nil, nil
+	}
+	return r.GetByID(id)
+}
+
+// GetAccountNumber returns the full, unmasked account number. Returns nil if the
+// account does not exist.
+func (r *AccountRepository) GetAccountNumber(id int) (*string, error) {
+	var accountNumber string
+	err := r.db.QueryRow("SELECT COALESCE(account_number, '') FROM account_balances WHERE id = $1", id).Scan(&accountNumber)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get account number: %w", err)
+	}
+	return &accountNumber, nil
+}
+
+// MaskAccountNumber hides all but the last 4 digits of an account number
+func MaskAccountNumber(accountNumber string) string {
+	var digits []rune
+	for _, c := range accountNumber {
+		if unicode.IsDigit(c) {
+			digits = append(digits, c)
+		}
+	}
+	if len(digits) == 0 {
+		return ""
+	}
+	if len(digits) <= 4 {
+		return "****"
+	}
+	return "****" + string(digits[len(digits)-4:])
+}
 
 func (r *AccountRepository) GetHistory(accountID int) ([]models.BalanceHistory, error) {
 	query := `
@@ -592,6 +965,339 @@ func (r *AccountRepository) GetHistory(accountID int) ([]models.BalanceHistory,
 	return history, nil
 }
 
+// forecastLookbackDays is how much recent history the balance forecast is fitted to
+const forecastLookbackDays = 365
+
+// forecastMinPoints is the fewest history entries a forecast will be fitted to
+const forecastMinPoints = 3
+
+// GetForecast projects an account's balance horizonDays into the future by fitting a
+// least-squares line to its recent balance history. Returns nil if the account does
+// not exist. Accounts with too little history get Confidence "insufficient_data".
+func (r *AccountRepository) GetForecast(id int, horizonDays int) (*models.ForecastResponse, error) {
+	account, err := r.GetByID(id)
+	if err != nil || account == nil {
+		return nil, err
+	}
+
+	rows, err := r.db.Query(`
+		SELECT balance, recorded_at
+		FROM entity_balance_history
+		WHERE entity_type = 'account' AND entity_id = $1
+		  AND recorded_at >= NOW() - make_interval(days => $2)
+		ORDER BY recorded_at ASC
+	`, id, forecastLookbackDays)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query history: %w", err)
+	}
+	defer rows.Close()
+
+	// x is days since the first point, y is the balance
+	var xs, ys []float64
+	var first time.Time
+	for rows.Next() {
+		var balance float64
+		var recordedAt time.Time
+		if err := rows.Scan(&balance, &recordedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan history: %w", err)
+		}
+		if len(xs) == 0 {
+			first = recordedAt
+		}
+		xs = append(xs, recordedAt.Sub(first).Hours()/24)
+		ys = append(ys, balance)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read history: %w", err)
+	}
+
+	forecast := &models.ForecastResponse{
+		AccountID:      id,
+		HorizonDays:    horizonDays,
+		CurrentBalance: account.CurrentBalance,
+		Points:         len(xs),
+		Confidence:     "insufficient_data",
+	}
+	if len(xs) < forecastMinPoints {
+		forecast.Message = fmt.Sprintf("insufficient data: at least %d history entries in the last %d days are needed", forecastMinPoints, forecastLookbackDays)
+		return forecast, nil
+	}
+
+	slope, intercept, rSquared, ok := linearRegression(xs, ys)
+	if !ok {
+		forecast.Message = "insufficient data: history entries must span more than one point in time"
+		return forecast, nil
+	}
+
+	// Project from now rather than from the last history entry
+	now := time.Since(first).Hours() / 24
+	projected := intercept + slope*(now+float64(horizonDays))
+	forecast.ProjectedBalance = &projected
+	forecast.SlopePerDay = &slope
+	forecast.RSquared = &rSquared
+	switch {
+	case rSquared >= 0.75 && len(xs) >= 10:
+		forecast.Confidence = "high"
+	case rSquared >= 0.4:
+		forecast.Confidence = "medium"
+	default:
+		forecast.Confidence = "low"
+	}
+	return forecast, nil
+}
+
+// linearRegression fits y = intercept + slope*x by least squares and returns the
+// coefficient of determination. ok is false when all x values are equal.
+func linearRegression(xs, ys []float64) (slope, intercept, rSquared float64, ok bool) {
+	n := float64(len(xs))
+	var sumX, sumY float64
+	for i := range xs {
+		sumX += xs[i]
+		sumY += ys[i]
+	}
+	meanX, meanY := sumX/n, sumY/n
+
+	var sxx, sxy, syy float64
+	for i := range xs {
+		dx, dy := xs[i]-meanX, ys[i]-meanY
+		sxx += dx * dx
+		sxy += dx * dy
+		syy += dy * dy
+	}
+	if sxx == 0 {
+		return 0, 0, 0, false
+	}
+
+	slope = sxy / sxx
+	intercept = meanY - slope*meanX
+	if syy == 0 {
+		// A perfectly flat history is fitted exactly
+		rSquared = 1
+	} else {
+		rSquared = (sxy * sxy) / (sxx * syy)
+	}
+	return slope, intercept, rSquared, true
+}
+
+// GetNetWorthHistory returns the total balance of all non-archived accounts at every
+// recorded history timestamp. Accounts without a record at a given timestamp carry
+// forward their latest known balance. The carry-forward is computed in SQL by summing
+// per-account balance deltas with a running window, so it scales with the number of
+// history rows rather than rows * timestamps.
+func (r *AccountRepository) GetNetWorthHistory() ([]models.NetWorthPoint, error) {
+	query := `
+		WITH deltas AS (
+			SELECT h.recorded_at,
+			       h.balance - COALESCE(LAG(h.balance) OVER (PARTITION BY h.entity_id ORDER BY h.recorded_at, h.id), 0) AS delta
+			FROM entity_balance_history h
+			JOIN account_balances a ON a.id = h.entity_id
+			WHERE h.entity_type = 'account' AND a.is_archived = false
+		)
+		SELECT recorded_at, SUM(SUM(delta)) OVER (ORDER BY recorded_at) AS total
+		FROM deltas
+		GROUP BY recorded_at
+		ORDER BY recorded_at ASC
+	`
+	rows, err := r.db.Query(query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query net worth history: %w", err)
+	}
+	defer rows.Close()
+
+	var points []models.NetWorthPoint
+	for rows.Next() {
+		var p models.NetWorthPoint
+		if err := rows.Scan(&p.Date, &p.Total); err != nil {
+			return nil, fmt.Errorf("failed to scan net worth history: %w", err)
+		}
+		points = append(points, p)
+	}
+	return points, rows.Err()
+}
+
+// GetTotalExcluding recomputes the grand total of active accounts as if the given
+// accounts, and every account in the given groups or their child groups, were absent.
+// Excluded accounts count as 0 and calculated accounts are re-resolved, so a formula
+// referencing an excluded account sees 0 for it. Unknown or archived IDs are a
+// validation error.
+func (r *AccountRepository) GetTotalExcluding(accountIDs, groupIDs []int) (*models.TotalExcludingResponse, error) {
+	accounts, err := r.GetAll()
+	if err != nil {
+		return nil, err
+	}
+	active := make(map[int]bool, len(accounts))
+	for _, a := range accounts {
+		active[a.ID] = true
+	}
+
+	excluded := make(map[int]bool)
+	for _, id := range accountIDs {
+		if !active[id] {
+			return nil, &ValidationError{Message: fmt.Sprintf("account %d does not exist or is archived", id)}
+		}
+		excluded[id] = true
+	}
+
+	if len(groupIDs) > 0 {
+		children := make(map[int][]int)
+		activeGroups := make(map[int]bool)
+		rows, err := r.db.Query("SELECT id, parent_group_id FROM account_groups WHERE entity_type = 'group' AND is_archived = false")
+		if err != nil {
+			return nil, fmt.Errorf("failed to query groups: %w", err)
+		}
+		defer rows.Close()
+		for rows.Next() {
+			var id int
+			var parentID sql.NullInt64
+			if err := rows.Scan(&id, &parentID); err != nil {
+				return nil, fmt.Errorf("failed to scan group: %w", err)
+			}
+			activeGroups[id] = true
+			if parentID.Valid {
+				children[int(parentID.Int64)] = append(children[int(parentID.Int64)], id)
+			}
+		}
+		if err := rows.Err(); err != nil {
+			return nil, err
+		}
+
+		excludedGroups := make(map[int]bool)
+		var pending []int
+		for _, id := range groupIDs {
+			if !activeGroups[id] {
+				return nil, &ValidationError{Message: fmt.Sprintf("group %d does not exist or is archived", id)}
+			}
+			pending = append(pending, id)
+		}
+		for len(pending) > 0 {
+			id := pending[0]
+			pending = pending[1:]
+			if excludedGroups[id] {
+				continue
+			}
+			excludedGroups[id] = true
+			pending = append(pending, children[id]...)
+		}
+		for _, a := range accounts {
+			for _, groupID := range a.GroupIDs {
+				if excludedGroups[groupID] {
+					excluded[a.ID] = true
+				}
+			}
+		}
+	}
+
+	resp := &models.TotalExcludingResponse{}
+	adjusted := make([]models.Account, len(accounts))
+	copy(adjusted, accounts)
+	for i := range adjusted {
+		resp.Total += adjusted[i].CurrentBalance
+		if excluded[adjusted[i].ID] {
+			// Pin excluded calculated accounts at 0 rather than re-resolving them
+			adjusted[i].IsCalculated = false
+			adjusted[i].CurrentBalance = 0
+		}
+	}
+	ResolveCalculatedBalances(adjusted)
+	for _, a := range adjusted {
+		resp.AdjustedTotal += a.CurrentBalance
+	}
+	resp.Difference = resp.Total - resp.AdjustedTotal
+	return resp, nil
+}
+
+// GetPeriodReport compares every active account, group and institution between from
+// and to, resolving each balance as of that time from the balance history. Entities
+// with no balance at either end are left out. moverLimit caps TopMovers.
+func (r *AccountRepository) GetPeriodReport(from, to time.Time, moverLimit int) (*models.PeriodReport, error) {
+	query := `
+		WITH entities AS (
+			SELECT 'account' AS entity_type, id, account_name AS name, 0 AS sort_group, position
+			FROM account_balances
+			WHERE is_archived = false
+			UNION ALL
+			SELECT entity_type, id, name, CASE WHEN entity_type = 'group' THEN 1 ELSE 2 END, position
+			FROM account_groups
+			WHERE is_archived = false AND entity_type IN ('group', 'institution')
+		)
+		SELECT e.entity_type, e.id, e.name,
+		       (SELECT h.balance FROM entity_balance_history h
+		        WHERE h.entity_type = e.entity_type AND h.entity_id = e.id AND h.recorded_at <= $1
+		        ORDER BY h.recorded_at DESC, h.id DESC LIMIT 1),
+		       (SELECT h.balance FROM entity_balance_history h
+		        WHERE h.entity_type = e.entity_type AND h.entity_id = e.id AND h.recorded_at <= $2
+		        ORDER BY h.recorded_at DESC, h.id DESC LIMIT 1)
+		FROM entities e
+		ORDER BY e.sort_group, e.position, e.id
+	`
+	rows, err := r.db.Query(query, from, to)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query period balances: %w", err)
+	}
+	defer rows.Close()
+
+	report := &models.PeriodReport{
+		From:         from,
+		To:           to,
+		Groups:       []models.PeriodChange{},
+		Institutions: []models.PeriodChange{},
+		Accounts:     []models.PeriodChange{},
+	}
+	for rows.Next() {
+		var c models.PeriodChange
+		var fromBalance, toBalance sql.NullFloat64
+		if err := rows.Scan(&c.EntityType, &c.ID, &c.Name, &fromBalance, &toBalance); err != nil {
+			return nil, fmt.Errorf("failed to scan period balance: %w", err)
+		}
+		if !fromBalance.Valid && !toBalance.Valid {
+			continue
+		}
+		if fromBalance.Valid {
+			c.FromBalance = &fromBalance.Float64
+		}
+		if toBalance.Valid {
+			c.ToBalance = &toBalance.Float64
+		}
+		c.Change = toBalance.Float64 - fromBalance.Float64
+		c.PercentChange = percentChange(fromBalance.Float64, c.Change)
+
+		switch c.EntityType {
+		case "account":
+			report.Accounts = append(report.Accounts, c)
+			report.FromTotal += fromBalance.Float64
+			report.ToTotal += toBalance.Float64
+		case "group":
+			report.Groups = append(report.Groups, c)
+		case "institution":
+			report.Institutions = append(report.Institutions, c)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read period balances: %w", err)
+	}
+
+	report.Change = report.ToTotal - report.FromTotal
+	report.PercentChange = percentChange(report.FromTotal, report.Change)
+
+	report.TopMovers = append([]models.PeriodChange{}, report.Accounts...)
+	sort.SliceStable(report.TopMovers, func(i, j int) bool {
+		return math.Abs(report.TopMovers[i].Change) > math.Abs(report.TopMovers[j].Change)
+	})
+	if len(report.TopMovers) > moverLimit {
+		report.TopMovers = report.TopMovers[:moverLimit]
+	}
+	return report, nil
+}
+
+// percentChange returns change as a percentage of |from|, or nil when from is 0
+func percentChange(from, change float64) *float64 {
+	if from == 0 {
+		return nil
+	}
+	percent := change / math.Abs(from) * 100
+	return &percent
+}
+
 func (r *AccountRepository) UpdatePositions(positions []models.AccountPosition) error {
 	tx, err := r.db.Begin()
 	if err != nil {
@@ -614,6 +1320,142 @@ func (r *AccountRepository) UpdatePositions(positions []models.AccountPosition)
 	return nil
 }
 
+// Move repositions a single account in the main list, shifting the accounts and
+// groups at or after the new position down by one. Returns nil if the account
+// does not exist.
+func (r *AccountRepository) Move(id int, req *models.MoveAccountRequest) (*models.Account, error) {
+	set := 0
+	if req.BeforeID != nil {
+		set++
+	}
+	if req.AfterID != nil {
+		set++
+	}
+	if req.To != "" {
+		set++
+	}
+	if set != 1 {
+		return nil, &ValidationError{Message: "Exactly one of before_id, after_id, or to is required"}
+	}
+	if req.To != "" && req.To != "top" && req.To != "bottom" {
+		return nil, &ValidationError{Message: "to must be 'top' or 'bottom'"}
+	}
+	neighborType := req.NeighborType
+	if neighborType == "" {
+		neighborType = "account"
+	}
+	if neighborType != "account" && neighborType != "group" {
+		return nil, &ValidationError{Message: "neighbor_type must be 'account' or 'group'"}
+	}
+
+	tx, err := r.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var exists bool
+	err = tx.QueryRow("SELECT EXISTS(SELECT 1 FROM account_balances WHERE id = $1)", id).Scan(&exists)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check account: %w", err)
+	}
+	if !exists {
+		return nil, nil
+	}
+
+	// Accounts and groups share one position space in the main list
+	var newPos int
+	shift := true
+	switch {
+	case req.To == "top":
+		var minPos sql.NullInt64
+		err = tx.QueryRow(`
+			SELECT MIN(position) FROM (
+				SELECT position FROM account_balances WHERE is_archived = false AND id != $1
+				UNION ALL
+				SELECT position FROM account_groups WHERE is_archived = false AND entity_type = 'group'
+			) positions
+		`, id).Scan(&minPos)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get min position: %w", err)
+		}
+		newPos = 1
+		if minPos.Valid {
+			newPos = int(minPos.Int64)
+		}
+	case req.To == "bottom":
+		var maxPos sql.NullInt64
+		err = tx.QueryRow(`
+			SELECT MAX(position) FROM (
+				SELECT position FROM account_balances WHERE is_archived = false AND id != $1
+				UNION ALL
+				SELECT position FROM account_groups WHERE is_archived = false AND entity_type = 'group'
+			) positions
+		`, id).Scan(&maxPos)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get max position: %w", err)
+		}
+		newPos = 1
+		if maxPos.Valid {
+			newPos = int(maxPos.Int64) + 1
+		}
+		shift = false
+	default:
+		neighborID := req.BeforeID
+		if neighborID == nil {
+			neighborID = req.AfterID
+		}
+		if neighborType == "account" && *neighborID == id {
+			return nil, &ValidationError{Message: "Cannot move an account relative to itself"}
+		}
+
+		query := "SELECT position FROM account_balances WHERE id = $1"
+		if neighborType == "group" {
+			query = "SELECT position FROM account_groups WHERE id = $1 AND entity_type = 'group'"
+		}
+		var neighborPos int
+		err = tx.QueryRow(query, *neighborID).Scan(&neighborPos)
+		if err == sql.ErrNoRows {
+			return nil, &ValidationError{Message: fmt.Sprintf("%s %d not found", neighborType, *neighborID)}
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to get neighbor position: %w", err)
+		}
+
+		newPos = neighborPos
+		if req.AfterID != nil {
+			newPos = neighborPos + 1
+		}
+	}
+
+	if shift {
+		_, err = tx.Exec(`
+			UPDATE account_balances SET position = position + 1
+			WHERE is_archived = false AND position >= $1 AND id != $2
+		`, newPos, id)
+		if err != nil {
+			return nil, fmt.Errorf("failed to shift account positions: %w", err)
+		}
+		_, err = tx.Exec(`
+			UPDATE account_groups SET position = position + 1
+			WHERE is_archived = false AND entity_type = 'group' AND position >= $1
+		`, newPos)
+		if err != nil {
+			return nil, fmt.Errorf("failed to shift group positions: %w", err)
+		}
+	}
+
+	_, err = tx.Exec("UPDATE account_balances SET position = $1, updated_at = NOW() WHERE id = $2", newPos, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update position: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return r.GetByID(id)
+}
+
 func (r *AccountRepository) UpdateFormula(id int, isCalculated bool, formula []models.FormulaItem) (*models.Account, error) {
 	var formulaJSON interface{}
 	var err error
@@ -628,12 +1470,13 @@ func (r *AccountRepository) UpdateFormula(id int, isCalculated bool, formula []m
 		UPDATE account_balances
 		SET is_calculated = $1, formula = $2, updated_at = NOW()
 		WHERE id = $3
-		RETURNING id, account_name, account_info, current_balance, is_archived, position, is_calculated, formula, created_at, updated_at
+		RETURNING id, account_name, account_info, COALESCE(account_number, ''), current_balance, is_archived, position, is_calculated, formula, created_at, updated_at
 	`
 	var a models.Account
+	var accountNumber string
 	var returnedFormula []byte
 	err = r.db.QueryRow(query, isCalculated, formulaJSON, id).Scan(
-		&a.ID, &a.AccountName, &a.AccountInfo, &a.CurrentBalance, &a.IsArchived, &a.Position, &a.IsCalculated, &returnedFormula, &a.CreatedAt, &a.UpdatedAt,
+		&a.ID, &a.AccountName, &a.AccountInfo, &accountNumber, &a.CurrentBalance, &a.IsArchived, &a.Position, &a.IsCalculated, &returnedFormula, &a.CreatedAt, &a.UpdatedAt,
 	)
 	if err == sql.ErrNoRows {
 		return nil, nil
@@ -644,6 +1487,7 @@ func (r *AccountRepository) UpdateFormula(id int, isCalculated bool, formula []m
 	if len(returnedFormula) > 0 {
 		json.Unmarshal(returnedFormula, &a.Formula)
 	}
+	a.MaskedAccountNumber = MaskAccountNumber(accountNumber)
 
 	// Fetch group memberships
 	a.GroupIDs = []int{}
@@ -661,9 +1505,348 @@ func (r *AccountRepository) UpdateFormula(id int, isCalculated bool, formula []m
 	return &a, nil
 }
 
+// GetBrokenReferences lists the formula items of a calculated account that point at
+// archived or deleted accounts. Returns nil if the account does not exist.
+func (r *AccountRepository) GetBrokenReferences(id int) ([]models.BrokenFormulaReference, error) {
+	account, err := r.GetByID(id)
+	if err != nil || account == nil {
+		return nil, err
+	}
+	allAccounts, err := r.GetAllIncludingArchived()
+	if err != nil {
+		return nil, err
+	}
+	return findBrokenReferences(account.Formula, allAccounts), nil
+}
+
+func findBrokenReferences(formula []models.FormulaItem, allAccounts []models.Account) []models.BrokenFormulaReference {
+	accountMap := make(map[int]models.Account)
+	for _, a := range allAccounts {
+		accountMap[a.ID] = a
+	}
+
+	broken := []models.BrokenFormulaReference{}
+	for i, item := range formula {
+		ref := models.BrokenFormulaReference{
+			Index:       i,
+			AccountID:   item.AccountID,
+			Coefficient: item.Coefficient,
+			Operation:   item.Operation,
+		}
+		a, ok := accountMap[item.AccountID]
+		switch {
+		case !ok:
+			ref.Reason = "deleted"
+		case a.IsArchived:
+			ref.Reason = "archived"
+			ref.AccountName = a.AccountName
+		default:
+			continue
+		}
+		broken = append(broken, ref)
+	}
+	return broken
+}
+
+// GetEffectiveFormula expands a calculated account's formula, replacing each nested
+// calculated account with its own formula, into coefficients on base accounts.
+// Only add/subtract formulas can be flattened; multiply or divide anywhere in the
+// tree, or a circular reference, is a validation error. References to deleted or
+// archived accounts are dropped as they contribute nothing to the resolved balance.
+// Returns nil if the account does not exist.
+func (r *AccountRepository) GetEffectiveFormula(id int) (*models.EffectiveFormulaResponse, error) {
+	allAccounts, err := r.GetAllIncludingArchived()
+	if err != nil {
+		return nil, err
+	}
+	accountMap := make(map[int]*models.Account, len(allAccounts))
+	for i := range allAccounts {
+		accountMap[allAccounts[i].ID] = &allAccounts[i]
+	}
+	account, ok := accountMap[id]
+	if !ok {
+		return nil, nil
+	}
+
+	coefficients := make(map[int]float64)
+	if err := expandFormula(account, 1, accountMap, map[int]bool{}, coefficients); err != nil {
+		return nil, err
+	}
+
+	resp := &models.EffectiveFormulaResponse{
+		AccountID:   account.ID,
+		AccountName: account.AccountName,
+		Terms:       []models.EffectiveFormulaTerm{},
+	}
+	for accountID, coefficient := range coefficients {
+		base := accountMap[accountID]
+		resp.Terms = append(resp.Terms, models.EffectiveFormulaTerm{
+			AccountID:   accountID,
+			AccountName: base.AccountName,
+			Coefficient: coefficient,
+			Balance:     base.CurrentBalance,
+		})
+		resp.Balance += coefficient * base.CurrentBalance
+	}
+	sort.Slice(resp.Terms, func(i, j int) bool {
+		if resp.Terms[i].AccountName != resp.Terms[j].AccountName {
+			return resp.Terms[i].AccountName < resp.Terms[j].AccountName
+		}
+		return resp.Terms[i].AccountID < resp.Terms[j].AccountID
+	})
+	return resp, nil
+}
+
+// expandFormula adds account's contribution, scaled by multiplier, to coefficients.
+// visiting holds the calculated accounts on the current path to detect cycles.
+func expandFormula(account *models.Account, multiplier float64, accountMap map[int]*models.Account, visiting map[int]bool, coefficients map[int]float64) error {
+	if !account.IsCalculated {
+		coefficients[account.ID] += multiplier
+		return nil
+	}
+	if visiting[account.ID] {
+		return &ValidationError{Message: fmt.Sprintf("formula has a circular reference through %q", account.AccountName)}
+	}
+	visiting[account.ID] = true
+	defer delete(visiting, account.ID)
+
+	for i, item := range account.Formula {
+		sign := 1.0
+		switch item.Operation {
+		case "", models.FormulaOpAdd:
+		case models.FormulaOpSubtract:
+			sign = -1
+		default:
+			if i > 0 {
+				return &ValidationError{Message: fmt.Sprintf("%q uses %s; only formulas that add and subtract can be expanded", account.AccountName, item.Operation)}
+			}
+		}
+
+		dep, ok := accountMap[item.AccountID]
+		if !ok || dep.IsArchived {
+			continue
+		}
+		if err := expandFormula(dep, multiplier*sign*item.Coefficient, accountMap, visiting, coefficients); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// RepairFormula remaps broken formula references to active accounts as requested and
+// removes the rest, then returns the re-resolved account. Returns nil if the account
+// does not exist.
+func (r *AccountRepository) RepairFormula(id int, req *models.RepairFormulaRequest) (*models.Account, error) {
+	account, err := r.GetByID(id)
+	if err != nil || account == nil {
+		return nil, err
+	}
+	allAccounts, err := r.GetAllIncludingArchived()
+	if err != nil {
+		return nil, err
+	}
+	accountMap := make(map[int]models.Account)
+	for _, a := range allAccounts {
+		accountMap[a.ID] = a
+	}
+
+	broken := make(map[int]bool)
+	for _, ref := range findBrokenReferences(account.Formula, allAccounts) {
+		broken[ref.AccountID] = true
+	}
+
+	remap := make(map[int]int)
+	for _, m := range req.Remap {
+		if !broken[m.FromAccountID] {
+			return nil, &ValidationError{Message: fmt.Sprintf("account %d is not a broken reference in this formula", m.FromAccountID)}
+		}
+		target, ok := accountMap[m.ToAccountID]
+		if !ok || target.IsArchived {
+			return nil, &ValidationError{Message: fmt.Sprintf("account %d does not exist or is archived", m.ToAccountID)}
+		}
+		remap[m.FromAccountID] = m.ToAccountID
+	}
+
+	formula := []models.FormulaItem{}
+	for _, item := range account.Formula {
+		if broken[item.AccountID] {
+			to, ok := remap[item.AccountID]
+			if !ok {
+				continue
+			}
+			item.AccountID = to
+		}
+		formula = append(formula, item)
+	}
+
+	isCalculated := account.IsCalculated && len(formula) > 0
+	if isCalculated {
+		if err := validation.ValidateFormulaForCycles(id, formula, allAccounts); err != nil {
+			return nil, &ValidationError{Message: err.Error()}
+		}
+	}
+
+	updated, err := r.UpdateFormula(id, isCalculated, formula)
+	if err != nil || updated == nil {
+		return nil, err
+	}
+	return r.GetByID(id)
+}
+
+// BulkUpdateFormulas validates a set of formula changes together and applies them in one
+// transaction. Every referenced account must exist, and the combined formulas must not
+// form a cycle. If any entry is invalid nothing is applied and Applied is false.
+func (r *AccountRepository) BulkUpdateFormulas(updates []models.BulkFormulaUpdate) (*models.BulkUpdateFormulasResponse, error) {
+	if len(updates) == 0 {
+		return nil, &ValidationError{Message: "formulas must not be empty"}
+	}
+
+	allAccounts, err := r.GetAllIncludingArchived()
+	if err != nil {
+		return nil, err
+	}
+	exists := make(map[int]bool)
+	for _, a := range allAccounts {
+		exists[a.ID] = true
+	}
+
+	response := &models.BulkUpdateFormulasResponse{}
+	seen := make(map[int]bool)
+	failed := false
+	for _, u := range updates {
+		result := models.BulkFormulaResult{AccountID: u.AccountID, Status: "valid"}
+		switch {
+		case !exists[u.AccountID]:
+			result.Error = "account not found"
+		case seen[u.AccountID]:
+			result.Error = "account appears more than once"
+		default:
+			if err := validation.ValidateFormulaOperations(u.Formula); err != nil {
+				result.Error = err.Error()
+				break
+			}
+			for _, item := range u.Formula {
+				if !exists[item.AccountID] {
+					result.Error = fmt.Sprintf("formula references account %d which does not exist", item.AccountID)
+					break
+				}
+			}
+		}
+		seen[u.AccountID] = true
+		if result.Error != "" {
+			result.Status = "error"
+			failed = true
+		}
+		response.Results = append(response.Results, result)
+	}
+
+	if !failed {
+		cycleErrs := validation.ValidateBulkFormulasForCycles(updates, allAccounts)
+		for i := range response.Results {
+			if err, ok := cycleErrs[response.Results[i].AccountID]; ok {
+				response.Results[i].Status = "error"
+				response.Results[i].Error = err.Error()
+				failed = true
+			}
+		}
+	}
+	if failed {
+		return response, nil
+	}
+
+	tx, err := r.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	for _, u := range updates {
+		var formulaJSON interface{}
+		if u.IsCalculated && len(u.Formula) > 0 {
+			formulaJSON, err = json.Marshal(u.Formula)
+			if err != nil {
+				return nil, fmt.Errorf("failed to marshal formula: %w", err)
+			}
+		}
+		_, err = tx.Exec(`
+			UPDATE account_balances
+			SET is_calculated = $1, formula = $2, updated_at = NOW()
+			WHERE id = $3
+		`, u.IsCalculated, formulaJSON, u.AccountID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to update formula for account %d: %w", u.AccountID, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	response.Applied = true
+	for i := range response.Results {
+		response.Results[i].Status = "updated"
+	}
+	return response, nil
+}
+
+// GetTotalsByTag returns the total balance of non-archived accounts for each tag, sorted
+// by tag name. An account with several tags counts toward each of them.
+func (r *AccountRepository) GetTotalsByTag() ([]models.TagTotal, error) {
+	accounts, err := r.GetAll()
+	if err != nil {
+		return nil, err
+	}
+
+	totals := make(map[string]*models.TagTotal)
+	for _, a := range accounts {
+		for _, tag := range a.Tags {
+			t, ok := totals[tag]
+			if !ok {
+				t = &models.TagTotal{Tag: tag, AccountIDs: []int{}}
+				totals[tag] = t
+			}
+			t.Total += a.CurrentBalance
+			t.AccountCount++
+			t.AccountIDs = append(t.AccountIDs, a.ID)
+		}
+	}
+
+	result := make([]models.TagTotal, 0, len(totals))
+	for _, t := range totals {
+		result = append(result, *t)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Tag < result[j].Tag })
+	return result, nil
+}
+
+// getTagsForAllAccounts returns tag names keyed by account ID
+func (r *AccountRepository) getTagsForAllAccounts() (map[int][]string, error) {
+	query := `
+		SELECT m.account_id, t.name FROM account_tag_memberships m
+		JOIN account_tags t ON m.tag_id = t.id
+		ORDER BY m.account_id, t.name
+	`
+	rows, err := r.db.Query(query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query tags: %w", err)
+	}
+	defer rows.Close()
+
+	tags := make(map[int][]string)
+	for rows.Next() {
+		var accountID int
+		var name string
+		if err := rows.Scan(&accountID, &name); err != nil {
+			return nil, fmt.Errorf("failed to scan tag: %w", err)
+		}
+		tags[accountID] = append(tags[accountID], name)
+	}
+	return tags, nil
+}
+
 func (r *AccountRepository) GetAllIncludingArchived() ([]models.Account, error) {
 	query := `
-		SELECT id, account_name, account_info, current_balance, is_archived, position, is_calculated, formula, created_at, updated_at
+		SELECT id, account_name, account_info, COALESCE(account_number, ''), current_balance, is_archived, position, is_calculated, formula, created_at, updated_at
 		FROM account_balances
 		ORDER BY account_name ASC
 	`
@@ -676,13 +1859,15 @@ func (r *AccountRepository) GetAllIncludingArchived() ([]models.Account, error)
 	var accounts []models.Account
 	for rows.Next() {
 		var a models.Account
+		var accountNumber string
 		var formulaJSON []byte
-		if err := rows.Scan(&a.ID, &a.AccountName, &a.AccountInfo, &a.CurrentBalance, &a.IsArchived, &a.Position, &a.IsCalculated, &formulaJSON, &a.CreatedAt, &a.UpdatedAt); err != nil {
+		if err := rows.Scan(&a.ID, &a.AccountName, &a.AccountInfo, &accountNumber, &a.CurrentBalance, &a.IsArchived, &a.Position, &a.IsCalculated, &formulaJSON, &a.CreatedAt, &a.UpdatedAt); err != nil {
 			return nil, fmt.Errorf("failed to scan account: %w", err)
 		}
 		if len(formulaJSON) > 0 {
 			json.Unmarshal(formulaJSON, &a.Formula)
 		}
+		a.MaskedAccountNumber = MaskAccountNumber(accountNumber)
 		accounts = append(accounts, a)
 	}
 
@@ -713,7 +1898,7 @@ func (r *AccountRepository) GetAllIncludingArchived() ([]models.Account, error)
 		SELECT m.account_id, m.group_id FROM account_group_memberships m
 		JOIN account_groups g ON m.group_id = g.id
 		WHERE g.entity_type = 'institution'
-		ORDER BY m.account_id
+		ORDER BY m.account_id, m.group_id
 	`
 	institutionRows, err := r.db.Query(institutionQuery)
 	if err != nil {
@@ -721,24 +1906,37 @@ func (r *AccountRepository) GetAllIncludingArchived() ([]models.Account, error)
 	}
 	defer institutionRows.Close()
 
-	institutionMemberships := make(map[int]int)
+	institutionMemberships := make(map[int][]int)
 	for institutionRows.Next() {
 		var accountID, institutionID int
 		if err := institutionRows.Scan(&accountID, &institutionID); err != nil {
 			return nil, fmt.Errorf("failed to scan institution membership: %w", err)
 		}
-		institutionMemberships[accountID] = institutionID
+		institutionMemberships[accountID] = append(institutionMemberships[accountID], institutionID)
+	}
+
+	tags, err := r.getTagsForAllAccounts()
+	if err != nil {
+		return nil, err
 	}
 
-	// Assign group IDs and institution ID to accounts
+	// Assign group IDs, institution ID and tags to accounts
 	for i := range accounts {
+		if accountTags, ok := tags[accounts[i].ID]; ok {
+			accounts[i].Tags = accountTags
+		} else {
+			accounts[i].Tags = []string{}
+		}
 		if groupIDs, ok := memberships[accounts[i].ID]; ok {
 			accounts[i].GroupIDs = groupIDs
 		} else {
 			accounts[i].GroupIDs = []int{}
 		}
-		if institutionID, ok := institutionMemberships[accounts[i].ID]; ok {
-			accounts[i].InstitutionID = &institutionID
+		if institutionIDs, ok := institutionMemberships[accounts[i].ID]; ok {
+			accounts[i].InstitutionID = &institutionIDs[0]
+			accounts[i].InstitutionIDs = institutionIDs
+		} else {
+			accounts[i].InstitutionIDs = []int{}
 		}
 	}
 
@@ -753,12 +1951,13 @@ func (r *AccountRepository) Unarchive(id int) (*models.Account, error) {
 		UPDATE account_balances
 		SET is_archived = false, updated_at = NOW()
 		WHERE id = $1
-		RETURNING id, account_name, account_info, current_balance, is_archived, position, is_calculated, formula, created_at, updated_at
+		RETURNING id, account_name, account_info, COALESCE(account_number, ''), current_balance, is_archived, position, is_calculated, formula, created_at, updated_at
 	`
 	var a models.Account
+	var accountNumber string
 	var formulaJSON []byte
 	err := r.db.QueryRow(query, id).Scan(
-		&a.ID, &a.AccountName, &a.AccountInfo, &a.CurrentBalance, &a.IsArchived, &a.Position, &a.IsCalculated, &formulaJSON, &a.CreatedAt, &a.UpdatedAt,
+		&a.ID, &a.AccountName, &a.AccountInfo, &accountNumber, &a.CurrentBalance, &a.IsArchived, &a.Position, &a.IsCalculated, &formulaJSON, &a.CreatedAt, &a.UpdatedAt,
 	)
 	if err == sql.ErrNoRows {
 		return nil, nil
@@ -769,10 +1968,230 @@ func (r *AccountRepository) Unarchive(id int) (*models.Account, error) {
 	if len(formulaJSON) > 0 {
 		json.Unmarshal(formulaJSON, &a.Formula)
 	}
+	a.MaskedAccountNumber = MaskAccountNumber(accountNumber)
+	a.GroupIDs = []int{}
+	return &a, nil
+}
+
+// BulkSetArchived archives or unarchives the given accounts in a single transaction.
+// When archiving without force, accounts referenced by the formula of a non-archived
+// calculated account, group, institution or dashboard are skipped. A dependent in the
+// same batch only unblocks an account once it has actually been archived.
+func (r *AccountRepository) BulkSetArchived(ids []int, archived bool, force bool) (*models.BulkArchiveResponse, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	response := &models.BulkArchiveResponse{
+		Accounts: []models.Account{},
+		Skipped:  []models.BulkArchiveSkipped{},
+	}
+	var pending []int
+	seen := make(map[int]bool)
+	for _, id := range ids {
+		if !seen[id] {
+			seen[id] = true
+			pending = append(pending, id)
+		}
+	}
+
+	// Repeat until a pass archives nothing, so that accounts blocked only by
+	// dependents archived later in the batch get another chance
+	blocked := make(map[int]string)
+	for progress := true; progress && len(pending) > 0; {
+		progress = false
+		var retry []int
+		for _, id := range pending {
+			if archived && !force {
+				dependents, err := r.formulaDependents(tx, id)
+				if err != nil {
+					return nil, err
+				}
+				if len(dependents) > 0 {
+					blocked[id] = "used in formula of " + strings.Join(dependents, ", ")
+					retry = append(retry, id)
+					continue
+				}
+			}
+
+			a, err := r.setArchivedTx(tx, id, archived)
+			if err != nil {
+				return nil, err
+			}
+			if a == nil {
+				response.Skipped = append(response.Skipped, models.BulkArchiveSkipped{ID: id, Reason: "account not found"})
+				continue
+			}
+			delete(blocked, id)
+			response.Accounts = append(response.Accounts, *a)
+			progress = true
+		}
+		pending = retry
+	}
+	for _, id := range pending {
+		response.Skipped = append(response.Skipped, models.BulkArchiveSkipped{ID: id, Reason: blocked[id]})
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return response, nil
+}
+
+// setArchivedTx sets the archived flag of one account within tx, returning nil if
+// the account does not exist
+func (r *AccountRepository) setArchivedTx(tx *sql.Tx, id int, archived bool) (*models.Account, error) {
+	query := `
+		UPDATE account_balances
+		SET is_archived = $1, updated_at = NOW()
+		WHERE id = $2
+		RETURNING id, account_name, account_info, COALESCE(account_number, ''), current_balance, is_archived, position, is_calculated, formula, created_at, updated_at
+	`
+	var a models.Account
+	var accountNumber string
+	var formulaJSON []byte
+	err := tx.QueryRow(query, archived, id).Scan(
+		&a.ID, &a.AccountName, &a.AccountInfo, &accountNumber, &a.CurrentBalance, &a.IsArchived, &a.Position, &a.IsCalculated, &formulaJSON, &a.CreatedAt, &a.UpdatedAt,
+	)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to update account %d: %w", id, err)
+	}
+	if len(formulaJSON) > 0 {
+		json.Unmarshal(formulaJSON, &a.Formula)
+	}
+	a.MaskedAccountNumber = MaskAccountNumber(accountNumber)
 	a.GroupIDs = []int{}
 	return &a, nil
 }
 
+// formulaDependents returns descriptions of non-archived calculated entities whose
+// formula references the account
+func (r *AccountRepository) formulaDependents(tx *sql.Tx, accountID int) ([]string, error) {
+	query := `
+		SELECT 'account', id, account_name FROM account_balances
+		WHERE is_calculated = true AND is_archived = false AND formula @> $1::jsonb
+		UNION ALL
+		SELECT entity_type, id, name FROM account_groups
+		WHERE is_calculated = true AND is_archived = false AND formula @> $1::jsonb
+		UNION ALL
+		SELECT 'dashboard', id, name FROM dashboards
+		WHERE is_calculated = true AND formula @> $2::jsonb
+	`
+	accountRef := fmt.Sprintf(`[{"account_id": %d}]`, accountID)
+	dashboardRef := fmt.Sprintf(`[{"type": "account", "id": %d}]`, accountID)
+	rows, err := tx.Query(query, accountRef, dashboardRef)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query formula dependents: %w", err)
+	}
+	defer rows.Close()
+
+	var dependents []string
+	for rows.Next() {
+		var entityType, name string
+		var id int
+		if err := rows.Scan(&entityType, &id, &name); err != nil {
+			return nil, fmt.Errorf("failed to scan formula dependent: %w", err)
+		}
+		dependents = append(dependents, fmt.Sprintf("%s %q", entityType, name))
+	}
+	return dependents, rows.Err()
+}
+
+// GetReferences returns the groups, institutions, dashboards and calculated accounts
+// that use the account through membership, formula, or dashboard items
+func (r *AccountRepository) GetReferences(id int) (*models.AccountReferences, error) {
+	refs := &models.AccountReferences{
+		Groups:             []models.EntityReference{},
+		Institutions:       []models.EntityReference{},
+		Dashboards:         []models.EntityReference{},
+		CalculatedAccounts: []models.EntityReference{},
+	}
+	accountRef := fmt.Sprintf(`[{"account_id": %d}]`, id)
+	dashboardRef := fmt.Sprintf(`[{"type": "account", "id": %d}]`, id)
+
+	// Groups and institutions, via membership or formula
+	groupQuery := `
+		SELECT g.id, g.name, g.entity_type, 'membership'
+		FROM account_group_memberships m
+		JOIN account_groups g ON m.group_id = g.id
+		WHERE m.account_id = $1
+		UNION ALL
+		SELECT id, name, entity_type, 'formula'
+		FROM account_groups
+		WHERE is_calculated = true AND formula @> $2::jsonb
+		ORDER BY 2
+	`
+	groupRows, err := r.db.Query(groupQuery, id, accountRef)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query group references: %w", err)
+	}
+	defer groupRows.Close()
+	for groupRows.Next() {
+		var ref models.EntityReference
+		var entityType string
+		if err := groupRows.Scan(&ref.ID, &ref.Name, &entityType, &ref.Via); err != nil {
+			return nil, fmt.Errorf("failed to scan group reference: %w", err)
+		}
+		if entityType == "institution" {
+			refs.Institutions = append(refs.Institutions, ref)
+		} else {
+			refs.Groups = append(refs.Groups, ref)
+		}
+	}
+
+	// Dashboards, via items or formula
+	dashboardQuery := `
+		SELECT d.id, d.name, 'item'
+		FROM dashboard_items di
+		JOIN dashboards d ON di.dashboard_id = d.id
+		WHERE di.item_type = 'account' AND di.item_id = $1
+		UNION ALL
+		SELECT id, name, 'formula'
+		FROM dashboards
+		WHERE is_calculated = true AND formula @> $2::jsonb
+		ORDER BY 2
+	`
+	dashboardRows, err := r.db.Query(dashboardQuery, id, dashboardRef)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query dashboard references: %w", err)
+	}
+	defer dashboardRows.Close()
+	for dashboardRows.Next() {
+		var ref models.EntityReference
+		if err := dashboardRows.Scan(&ref.ID, &ref.Name, &ref.Via); err != nil {
+			return nil, fmt.Errorf("failed to scan dashboard reference: %w", err)
+		}
+		refs.Dashboards = append(refs.Dashboards, ref)
+	}
+
+	// Calculated accounts whose formula uses this account
+	accountQuery := `
+		SELECT id, account_name, 'formula'
+		FROM account_balances
+		WHERE is_calculated = true AND formula @> $1::jsonb
+		ORDER BY account_name
+	`
+	accountRows, err := r.db.Query(accountQuery, accountRef)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query account references: %w", err)
+	}
+	defer accountRows.Close()
+	for accountRows.Next() {
+		var ref models.EntityReference
+		if err := accountRows.Scan(&ref.ID, &ref.Name, &ref.Via); err != nil {
+			return nil, fmt.Errorf("failed to scan account reference: %w", err)
+		}
+		refs.CalculatedAccounts = append(refs.CalculatedAccounts, ref)
+	}
+
+	return refs, nil
+}
+
 func (r *AccountRepository) Delete(id int) error {
 	tx, err := r.db.Begin()
 	if err != nil {
@@ -842,16 +2261,62 @@ func (r *AccountRepository) getAllAccountsTx(tx *sql.Tx) ([]models.Account, erro
 	return accounts, nil
 }
 
-// calculateFormulaBalance calculates the balance from a formula using provided balance map
-func calculateFormulaBalance(formula []models.FormulaItem, balanceMap map[int]float64) float64 {
-	var total float64
+// calculateFormulaBalance calculates the balance from a formula using provided balance
+// map, with the division by zero and missing reference flags of evaluateFormula
+func calculateFormulaBalance(formula []models.FormulaItem, balanceMap map[int]float64) (float64, bool, bool) {
+	return evaluateFormula(formula, balanceMap)
+}
+
+// evaluateFormula evaluates formula items left-to-right. The first item seeds the
+// running total (negated if its operation is "subtract"); each following item's
+// coefficient * balance is then added, subtracted, multiplied or divided into it.
+// Items referencing accounts missing from balanceMap (deleted or archived) are
+// skipped and reported with missingRefs, rather than feeding 0 into a multiply or
+// divide; if the first item is skipped, the next one seeds the total. Dividing by
+// zero yields 0 and reports divisionByZero.
+func evaluateFormula(formula []models.FormulaItem, balanceMap map[int]float64) (total float64, divisionByZero, missingRefs bool) {
+	seeded := false
+	for _, item := range formula {
+		balance, ok := balanceMap[item.AccountID]
+		if !ok {
+			missingRefs = true
+			continue
+		}
+		term := item.Coefficient * balance
+		if !seeded {
+			if item.Operation == models.FormulaOpSubtract {
+				term = -term
+			}
+			total = term
+			seeded = true
+			continue
+		}
+		switch item.Operation {
+		case models.FormulaOpSubtract:
+			total -= term
+		case models.FormulaOpMultiply:
+			total *= term
+		case models.FormulaOpDivide:
+			if term == 0 {
+				return 0, true, missingRefs
+			}
+			total /= term
+		default:
+			total += term
+		}
+	}
+	return total, false, missingRefs
+}
+
+// formulaBalances builds a balance map of the accounts referenced by a formula
+func formulaBalances(formula []models.FormulaItem, accountMap map[int]*models.Account) map[int]float64 {
+	balances := make(map[int]float64, len(formula))
 	for _, item := range formula {
-		if balance, ok := balanceMap[item.AccountID]; ok {
-			total += item.Coefficient * balance
+		if acc, ok := accountMap[item.AccountID]; ok {
+			balances[item.AccountID] = acc.CurrentBalance
 		}
-		// If account not found in map, it contributes 0 (handles deleted accounts)
 	}
-	return total
+	return balances
 }
 
 // insertHistoryRecordsTx batch inserts history records efficiently
@@ -940,6 +2405,33 @@ func (r *AccountRepository) findAffectedGroups(tx *sql.Tx, accountID int, depend
 		}
 	}
 
+	// Parents of affected groups include their children's totals, so they change too
+	for groupID := range groupIDSet {
+		ancestorRows, err := tx.Query(`
+			WITH RECURSIVE ancestors AS (
+				SELECT parent_group_id AS id FROM account_groups
+				WHERE id = $1 AND parent_group_id IS NOT NULL
+				UNION
+				SELECT g.parent_group_id FROM account_groups g
+				JOIN ancestors a ON g.id = a.id
+				WHERE g.parent_group_id IS NOT NULL
+			)
+			SELECT id FROM ancestors
+		`, groupID)
+		if err != nil {
+			return nil, err
+		}
+		for ancestorRows.Next() {
+			var ancestorID int
+			if err := ancestorRows.Scan(&ancestorID); err != nil {
+				ancestorRows.Close()
+				return nil, err
+			}
+			groupIDSet[ancestorID] = true
+		}
+		ancestorRows.Close()
+	}
+
 	// Convert set to slice
 	var groupIDs []int
 	for id := range groupIDSet {
@@ -948,6 +2440,75 @@ func (r *AccountRepository) findAffectedGroups(tx *sql.Tx, accountID int, depend
 	return groupIDs, nil
 }
 
+// childGroupsBalanceTx returns the summed totals of a group's non-archived child groups,
+// including their own descendants. visited guards against cycles in the parent chain.
+func (r *AccountRepository) childGroupsBalanceTx(tx *sql.Tx, groupID int, balanceMap map[int]float64, visited map[int]bool) (float64, error) {
+	rows, err := tx.Query(`
+		SELECT id, is_calculated, formula FROM account_groups
+		WHERE parent_group_id = $1 AND is_archived = false AND entity_type = 'group'
+	`, groupID)
+	if err != nil {
+		return 0, err
+	}
+
+	type childGroup struct {
+		id           int
+		isCalculated bool
+		formulaJSON  []byte
+	}
+	var children []childGroup
+	for rows.Next() {
+		var c childGroup
+		if err := rows.Scan(&c.id, &c.isCalculated, &c.formulaJSON); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		children = append(children, c)
+	}
+	rows.Close()
+
+	var total float64
+	for _, c := range children {
+		if visited[c.id] {
+			continue
+		}
+		visited[c.id] = true
+
+		if c.isCalculated && len(c.formulaJSON) > 0 {
+			var formula []models.FormulaItem
+			json.Unmarshal(c.formulaJSON, &formula)
+			balance, _, _ := calculateFormulaBalance(formula, balanceMap)
+			total += balance
+			continue
+		}
+
+		memberRows, err := tx.Query(`
+			SELECT account_id FROM account_group_memberships WHERE group_id = $1
+		`, c.id)
+		if err != nil {
+			return 0, err
+		}
+		for memberRows.Next() {
+			var accountID int
+			if err := memberRows.Scan(&accountID); err != nil {
+				memberRows.Close()
+				return 0, err
+			}
+			if balance, ok := balanceMap[accountID]; ok {
+				total += balance
+			}
+		}
+		memberRows.Close()
+
+		childBalance, err := r.childGroupsBalanceTx(tx, c.id, balanceMap, visited)
+		if err != nil {
+			return 0, err
+		}
+		total += childBalance
+	}
+	return total, nil
+}
+
 // insertGroupHistoryRecordsTx inserts history records for multiple groups and institutions
 func (r *AccountRepository) insertGroupHistoryRecordsTx(tx *sql.Tx, groupIDs []int, balanceMap map[int]float64) error {
 	for _, groupID := range groupIDs {
@@ -973,11 +2534,7 @@ func (r *AccountRepository) insertGroupHistoryRecordsTx(tx *sql.Tx, groupIDs []i
 		if isCalculated && len(formulaJSON) > 0 {
 			var formula []models.FormulaItem
 			json.Unmarshal(formulaJSON, &formula)
-			for _, item := range formula {
-				if balance, ok := balanceMap[item.AccountID]; ok {
-					totalBalance += item.Coefficient * balance
-				}
-			}
+			totalBalance, _, _ = calculateFormulaBalance(formula, balanceMap)
 		} else {
 			// Sum of member account balances
 			memberRows, err := tx.Query(`
@@ -997,6 +2554,13 @@ func (r *AccountRepository) insertGroupHistoryRecordsTx(tx *sql.Tx, groupIDs []i
 				}
 			}
 			memberRows.Close()
+
+			// Add totals of nested child groups
+			childBalance, err := r.childGroupsBalanceTx(tx, groupID, balanceMap, map[int]bool{groupID: true})
+			if err != nil {
+				return err
+			}
+			totalBalance += childBalance
 		}
 
 		// Insert history record with the correct entity_type ('group' or 'institution')
@@ -1122,6 +2686,27 @@ func (r *AccountRepository) findAffectedDashboards(tx *sql.Tx, accountID int, de
 		}
 	}
 
+	// Dashboards whose formula references an affected dashboard are affected too
+	dependents, err := r.dashboardFormulaReferencesTx(tx)
+	if err != nil {
+		return nil, err
+	}
+	for changed := true; changed; {
+		changed = false
+		for dashboardID, referenced := range dependents {
+			if dashboardIDSet[dashboardID] {
+				continue
+			}
+			for _, refID := range referenced {
+				if dashboardIDSet[refID] {
+					dashboardIDSet[dashboardID] = true
+					changed = true
+					break
+				}
+			}
+		}
+	}
+
 	// Convert set to slice
 	var dashboardIDs []int
 	for id := range dashboardIDSet {
@@ -1130,18 +2715,120 @@ func (r *AccountRepository) findAffectedDashboards(tx *sql.Tx, accountID int, de
 	return dashboardIDs, nil
 }
 
+// dashboardFormulaReferencesTx maps each calculated dashboard to the dashboards its formula references
+func (r *AccountRepository) dashboardFormulaReferencesTx(tx *sql.Tx) (map[int][]int, error) {
+	rows, err := tx.Query(`
+		SELECT id, formula FROM dashboards
+		WHERE is_calculated = true AND formula IS NOT NULL
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	references := make(map[int][]int)
+	for rows.Next() {
+		var dashboardID int
+		var formulaJSON []byte
+		if err := rows.Scan(&dashboardID, &formulaJSON); err != nil {
+			return nil, err
+		}
+
+		var formula []models.DashboardFormulaItem
+		if err := json.Unmarshal(formulaJSON, &formula); err != nil {
+			continue
+		}
+		for _, item := range formula {
+			if item.Type == "dashboard" {
+				references[dashboardID] = append(references[dashboardID], item.ID)
+			}
+		}
+	}
+	return references, rows.Err()
+}
+
+// dashboardBalanceTx calculates a dashboard's total from its formula or items.
+// visiting guards against cycles between dashboards referencing each other.
+func (r *AccountRepository) dashboardBalanceTx(tx *sql.Tx, dashboardID int, balanceMap map[int]float64, groupBalanceMap map[int]float64, visiting map[int]bool) (float64, error) {
+	visiting[dashboardID] = true
+	defer delete(visiting, dashboardID)
+
+	var isCalculated bool
+	var formulaJSON []byte
+	err := tx.QueryRow(`
+		SELECT is_calculated, formula
+		FROM dashboards WHERE id = $1
+	`, dashboardID).Scan(&isCalculated, &formulaJSON)
+	if err == sql.ErrNoRows {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	var totalBalance float64
+	if isCalculated && len(formulaJSON) > 0 {
+		// Calculate from formula
+		var formula []models.DashboardFormulaItem
+		json.Unmarshal(formulaJSON, &formula)
+		for _, item := range formula {
+			switch item.Type {
+			case "account":
+				if balance, ok := balanceMap[item.ID]; ok {
+					totalBalance += item.Coefficient * balance
+				}
+			case "group", "institution":
+				if balance, ok := groupBalanceMap[item.ID]; ok {
+					totalBalance += item.Coefficient * balance
+				}
+			case "dashboard":
+				if visiting[item.ID] {
+					continue
+				}
+				balance, err := r.dashboardBalanceTx(tx, item.ID, balanceMap, groupBalanceMap, visiting)
+				if err != nil {
+					return 0, err
+				}
+				totalBalance += item.Coefficient * balance
+			}
+		}
+		return totalBalance, nil
+	}
+
+	// Sum of dashboard item balances
+	itemRows, err := tx.Query(`
+		SELECT item_type, item_id FROM dashboard_items WHERE dashboard_id = $1
+	`, dashboardID)
+	if err != nil {
+		return 0, err
+	}
+	defer itemRows.Close()
+	for itemRows.Next() {
+		var itemType string
+		var itemID int
+		if err := itemRows.Scan(&itemType, &itemID); err != nil {
+			return 0, err
+		}
+		switch itemType {
+		case "account":
+			if balance, ok := balanceMap[itemID]; ok {
+				totalBalance += balance
+			}
+		case "group", "institution":
+			if balance, ok := groupBalanceMap[itemID]; ok {
+				totalBalance += balance
+			}
+		}
+	}
+	return totalBalance, itemRows.Err()
+}
+
 // insertDashboardHistoryRecordsTx inserts history records for multiple dashboards
 func (r *AccountRepository) insertDashboardHistoryRecordsTx(tx *sql.Tx, dashboardIDs []int, balanceMap map[int]float64, groupBalanceMap map[int]float64) error {
 	for _, dashboardID := range dashboardIDs {
 		// Get dashboard info
 		var dashboardName string
-		var isCalculated bool
-		var formulaJSON []byte
-
-		err := tx.QueryRow(`
-			SELECT name, is_calculated, formula
-			FROM dashboards WHERE id = $1
-		`, dashboardID).Scan(&dashboardName, &isCalculated, &formulaJSON)
+		err := tx.QueryRow(`SELECT name FROM dashboards WHERE id = $1`, dashboardID).Scan(&dashboardName)
 		if err == sql.ErrNoRows {
 			continue
 		}
@@ -1149,50 +2836,9 @@ func (r *AccountRepository) insertDashboardHistoryRecordsTx(tx *sql.Tx, dashboar
 			return err
 		}
 
-		var totalBalance float64
-		if isCalculated && len(formulaJSON) > 0 {
-			// Calculate from formula
-			var formula []models.DashboardFormulaItem
-			json.Unmarshal(formulaJSON, &formula)
-			for _, item := range formula {
-				switch item.Type {
-				case "account":
-					if balance, ok := balanceMap[item.ID]; ok {
-						totalBalance += item.Coefficient * balance
-					}
-				case "group", "institution":
-					if balance, ok := groupBalanceMap[item.ID]; ok {
-						totalBalance += item.Coefficient * balance
-					}
-				}
-			}
-		} else {
-			// Sum of dashboard item balances
-			itemRows, err := tx.Query(`
-				SELECT item_type, item_id FROM dashboard_items WHERE dashboard_id = $1
-			`, dashboardID)
-			if err != nil {
-				return err
-			}
-			for itemRows.Next() {
-				var itemType string
-				var itemID int
-				if err := itemRows.Scan(&itemType, &itemID); err != nil {
-					itemRows.Close()
-					return err
-				}
-				switch itemType {
-				case "account":
-					if balance, ok := balanceMap[itemID]; ok {
-						totalBalance += balance
-					}
-				case "group", "institution":
-					if balance, ok := groupBalanceMap[itemID]; ok {
-						totalBalance += balance
-					}
-				}
-			}
-			itemRows.Close()
+		totalBalance, err := r.dashboardBalanceTx(tx, dashboardID, balanceMap, groupBalanceMap, make(map[int]bool))
+		if err != nil {
+			return err
 		}
 
 		// Insert history record
@@ -1230,11 +2876,7 @@ func (r *AccountRepository) calculateGroupBalances(tx *sql.Tx, groupIDs []int, b
 		if isCalculated && len(formulaJSON) > 0 {
 			var formula []models.FormulaItem
 			json.Unmarshal(formulaJSON, &formula)
-			for _, item := range formula {
-				if balance, ok := balanceMap[item.AccountID]; ok {
-					totalBalance += item.Coefficient * balance
-				}
-			}
+			totalBalance, _, _ = calculateFormulaBalance(formula, balanceMap)
 		} else {
 			memberRows, err := tx.Query(`
 				SELECT account_id FROM account_group_memberships WHERE group_id = $1
@@ -1253,6 +2895,13 @@ func (r *AccountRepository) calculateGroupBalances(tx *sql.Tx, groupIDs []int, b
 				}
 			}
 			memberRows.Close()
+
+			// Add totals of nested child groups
+			childBalance, err := r.childGroupsBalanceTx(tx, groupID, balanceMap, map[int]bool{groupID: true})
+			if err != nil {
+				return nil, err
+			}
+			totalBalance += childBalance
 		}
 
 		groupBalanceMap[groupID] = totalBalance
@@ -1263,6 +2912,28 @@ func (r *AccountRepository) calculateGroupBalances(tx *sql.Tx, groupIDs []int, b
 
 // expandGroupBalanceMap adds balances for any groups/institutions referenced by dashboards that aren't already in the map
 func (r *AccountRepository) expandGroupBalanceMap(tx *sql.Tx, groupBalanceMap map[int]float64, balanceMap map[int]float64, dashboardIDs []int) (map[int]float64, error) {
+	// Include dashboards referenced (transitively) by these dashboards' formulas,
+	// since their totals are recomputed as part of the referencing dashboard
+	references, err := r.dashboardFormulaReferencesTx(tx)
+	if err != nil {
+		return nil, err
+	}
+	dashboardSet := make(map[int]bool)
+	queue := append([]int{}, dashboardIDs...)
+	for len(queue) > 0 {
+		dashboardID := queue[0]
+		queue = queue[1:]
+		if dashboardSet[dashboardID] {
+			continue
+		}
+		dashboardSet[dashboardID] = true
+		queue = append(queue, references[dashboardID]...)
+	}
+	dashboardIDs = make([]int, 0, len(dashboardSet))
+	for dashboardID := range dashboardSet {
+		dashboardIDs = append(dashboardIDs, dashboardID)
+	}
+
 	// Collect all group/institution IDs referenced by these dashboards
 	groupIDSet := make(map[int]bool)
 
@@ -1323,11 +2994,7 @@ func (r *AccountRepository) expandGroupBalanceMap(tx *sql.Tx, groupBalanceMap ma
 		if isCalculated && len(formulaJSON) > 0 {
 			var formula []models.FormulaItem
 			json.Unmarshal(formulaJSON, &formula)
-			for _, item := range formula {
-				if balance, ok := balanceMap[item.AccountID]; ok {
-					totalBalance += item.Coefficient * balance
-				}
-			}
+			totalBalance, _, _ = calculateFormulaBalance(formula, balanceMap)
 		} else {
 			memberRows, err := tx.Query(`
 				SELECT account_id FROM account_group_memberships WHERE group_id = $1
@@ -1346,6 +3013,13 @@ func (r *AccountRepository) expandGroupBalanceMap(tx *sql.Tx, groupBalanceMap ma
 				}
 			}
 			memberRows.Close()
+
+			// Add totals of nested child groups
+			childBalance, err := r.childGroupsBalanceTx(tx, groupID, balanceMap, map[int]bool{groupID: true})
+			if err != nil {
+				return nil, err
+			}
+			totalBalance += childBalance
 		}
 
 		groupBalanceMap[groupID] = totalBalance
@@ -4,8 +4,10 @@ import (
 	"database/sql"
 	"encoding/json"
 	"fmt"
+	"time"
 
 	"finance-tracker/internal/models"
+	"finance-tracker/internal/validation"
 )
 
 type DashboardRepository struct {
@@ -89,6 +91,15 @@ func (r *DashboardRepository) GetByID(id int) (*models.Dashboard, error) {
 }
 
 func (r *DashboardRepository) GetWithItems(id int) (*models.DashboardWithItems, error) {
+	return r.getWithItems(id, make(map[int]bool))
+}
+
+// getWithItems builds a dashboard with its items. visiting holds the dashboards whose
+// totals are being resolved, so nested dashboard references can't recurse forever.
+func (r *DashboardRepository) getWithItems(id int, visiting map[int]bool) (*models.DashboardWithItems, error) {
+	visiting[id] = true
+	defer delete(visiting, id)
+
 	dashboard, err := r.GetByID(id)
 	if err != nil {
 		return nil, err
@@ -99,7 +110,7 @@ func (r *DashboardRepository) GetWithItems(id int) (*models.DashboardWithItems,
 
 	// Get ALL non-archived accounts for balance resolution
 	allAccountsQuery := `
-		SELECT id, account_name, account_info, current_balance, is_archived, position, is_calculated, formula, created_at, updated_at
+		SELECT id, account_name, account_info, current_balance, is_archived, position, is_calculated, formula, COALESCE(account_number, ''), created_at, updated_at
 		FROM account_balances
 		WHERE is_archived = false
 	`
@@ -113,13 +124,15 @@ func (r *DashboardRepository) GetWithItems(id int) (*models.DashboardWithItems,
 	for allRows.Next() {
 		var a models.Account
 		var formulaJSON []byte
-		err := allRows.Scan(&a.ID, &a.AccountName, &a.AccountInfo, &a.CurrentBalance, &a.IsArchived, &a.Position, &a.IsCalculated, &formulaJSON, &a.CreatedAt, &a.UpdatedAt)
+		var accountNumber string
+		err := allRows.Scan(&a.ID, &a.AccountName, &a.AccountInfo, &a.CurrentBalance, &a.IsArchived, &a.Position, &a.IsCalculated, &formulaJSON, &accountNumber, &a.CreatedAt, &a.UpdatedAt)
 		if err != nil {
 			return nil, fmt.Errorf("failed to scan account: %w", err)
 		}
 		if len(formulaJSON) > 0 {
 			json.Unmarshal(formulaJSON, &a.Formula)
 		}
+		a.MaskedAccountNumber = MaskAccountNumber(accountNumber)
 		a.GroupIDs = []int{}
 		allAccounts = append(allAccounts, a)
 	}
@@ -158,14 +171,31 @@ func (r *DashboardRepository) GetWithItems(id int) (*models.DashboardWithItems,
 	}
 	defer itemRows.Close()
 
-	var items []models.ListItem
-	var totalBalance float64
-
+	var dashboardItems []models.DashboardItem
+	dashboardGroupIDs := make(map[int]bool)
 	for itemRows.Next() {
 		var di models.DashboardItem
 		if err := itemRows.Scan(&di.ID, &di.DashboardID, &di.ItemType, &di.ItemID, &di.Position); err != nil {
 			return nil, fmt.Errorf("failed to scan dashboard item: %w", err)
 		}
+		dashboardItems = append(dashboardItems, di)
+		if di.ItemType == "group" {
+			dashboardGroupIDs[di.ItemID] = true
+		}
+	}
+
+	var items []models.ListItem
+	var totalBalance float64
+
+	for _, di := range dashboardItems {
+		// A group whose parent is also on the dashboard is rendered nested under the parent
+		if di.ItemType == "group" {
+			if group, ok := groupsMap[di.ItemID]; ok && group.ParentGroupID != nil && dashboardGroupIDs[*group.ParentGroupID] {
+				if _, parentOK := groupsMap[*group.ParentGroupID]; parentOK {
+					continue
+				}
+			}
+		}
 
 		if di.ItemType == "account" {
 			if acc, ok := accountMap[di.ItemID]; ok {
@@ -211,6 +241,17 @@ func (r *DashboardRepository) GetWithItems(id int) (*models.DashboardWithItems,
 				if institution, ok := institutionsMap[item.ID]; ok {
 					totalBalance += item.Coefficient * institution.TotalBalance
 				}
+			case "dashboard":
+				if visiting[item.ID] {
+					continue
+				}
+				nested, err := r.getWithItems(item.ID, visiting)
+				if err != nil {
+					return nil, fmt.Errorf("failed to resolve dashboard %d: %w", item.ID, err)
+				}
+				if nested != nil {
+					totalBalance += item.Coefficient * nested.TotalBalance
+				}
 			}
 		}
 	}
@@ -225,7 +266,7 @@ func (r *DashboardRepository) GetWithItems(id int) (*models.DashboardWithItems,
 func (r *DashboardRepository) getGroupsWithAccounts(accountMap map[int]*models.Account) (map[int]*models.AccountGroupWithAccounts, error) {
 	// Get all non-archived groups (excluding institutions)
 	groupsQuery := `
-		SELECT id, name, description, color, position, is_archived, is_calculated, formula, entity_type, created_at, updated_at
+		SELECT id, name, description, color, position, is_archived, is_calculated, formula, entity_type, parent_group_id, created_at, updated_at
 		FROM account_groups
 		WHERE is_archived = false AND entity_type = 'group'
 	`
@@ -239,7 +280,7 @@ func (r *DashboardRepository) getGroupsWithAccounts(accountMap map[int]*models.A
 	for groupRows.Next() {
 		var g models.AccountGroup
 		var formulaJSON []byte
-		err := groupRows.Scan(&g.ID, &g.Name, &g.Description, &g.Color, &g.Position, &g.IsArchived, &g.IsCalculated, &formulaJSON, &g.EntityType, &g.CreatedAt, &g.UpdatedAt)
+		err := groupRows.Scan(&g.ID, &g.Name, &g.Description, &g.Color, &g.Position, &g.IsArchived, &g.IsCalculated, &formulaJSON, &g.EntityType, &g.ParentGroupID, &g.CreatedAt, &g.UpdatedAt)
 		if err != nil {
 			return nil, err
 		}
@@ -281,12 +322,9 @@ func (r *DashboardRepository) getGroupsWithAccounts(accountMap map[int]*models.A
 		accounts := groupAccounts[g.ID]
 
 		var totalBalance float64
+		var divisionByZero, missingRefs bool
 		if g.IsCalculated && len(g.Formula) > 0 {
-			for _, item := range g.Formula {
-				if acc, ok := accountMap[item.AccountID]; ok {
-					totalBalance += item.Coefficient * acc.CurrentBalance
-				}
-			}
+			totalBalance, divisionByZero, missingRefs = calculateFormulaBalance(g.Formula, formulaBalances(g.Formula, accountMap))
 		} else {
 			for _, a := range accounts {
 				totalBalance += a.CurrentBalance
@@ -294,19 +332,23 @@ func (r *DashboardRepository) getGroupsWithAccounts(accountMap map[int]*models.A
 		}
 
 		result[g.ID] = &models.AccountGroupWithAccounts{
-			AccountGroup: g,
-			TotalBalance: totalBalance,
-			Accounts:     accounts,
+			AccountGroup:      g,
+			TotalBalance:      totalBalance,
+			DivisionByZero:    divisionByZero,
+			MissingReferences: missingRefs,
+			Accounts:          accounts,
 		}
 	}
 
+	nestChildGroups(result)
+
 	return result, nil
 }
 
 func (r *DashboardRepository) getInstitutionsWithAccounts(accountMap map[int]*models.Account) (map[int]*models.AccountGroupWithAccounts, error) {
 	// Get all non-archived institutions
 	institutionsQuery := `
-		SELECT id, name, description, color, position, is_archived, is_calculated, formula, entity_type, created_at, updated_at
+		SELECT id, name, description, color, position, is_archived, is_calculated, formula, entity_type, parent_group_id, created_at, updated_at
 		FROM account_groups
 		WHERE is_archived = false AND entity_type = 'institution'
 	`
@@ -320,7 +362,7 @@ func (r *DashboardRepository) getInstitutionsWithAccounts(accountMap map[int]*mo
 	for institutionRows.Next() {
 		var i models.AccountGroup
 		var formulaJSON []byte
-		err := institutionRows.Scan(&i.ID, &i.Name, &i.Description, &i.Color, &i.Position, &i.IsArchived, &i.IsCalculated, &formulaJSON, &i.EntityType, &i.CreatedAt, &i.UpdatedAt)
+		err := institutionRows.Scan(&i.ID, &i.Name, &i.Description, &i.Color, &i.Position, &i.IsArchived, &i.IsCalculated, &formulaJSON, &i.EntityType, &i.ParentGroupID, &i.CreatedAt, &i.UpdatedAt)
 		if err != nil {
 			return nil, err
 		}
@@ -364,12 +406,9 @@ func (r *DashboardRepository) getInstitutionsWithAccounts(accountMap map[int]*mo
 		accounts := institutionAccounts[inst.ID]
 
 		var totalBalance float64
+		var divisionByZero, missingRefs bool
 		if inst.IsCalculated && len(inst.Formula) > 0 {
-			for _, item := range inst.Formula {
-				if acc, ok := accountMap[item.AccountID]; ok {
-					totalBalance += item.Coefficient * acc.CurrentBalance
-				}
-			}
+			totalBalance, divisionByZero, missingRefs = calculateFormulaBalance(inst.Formula, formulaBalances(inst.Formula, accountMap))
 		} else {
 			for _, a := range accounts {
 				totalBalance += a.CurrentBalance
@@ -377,16 +416,74 @@ func (r *DashboardRepository) getInstitutionsWithAccounts(accountMap map[int]*mo
 		}
 
 		result[inst.ID] = &models.AccountGroupWithAccounts{
-			AccountGroup: inst,
-			TotalBalance: totalBalance,
-			Accounts:     accounts,
+			AccountGroup:      inst,
+			TotalBalance:      totalBalance,
+			DivisionByZero:    divisionByZero,
+			MissingReferences: missingRefs,
+			Accounts:          accounts,
 		}
 	}
 
 	return result, nil
 }
 
+// validateFormula checks that every formula item references an existing entity of a
+// known type and that dashboard references don't form a cycle
+func (r *DashboardRepository) validateFormula(dashboardID int, formula []models.DashboardFormulaItem) error {
+	for _, item := range formula {
+		var query string
+		switch item.Type {
+		case "account":
+			query = "SELECT EXISTS(SELECT 1 FROM account_balances WHERE id = $1)"
+		case "group", "institution":
+			query = "SELECT EXISTS(SELECT 1 FROM account_groups WHERE id = $1 AND entity_type = '" + item.Type + "')"
+		case "dashboard":
+			query = "SELECT EXISTS(SELECT 1 FROM dashboards WHERE id = $1)"
+		default:
+			return &ValidationError{Message: fmt.Sprintf("invalid formula item type %q: must be 'account', 'group', 'institution', or 'dashboard'", item.Type)}
+		}
+
+		var exists bool
+		if err := r.db.QueryRow(query, item.ID).Scan(&exists); err != nil {
+			return fmt.Errorf("failed to check formula reference: %w", err)
+		}
+		if !exists {
+			return &ValidationError{Message: fmt.Sprintf("formula references missing %s %d", item.Type, item.ID)}
+		}
+	}
+
+	rows, err := r.db.Query("SELECT id, name, is_calculated, formula FROM dashboards")
+	if err != nil {
+		return fmt.Errorf("failed to query dashboards: %w", err)
+	}
+	defer rows.Close()
+
+	var allDashboards []models.Dashboard
+	for rows.Next() {
+		var d models.Dashboard
+		var formulaJSON []byte
+		if err := rows.Scan(&d.ID, &d.Name, &d.IsCalculated, &formulaJSON); err != nil {
+			return fmt.Errorf("failed to scan dashboard: %w", err)
+		}
+		if len(formulaJSON) > 0 {
+			json.Unmarshal(formulaJSON, &d.Formula)
+		}
+		allDashboards = append(allDashboards, d)
+	}
+
+	if err := validation.ValidateDashboardFormulaForCycles(dashboardID, formula, allDashboards); err != nil {
+		return &ValidationError{Message: err.Error()}
+	}
+	return nil
+}
+
 func (r *DashboardRepository) Create(req *models.CreateDashboardRequest) (*models.DashboardWithItems, error) {
+	if req.IsCalculated {
+		if err := r.validateFormula(0, req.Formula); err != nil {
+			return nil, err
+		}
+	}
+
 	tx, err := r.db.Begin()
 	if err != nil {
 		return nil, fmt.Errorf("failed to begin transaction: %w", err)
@@ -424,36 +521,8 @@ func (r *DashboardRepository) Create(req *models.CreateDashboardRequest) (*model
 	}
 
 	// Insert items
-	position := 0
-	for _, accountID := range req.AccountIDs {
-		position++
-		_, err = tx.Exec(
-			"INSERT INTO dashboard_items (dashboard_id, item_type, item_id, position) VALUES ($1, $2, $3, $4)",
-			d.ID, "account", accountID, position,
-		)
-		if err != nil {
-			return nil, fmt.Errorf("failed to add account to dashboard: %w", err)
-		}
-	}
-	for _, groupID := range req.GroupIDs {
-		position++
-		_, err = tx.Exec(
-			"INSERT INTO dashboard_items (dashboard_id, item_type, item_id, position) VALUES ($1, $2, $3, $4)",
-			d.ID, "group", groupID, position,
-		)
-		if err != nil {
-			return nil, fmt.Errorf("failed to add group to dashboard: %w", err)
-		}
-	}
-	for _, institutionID := range req.InstitutionIDs {
-		position++
-		_, err = tx.Exec(
-			"INSERT INTO dashboard_items (dashboard_id, item_type, item_id, position) VALUES ($1, $2, $3, $4)",
-			d.ID, "institution", institutionID, position,
-		)
-		if err != nil {
-			return nil, fmt.Errorf("failed to add institution to dashboard: %w", err)
-		}
+	if err := insertDashboardItemsTx(tx, d.ID, req.AccountIDs, req.GroupIDs, req.InstitutionIDs); err != nil {
+		return nil, err
 	}
 
 	if err := tx.Commit(); err != nil {
@@ -464,6 +533,12 @@ func (r *DashboardRepository) Create(req *models.CreateDashboardRequest) (*model
 }
 
 func (r *DashboardRepository) Update(id int, req *models.UpdateDashboardRequest) (*models.DashboardWithItems, error) {
+	if req.IsCalculated {
+		if err := r.validateFormula(id, req.Formula); err != nil {
+			return nil, err
+		}
+	}
+
 	tx, err := r.db.Begin()
 	if err != nil {
 		return nil, fmt.Errorf("failed to begin transaction: %w", err)
@@ -501,35 +576,119 @@ func (r *DashboardRepository) Update(id int, req *models.UpdateDashboardRequest)
 	}
 
 	// Insert new items
+	if err := insertDashboardItemsTx(tx, id, req.AccountIDs, req.GroupIDs, req.InstitutionIDs); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return r.GetWithItems(id)
+}
+
+// insertDashboardItemsTx adds accounts, then groups, then institutions to a dashboard
+// in that order, numbering positions from 1
+func insertDashboardItemsTx(tx *sql.Tx, dashboardID int, accountIDs, groupIDs, institutionIDs []int) error {
 	position := 0
-	for _, accountID := range req.AccountIDs {
+	for _, accountID := range accountIDs {
 		position++
-		_, err = tx.Exec(
-			"INSERT INTO dashboard_items (dashboard_id, item_type, item_id, position) VALUES ($1, $2, $3, $4)",
-			id, "account", accountID, position,
-		)
-		if err != nil {
-			return nil, fmt.Errorf("failed to add account to dashboard: %w", err)
+		if err := insertDashboardItemTx(tx, dashboardID, "account", accountID, position); err != nil {
+			return err
 		}
 	}
-	for _, groupID := range req.GroupIDs {
+	for _, groupID := range groupIDs {
 		position++
-		_, err = tx.Exec(
-			"INSERT INTO dashboard_items (dashboard_id, item_type, item_id, position) VALUES ($1, $2, $3, $4)",
-			id, "group", groupID, position,
-		)
-		if err != nil {
-			return nil, fmt.Errorf("failed to add group to dashboard: %w", err)
+		if err := insertDashboardItemTx(tx, dashboardID, "group", groupID, position); err != nil {
+			return err
 		}
 	}
-	for _, institutionID := range req.InstitutionIDs {
+	for _, institutionID := range institutionIDs {
 		position++
-		_, err = tx.Exec(
-			"INSERT INTO dashboard_items (dashboard_id, item_type, item_id, position) VALUES ($1, $2, $3, $4)",
-			id, "institution", institutionID, position,
-		)
-		if err != nil {
-			return nil, fmt.Errorf("failed to add institution to dashboard: %w", err)
+		if err := insertDashboardItemTx(tx, dashboardID, "institution", institutionID, position); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func insertDashboardItemTx(tx *sql.Tx, dashboardID int, itemType string, itemID int, position int) error {
+	_, err := tx.Exec(
+		"INSERT INTO dashboard_items (dashboard_id, item_type, item_id, position) VALUES ($1, $2, $3, $4)",
+		dashboardID, itemType, itemID, position,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to add %s to dashboard: %w", itemType, err)
+	}
+	return nil
+}
+
+// Clone creates a copy of a dashboard named "<name> (copy)" at the end of the list,
+// with the same items at the same positions and the same formula. Clones are never
+// the main dashboard. Returns nil if the dashboard does not exist.
+func (r *DashboardRepository) Clone(id int) (*models.DashboardWithItems, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var name, description string
+	var isCalculated bool
+	var formulaJSON []byte
+	err = tx.QueryRow(
+		"SELECT name, description, is_calculated, formula FROM dashboards WHERE id = $1", id,
+	).Scan(&name, &description, &isCalculated, &formulaJSON)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get dashboard: %w", err)
+	}
+
+	var maxPos sql.NullInt64
+	err = tx.QueryRow("SELECT MAX(position) FROM dashboards").Scan(&maxPos)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get max position: %w", err)
+	}
+	newPos := 1
+	if maxPos.Valid {
+		newPos = int(maxPos.Int64) + 1
+	}
+
+	var cloneID int
+	err = tx.QueryRow(`
+		INSERT INTO dashboards (name, description, position, is_calculated, formula)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id
+	`, name+" (copy)", description, newPos, isCalculated, formulaJSON).Scan(&cloneID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create dashboard: %w", err)
+	}
+
+	rows, err := tx.Query("SELECT item_type, item_id, position FROM dashboard_items WHERE dashboard_id = $1 ORDER BY position", id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query dashboard items: %w", err)
+	}
+	type item struct {
+		itemType string
+		itemID   int
+		position int
+	}
+	var items []item
+	for rows.Next() {
+		var it item
+		if err := rows.Scan(&it.itemType, &it.itemID, &it.position); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to scan dashboard item: %w", err)
+		}
+		items = append(items, it)
+	}
+	rows.Close()
+
+	for _, it := range items {
+		if err := insertDashboardItemTx(tx, cloneID, it.itemType, it.itemID, it.position); err != nil {
+			return nil, err
 		}
 	}
 
@@ -537,7 +696,7 @@ func (r *DashboardRepository) Update(id int, req *models.UpdateDashboardRequest)
 		return nil, fmt.Errorf("failed to commit transaction: %w", err)
 	}
 
-	return r.GetWithItems(id)
+	return r.GetWithItems(cloneID)
 }
 
 func (r *DashboardRepository) Delete(id int) error {
@@ -639,6 +798,148 @@ func (r *DashboardRepository) GetHistory(dashboardID int) ([]models.DashboardBal
 	return history, nil
 }
 
+// Compare returns each dashboard item's balance as of from and to, using the latest
+// history entry at or before each time, along with the dashboard total at both times.
+// Returns nil if the dashboard does not exist.
+func (r *DashboardRepository) Compare(dashboardID int, from, to time.Time) (*models.DashboardComparison, error) {
+	var exists bool
+	err := r.db.QueryRow("SELECT EXISTS(SELECT 1 FROM dashboards WHERE id = $1)", dashboardID).Scan(&exists)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check dashboard existence: %w", err)
+	}
+	if !exists {
+		return nil, nil
+	}
+
+	query := `
+		SELECT i.item_type, i.item_id, COALESCE(a.account_name, g.name, ''),
+		       (SELECT h.balance FROM entity_balance_history h
+		        WHERE h.entity_type = i.item_type AND h.entity_id = i.item_id AND h.recorded_at <= $2
+		        ORDER BY h.recorded_at DESC, h.id DESC LIMIT 1),
+		       (SELECT h.balance FROM entity_balance_history h
+		        WHERE h.entity_type = i.item_type AND h.entity_id = i.item_id AND h.recorded_at <= $3
+		        ORDER BY h.recorded_at DESC, h.id DESC LIMIT 1)
+		FROM dashboard_items i
+		LEFT JOIN account_balances a ON i.item_type = 'account' AND a.id = i.item_id
+		LEFT JOIN account_groups g ON i.item_type IN ('group', 'institution') AND g.id = i.item_id
+		WHERE i.dashboard_id = $1
+		ORDER BY i.position
+	`
+	rows, err := r.db.Query(query, dashboardID, from, to)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query dashboard item history: %w", err)
+	}
+	defer rows.Close()
+
+	comparison := &models.DashboardComparison{
+		DashboardID: dashboardID,
+		From:        from,
+		To:          to,
+		Items:       []models.DashboardItemComparison{},
+	}
+	for rows.Next() {
+		var item models.DashboardItemComparison
+		var fromBalance, toBalance sql.NullFloat64
+		if err := rows.Scan(&item.ItemType, &item.ItemID, &item.Name, &fromBalance, &toBalance); err != nil {
+			return nil, fmt.Errorf("failed to scan dashboard item history: %w", err)
+		}
+		item.FromBalance, item.ToBalance, item.Delta = compareBalances(fromBalance, toBalance)
+		comparison.Items = append(comparison.Items, item)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read dashboard item history: %w", err)
+	}
+
+	totalQuery := `
+		SELECT h.balance FROM entity_balance_history h
+		WHERE h.entity_type = 'dashboard' AND h.entity_id = $1 AND h.recorded_at <= $2
+		ORDER BY h.recorded_at DESC, h.id DESC LIMIT 1
+	`
+	var fromTotal, toTotal sql.NullFloat64
+	if err := r.db.QueryRow(totalQuery, dashboardID, from).Scan(&fromTotal); err != nil && err != sql.ErrNoRows {
+		return nil, fmt.Errorf("failed to get dashboard total: %w", err)
+	}
+	if err := r.db.QueryRow(totalQuery, dashboardID, to).Scan(&toTotal); err != nil && err != sql.ErrNoRows {
+		return nil, fmt.Errorf("failed to get dashboard total: %w", err)
+	}
+	comparison.FromTotal, comparison.ToTotal, comparison.TotalDelta = compareBalances(fromTotal, toTotal)
+
+	return comparison, nil
+}
+
+// compareBalances converts two nullable balances to pointers and computes the delta
+// when both are present
+func compareBalances(from, to sql.NullFloat64) (*float64, *float64, *float64) {
+	var fromPtr, toPtr, deltaPtr *float64
+	if from.Valid {
+		fromPtr = &from.Float64
+	}
+	if to.Valid {
+		toPtr = &to.Float64
+	}
+	if from.Valid && to.Valid {
+		delta := to.Float64 - from.Float64
+		deltaPtr = &delta
+	}
+	return fromPtr, toPtr, deltaPtr
+}
+
+// UpdatePositions reorders dashboards in the dashboard list, renumbers them to
+// 1..n, and returns the resulting order
+func (r *DashboardRepository) UpdatePositions(positions []models.DashboardPosition) ([]models.DashboardPosition, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	for _, p := range positions {
+		_, err := tx.Exec("UPDATE dashboards SET position = $1, updated_at = NOW() WHERE id = $2", p.Position, p.ID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to update position for dashboard %d: %w", p.ID, err)
+		}
+	}
+
+	// Renumber to 1..n so gaps and duplicates don't accumulate, keeping the list order
+	_, err = tx.Exec(`
+		WITH ordered AS (
+			SELECT id, ROW_NUMBER() OVER (ORDER BY position ASC, name ASC, id ASC) AS new_position
+			FROM dashboards
+		)
+		UPDATE dashboards d
+		SET position = ordered.new_position
+		FROM ordered
+		WHERE d.id = ordered.id AND d.position != ordered.new_position
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to normalize dashboard positions: %w", err)
+	}
+
+	rows, err := tx.Query("SELECT id, position FROM dashboards ORDER BY position ASC")
+	if err != nil {
+		return nil, fmt.Errorf("failed to query dashboard positions: %w", err)
+	}
+	defer rows.Close()
+
+	order := []models.DashboardPosition{}
+	for rows.Next() {
+		var p models.DashboardPosition
+		if err := rows.Scan(&p.ID, &p.Position); err != nil {
+			return nil, fmt.Errorf("failed to scan dashboard position: %w", err)
+		}
+		order = append(order, p)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	rows.Close()
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return order, nil
+}
+
 func (r *DashboardRepository) UpdateItemPositions(dashboardID int, positions []models.DashboardItemPosition) error {
 	tx, err := r.db.Begin()
 	if err != nil {
@@ -1,12 +1,21 @@
 package repository
 
 import (
+	"context"
 	"database/sql"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
 
+	"finance-tracker/internal/datasource"
 	"finance-tracker/internal/models"
 	"finance-tracker/internal/service"
 	"finance-tracker/internal/storage"
+	"finance-tracker/internal/validation"
 )
 
 type DatasetRepository struct {
@@ -36,7 +45,8 @@ func (r *DatasetRepository) GetAll(page, pageSize int) (*models.DatasetListRespo
 	query := `
 		SELECT id, name, description, COALESCE(folder_path, ''), row_count, status,
 		       COALESCE(error_message, ''), COALESCE(last_commit_hash, ''), last_synced_at,
-		       created_at, updated_at
+		       auto_sync, auto_sync_interval_seconds, combine_mode, COALESCE(join_key, ''), skip_rows, delimiter,
+		       dedupe, dedupe_keys, duplicates_dropped, derived_columns, created_at, updated_at
 		FROM datasets
 		ORDER BY created_at DESC
 		LIMIT $1 OFFSET $2
@@ -51,13 +61,18 @@ func (r *DatasetRepository) GetAll(page, pageSize int) (*models.DatasetListRespo
 	for rows.Next() {
 		var d models.Dataset
 		var lastSyncedAt sql.NullTime
+		var dedupeKeysJSON, derivedColumnsJSON []byte
 		if err := rows.Scan(&d.ID, &d.Name, &d.Description, &d.FolderPath, &d.RowCount, &d.Status,
-			&d.ErrorMessage, &d.LastCommitHash, &lastSyncedAt, &d.CreatedAt, &d.UpdatedAt); err != nil {
+			&d.ErrorMessage, &d.LastCommitHash, &lastSyncedAt, &d.AutoSync, &d.AutoSyncIntervalSeconds, &d.CombineMode, &d.JoinKey, &d.SkipRows, &d.Delimiter,
+			&d.Dedupe, &dedupeKeysJSON, &d.DuplicatesDropped, &derivedColumnsJSON,
+			&d.CreatedAt, &d.UpdatedAt); err != nil {
 			return nil, fmt.Errorf("failed to scan dataset: %w", err)
 		}
 		if lastSyncedAt.Valid {
 			d.LastSyncedAt = &lastSyncedAt.Time
 		}
+		d.DedupeKeys = parseDedupeKeys(dedupeKeysJSON)
+		d.DerivedColumns = parseDerivedColumns(derivedColumnsJSON)
 		datasets = append(datasets, d)
 	}
 
@@ -73,14 +88,18 @@ func (r *DatasetRepository) GetByID(id int) (*models.Dataset, error) {
 	query := `
 		SELECT id, name, description, COALESCE(folder_path, ''), row_count, status,
 		       COALESCE(error_message, ''), COALESCE(last_commit_hash, ''), last_synced_at,
-		       created_at, updated_at
+		       auto_sync, auto_sync_interval_seconds, combine_mode, COALESCE(join_key, ''), skip_rows, delimiter,
+		       dedupe, dedupe_keys, duplicates_dropped, derived_columns, created_at, updated_at
 		FROM datasets
 		WHERE id = $1
 	`
 	var d models.Dataset
 	var lastSyncedAt sql.NullTime
+	var dedupeKeysJSON, derivedColumnsJSON []byte
 	err := r.db.QueryRow(query, id).Scan(&d.ID, &d.Name, &d.Description, &d.FolderPath, &d.RowCount,
-		&d.Status, &d.ErrorMessage, &d.LastCommitHash, &lastSyncedAt, &d.CreatedAt, &d.UpdatedAt)
+		&d.Status, &d.ErrorMessage, &d.LastCommitHash, &lastSyncedAt, &d.AutoSync, &d.AutoSyncIntervalSeconds, &d.CombineMode, &d.JoinKey, &d.SkipRows, &d.Delimiter,
+		&d.Dedupe, &dedupeKeysJSON, &d.DuplicatesDropped, &derivedColumnsJSON,
+		&d.CreatedAt, &d.UpdatedAt)
 	if err == sql.ErrNoRows {
 		return nil, nil
 	}
@@ -90,6 +109,13 @@ func (r *DatasetRepository) GetByID(id int) (*models.Dataset, error) {
 	if lastSyncedAt.Valid {
 		d.LastSyncedAt = &lastSyncedAt.Time
 	}
+	d.DedupeKeys = parseDedupeKeys(dedupeKeysJSON)
+	d.DerivedColumns = parseDerivedColumns(derivedColumnsJSON)
+
+	d.ColumnDescriptions, err = r.getColumnDescriptions(id)
+	if err != nil {
+		return nil, err
+	}
 
 	return &d, nil
 }
@@ -109,10 +135,52 @@ func IsValidationError(err error) bool {
 	return ok
 }
 
+// IsQueryTimeout checks if an error comes from a dataset query that exceeded its timeout
+func IsQueryTimeout(err error) bool {
+	return errors.Is(err, storage.ErrQueryTimeout)
+}
+
+// ErrDatasetNotReady is returned when an operation needs a dataset whose data has
+// finished loading
+var ErrDatasetNotReady = errors.New("dataset is not ready")
+
+// IsNotReady checks if an error comes from using a dataset that is not ready
+func IsNotReady(err error) bool {
+	return errors.Is(err, ErrDatasetNotReady)
+}
+
+// IsAlreadySyncing checks if an error comes from requesting a sync of a dataset that
+// is already being synced
+func IsAlreadySyncing(err error) bool {
+	return errors.Is(err, service.ErrAlreadySyncing)
+}
+
 func (r *DatasetRepository) Create(req *models.CreateDatasetRequest) (*models.Dataset, error) {
 	if req.FolderPath == "" {
 		return nil, &ValidationError{Message: "Folder path is required"}
 	}
+	if req.AutoSyncIntervalSeconds != nil && *req.AutoSyncIntervalSeconds < 0 {
+		return nil, &ValidationError{Message: "Auto-sync interval must not be negative"}
+	}
+	combineMode, joinKey, err := validateCombineMode(req.CombineMode, req.JoinKey)
+	if err != nil {
+		return nil, err
+	}
+	if err := validateSkipRows(req.SkipRows); err != nil {
+		return nil, err
+	}
+	if err := validateDelimiter(req.Delimiter); err != nil {
+		return nil, err
+	}
+
+	var nameTaken bool
+	err = r.db.QueryRow("SELECT EXISTS(SELECT 1 FROM datasets WHERE LOWER(TRIM(name)) = LOWER(TRIM($1)))", req.Name).Scan(&nameTaken)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check dataset name: %w", err)
+	}
+	if nameTaken {
+		return nil, &ValidationError{Message: fmt.Sprintf("A dataset named %q already exists", strings.TrimSpace(req.Name))}
+	}
 
 	// Initialize git repo and validate folder
 	commitHash, err := r.syncService.InitializeDataset(req.FolderPath)
@@ -120,6 +188,12 @@ func (r *DatasetRepository) Create(req *models.CreateDatasetRequest) (*models.Da
 		return nil, &ValidationError{Message: fmt.Sprintf("Failed to initialize folder: %v", err)}
 	}
 
+	dedupeKeys, err := r.validateDedupeKeys(&service.DatasetInfo{FolderPath: req.FolderPath, CombineMode: combineMode, SkipRows: req.SkipRows, Delimiter: req.Delimiter}, req.DedupeKeys)
+	if err != nil {
+		return nil, err
+	}
+	dedupeKeysParam, _ := json.Marshal(dedupeKeys)
+
 	// Generate table name from dataset name
 	tableName := storage.ToTableName(req.Name)
 
@@ -135,19 +209,35 @@ func (r *DatasetRepository) Create(req *models.CreateDatasetRequest) (*models.Da
 		return nil, fmt.Errorf("failed to generate unique table name: %w", err)
 	}
 
+	// Auto-sync defaults to enabled with a one minute check interval
+	autoSync := true
+	if req.AutoSync != nil {
+		autoSync = *req.AutoSync
+	}
+	autoSyncInterval := 60
+	if req.AutoSyncIntervalSeconds != nil {
+		autoSyncInterval = *req.AutoSyncIntervalSeconds
+	}
+
 	// Create dataset with folder path and table name
 	query := `
-		INSERT INTO datasets (name, description, folder_path, last_commit_hash, table_name, status)
-		VALUES ($1, $2, $3, $4, $5, 'pending')
+		INSERT INTO datasets (name, description, folder_path, last_commit_hash, table_name, auto_sync, auto_sync_interval_seconds,
+		                      combine_mode, join_key, skip_rows, delimiter, dedupe, dedupe_keys, status)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, NULLIF($9, ''), $10, $11, $12, $13, 'pending')
 		RETURNING id, name, description, COALESCE(folder_path, ''), row_count, status,
 		          COALESCE(error_message, ''), COALESCE(last_commit_hash, ''), last_synced_at,
-		          created_at, updated_at
+		          auto_sync, auto_sync_interval_seconds, combine_mode, COALESCE(join_key, ''), skip_rows, delimiter,
+		          dedupe, dedupe_keys, duplicates_dropped, derived_columns, created_at, updated_at
 	`
 	var d models.Dataset
 	var lastSyncedAt sql.NullTime
-	err = tx.QueryRow(query, req.Name, req.Description, req.FolderPath, commitHash, tableName).Scan(
+	var dedupeKeysJSON, derivedColumnsJSON []byte
+	err = tx.QueryRow(query, req.Name, req.Description, req.FolderPath, commitHash, tableName, autoSync, autoSyncInterval, combineMode, joinKey,
+		req.SkipRows, req.Delimiter, req.Dedupe, dedupeKeysParam).Scan(
 		&d.ID, &d.Name, &d.Description, &d.FolderPath, &d.RowCount, &d.Status,
-		&d.ErrorMessage, &d.LastCommitHash, &lastSyncedAt, &d.CreatedAt, &d.UpdatedAt,
+		&d.ErrorMessage, &d.LastCommitHash, &lastSyncedAt, &d.AutoSync, &d.AutoSyncIntervalSeconds, &d.CombineMode, &d.JoinKey, &d.SkipRows, &d.Delimiter,
+		&d.Dedupe, &dedupeKeysJSON, &d.DuplicatesDropped, &derivedColumnsJSON,
+		&d.CreatedAt, &d.UpdatedAt,
 	)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create dataset: %w", err)
@@ -155,6 +245,8 @@ func (r *DatasetRepository) Create(req *models.CreateDatasetRequest) (*models.Da
 	if lastSyncedAt.Valid {
 		d.LastSyncedAt = &lastSyncedAt.Time
 	}
+	d.DedupeKeys = parseDedupeKeys(dedupeKeysJSON)
+	d.DerivedColumns = parseDerivedColumns(derivedColumnsJSON)
 
 	if err := tx.Commit(); err != nil {
 		return nil, fmt.Errorf("failed to commit transaction: %w", err)
@@ -162,23 +254,32 @@ func (r *DatasetRepository) Create(req *models.CreateDatasetRequest) (*models.Da
 
 	// Perform initial sync to load data
 	datasetInfo := &service.DatasetInfo{
-		ID:         d.ID,
-		Name:       d.Name,
-		TableName:  tableName,
-		FolderPath: d.FolderPath,
-		Status:     d.Status,
+		ID:          d.ID,
+		Name:        d.Name,
+		TableName:   tableName,
+		FolderPath:  d.FolderPath,
+		Status:      d.Status,
+		CombineMode: d.CombineMode,
+		JoinKey:     d.JoinKey,
+		SkipRows:    d.SkipRows,
+		Delimiter:   d.Delimiter,
+		Dedupe:      d.Dedupe,
+		DedupeKeys:  d.DedupeKeys,
 	}
-	_, _, err = r.syncService.SyncDataset(datasetInfo)
-	if err != nil {
-		// Error is already stored in the dataset by SyncDataset
-		// Return the dataset with error status
+	d.DerivedColumns = []models.DerivedColumn{}
+	// Load the data in the background so large folders don't block the request.
+	// Clients poll GET /datasets/{id} until the status leaves pending/syncing; sync
+	// failures are stored in the dataset's status and error message.
+	if err := r.syncService.SyncInBackground(datasetInfo); err != nil {
+		return nil, fmt.Errorf("failed to start initial sync: %w", err)
 	}
 
-	// Return the updated dataset
-	return r.GetByID(d.ID)
+	return &d, nil
 }
 
-// ensureUniqueTableName checks if a table name is unique and appends a suffix if needed
+// ensureUniqueTableName checks if a table name is unique and appends a suffix if needed.
+// Names such as "My Data" and "my-data" map to the same table name, so a name is
+// taken if another dataset uses it or a table of that name is left over.
 func (r *DatasetRepository) ensureUniqueTableName(tx *sql.Tx, baseName string) (string, error) {
 	tableName := baseName
 	suffix := 1
@@ -189,6 +290,12 @@ func (r *DatasetRepository) ensureUniqueTableName(tx *sql.Tx, baseName string) (
 		if err != nil {
 			return "", err
 		}
+		if !exists {
+			exists, err = r.storage.TableExists(tableName)
+			if err != nil {
+				return "", err
+			}
+		}
 		if !exists {
 			return tableName, nil
 		}
@@ -226,13 +333,18 @@ func (r *DatasetRepository) Delete(id int) error {
 // GetDatasetInfo returns the minimal dataset info needed for sync operations
 func (r *DatasetRepository) GetDatasetInfo(id int) (*service.DatasetInfo, error) {
 	query := `
-		SELECT id, name, COALESCE(table_name, ''), folder_path, last_commit_hash, status
+		SELECT id, name, COALESCE(table_name, ''), folder_path, last_commit_hash, status,
+		       auto_sync, auto_sync_interval_seconds, combine_mode, COALESCE(join_key, ''), skip_rows, delimiter,
+		       dedupe, dedupe_keys, derived_columns
 		FROM datasets
 		WHERE id = $1
 	`
 	var info service.DatasetInfo
 	var folderPath sql.NullString
-	err := r.db.QueryRow(query, id).Scan(&info.ID, &info.Name, &info.TableName, &folderPath, &info.LastCommitHash, &info.Status)
+	var autoSyncIntervalSeconds int
+	var dedupeKeysJSON, derivedColumnsJSON []byte
+	err := r.db.QueryRow(query, id).Scan(&info.ID, &info.Name, &info.TableName, &folderPath, &info.LastCommitHash, &info.Status,
+		&info.AutoSync, &autoSyncIntervalSeconds, &info.CombineMode, &info.JoinKey, &info.SkipRows, &info.Delimiter, &info.Dedupe, &dedupeKeysJSON, &derivedColumnsJSON)
 	if err == sql.ErrNoRows {
 		return nil, nil
 	}
@@ -242,10 +354,22 @@ func (r *DatasetRepository) GetDatasetInfo(id int) (*service.DatasetInfo, error)
 	if folderPath.Valid {
 		info.FolderPath = folderPath.String
 	}
+	info.AutoSyncInterval = time.Duration(autoSyncIntervalSeconds) * time.Second
+	info.DedupeKeys = parseDedupeKeys(dedupeKeysJSON)
+	info.DerivedColumns = parseDerivedColumns(derivedColumnsJSON)
 
-	// If table_name is not set (legacy dataset), generate and save it
+	// If table_name is not set (legacy dataset), generate and save it. A name that
+	// maps to another dataset's table gets the ID appended so the two never share one.
 	if info.TableName == "" {
 		info.TableName = storage.ToTableName(info.Name)
+		var taken bool
+		err := r.db.QueryRow("SELECT EXISTS(SELECT 1 FROM datasets WHERE table_name = $1 AND id != $2)", info.TableName, id).Scan(&taken)
+		if err != nil {
+			return nil, fmt.Errorf("failed to check table name: %w", err)
+		}
+		if taken {
+			info.TableName = fmt.Sprintf("%s_%d", info.TableName, id)
+		}
 		// Try to save it back to the database (best effort)
 		_, _ = r.db.Exec("UPDATE datasets SET table_name = $1 WHERE id = $2 AND table_name IS NULL", info.TableName, id)
 	}
@@ -269,7 +393,7 @@ func (r *DatasetRepository) SyncDataset(id int) error {
 	return err
 }
 
-func (r *DatasetRepository) GetData(id int, page, pageSize int, sortColumn, sortDirection string) (*models.DatasetDataResponse, error) {
+func (r *DatasetRepository) GetData(id int, page, pageSize int, sortColumn, sortDirection string, filter models.DataFilter) (*models.DatasetDataResponse, error) {
 	// Get dataset info for sync check
 	info, err := r.GetDatasetInfo(id)
 	if err != nil {
@@ -282,20 +406,22 @@ func (r *DatasetRepository) GetData(id int, page, pageSize int, sortColumn, sort
 	// Check if currently syncing
 	isSyncing := r.syncService.IsSyncing(id)
 
-	// If not syncing, check if sync is needed and trigger it
-	if !isSyncing && info.FolderPath != "" {
-		needsSync, err := r.syncService.NeedsSync(info)
-		if err == nil && needsSync {
-			// Start sync in background
-			go func() {
-				r.syncService.SyncDataset(info)
-			}()
-			isSyncing = true
-		}
+	// If auto-sync is enabled, start a background sync when the folder has changed.
+	// The current (possibly stale) data is returned immediately.
+	if !isSyncing && info.FolderPath != "" && info.AutoSync {
+		isSyncing = r.syncService.SyncInBackgroundIfNeeded(info, info.AutoSyncInterval)
 	}
 
 	// Get current data from storage (may be stale if syncing)
-	dataPage, err := r.storage.GetData(id, info.TableName, page, pageSize, sortColumn, sortDirection)
+	dataPage, err := r.storage.GetData(id, info.TableName, page, pageSize, sortColumn, sortDirection, storage.DataFilter{
+		Column: filter.Column,
+		Op:     filter.Op,
+		Value:  filter.Value,
+		Query:  filter.Query,
+	})
+	if errors.Is(err, storage.ErrInvalidFilter) {
+		return nil, &ValidationError{Message: err.Error()}
+	}
 	if err != nil {
 		// If no data exists yet, return empty response with syncing flag
 		if isSyncing {
@@ -311,21 +437,233 @@ func (r *DatasetRepository) GetData(id int, page, pageSize int, sortColumn, sort
 		return nil, err
 	}
 
-	return &models.DatasetDataResponse{
+	response := &models.DatasetDataResponse{
 		Columns:  dataPage.Columns,
 		Rows:     dataPage.Rows,
 		Total:    dataPage.Total,
 		Page:     dataPage.Page,
 		PageSize: dataPage.PageSize,
 		Syncing:  isSyncing,
-	}, nil
+	}
+	if err := r.applyColumnSettings(id, response); err != nil {
+		return nil, err
+	}
+	return response, nil
 }
 
-func (r *DatasetRepository) IsSyncing(id int) bool {
-	return r.syncService.IsSyncing(id)
+// applyColumnSettings reorders the response's columns and rows by the stored
+// column positions and fills in display names
+func (r *DatasetRepository) applyColumnSettings(id int, response *models.DatasetDataResponse) error {
+	rows, err := r.db.Query(`
+		SELECT column_name, COALESCE(display_name, '')
+		FROM dataset_column_settings
+		WHERE dataset_id = $1
+		ORDER BY position ASC
+	`, id)
+	if err != nil {
+		return fmt.Errorf("failed to get column settings: %w", err)
+	}
+	defer rows.Close()
+
+	index := make(map[string]int)
+	for i, col := range response.Columns {
+		index[col] = i
+	}
+
+	// Positioned columns first, then the rest in physical order
+	var order []int
+	placed := make(map[int]bool)
+	displayNames := make(map[string]string)
+	for rows.Next() {
+		var column, displayName string
+		if err := rows.Scan(&column, &displayName); err != nil {
+			return fmt.Errorf("failed to scan column setting: %w", err)
+		}
+		// Settings for columns no longer in the source are ignored
+		i, ok := index[column]
+		if !ok {
+			continue
+		}
+		order = append(order, i)
+		placed[i] = true
+		displayNames[column] = displayName
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	for i := range response.Columns {
+		if !placed[i] {
+			order = append(order, i)
+		}
+	}
+
+	columns := make([]string, len(order))
+	labels := make([]string, len(order))
+	for j, i := range order {
+		columns[j] = response.Columns[i]
+		labels[j] = columns[j]
+		if displayNames[columns[j]] != "" {
+			labels[j] = displayNames[columns[j]]
+		}
+	}
+	for k, row := range response.Rows {
+		reordered := make([]any, len(order))
+		for j, i := range order {
+			reordered[j] = row[i]
+		}
+		response.Rows[k] = reordered
+	}
+	response.Columns = columns
+	response.DisplayNames = labels
+	return nil
+}
+
+// UpdateAutoSync updates the auto-sync settings for a dataset
+func (r *DatasetRepository) UpdateAutoSync(id int, req *models.UpdateAutoSyncRequest) (*models.Dataset, error) {
+	if req.AutoSyncIntervalSeconds < 0 {
+		return nil, &ValidationError{Message: "Auto-sync interval must not be negative"}
+	}
+
+	result, err := r.db.Exec(`
+		UPDATE datasets
+		SET auto_sync = $1, auto_sync_interval_seconds = $2, updated_at = NOW()
+		WHERE id = $3
+	`, req.AutoSync, req.AutoSyncIntervalSeconds, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update auto-sync settings: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if rowsAffected == 0 {
+		return nil, nil
+	}
+
+	return r.GetByID(id)
+}
+
+// UpdateCombineMode changes how the dataset combines the CSV files in its folder and
+// resyncs it so the data reflects the new mode. A failed resync is recorded in the
+// dataset's status. Returns nil if the dataset does not exist.
+func (r *DatasetRepository) UpdateCombineMode(id int, req *models.UpdateCombineModeRequest) (*models.Dataset, error) {
+	if req.CombineMode == "" {
+		return nil, &ValidationError{Message: "Combine mode is required"}
+	}
+	combineMode, joinKey, err := validateCombineMode(req.CombineMode, req.JoinKey)
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := r.db.Exec(`
+		UPDATE datasets
+		SET combine_mode = $1, join_key = NULLIF($2, ''), updated_at = NOW()
+		WHERE id = $3
+	`, combineMode, joinKey, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update combine mode: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if rowsAffected == 0 {
+		return nil, nil
+	}
+
+	info, err := r.GetDatasetInfo(id)
+	if err != nil {
+		return nil, err
+	}
+	if info.FolderPath != "" {
+		// Failures are recorded in the dataset's status
+		r.syncService.Resync(info)
+	}
+
+	return r.GetByID(id)
+}
+
+// UpdateSkipRows sets how many records precede each file's header, or restores
+// automatic detection of title lines when SkipRows is nil, and resyncs the dataset.
+// A failed resync is recorded in the dataset's status. Returns nil if the dataset
+// does not exist.
+func (r *DatasetRepository) UpdateSkipRows(id int, req *models.UpdateSkipRowsRequest) (*models.Dataset, error) {
+	if err := validateSkipRows(req.SkipRows); err != nil {
+		return nil, err
+	}
+
+	result, err := r.db.Exec(`
+		UPDATE datasets
+		SET skip_rows = $1, updated_at = NOW()
+		WHERE id = $2
+	`, req.SkipRows, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update skip rows: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if rowsAffected == 0 {
+		return nil, nil
+	}
+
+	info, err := r.GetDatasetInfo(id)
+	if err != nil {
+		return nil, err
+	}
+	if info.FolderPath != "" {
+		// Failures are recorded in the dataset's status
+		r.syncService.Resync(info)
+	}
+
+	return r.GetByID(id)
+}
+
+// UpdateDelimiter sets the field delimiter of the dataset's CSV files, or restores
+// automatic detection when Delimiter is nil, and resyncs the dataset. A failed
+// resync is recorded in the dataset's status. Returns nil if the dataset does not
+// exist.
+func (r *DatasetRepository) UpdateDelimiter(id int, req *models.UpdateDelimiterRequest) (*models.Dataset, error) {
+	if err := validateDelimiter(req.Delimiter); err != nil {
+		return nil, err
+	}
+
+	result, err := r.db.Exec(`
+		UPDATE datasets
+		SET delimiter = $1, updated_at = NOW()
+		WHERE id = $2
+	`, req.Delimiter, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update delimiter: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if rowsAffected == 0 {
+		return nil, nil
+	}
+
+	info, err := r.GetDatasetInfo(id)
+	if err != nil {
+		return nil, err
+	}
+	if info.FolderPath != "" {
+		// Failures are recorded in the dataset's status
+		r.syncService.Resync(info)
+	}
+
+	return r.GetByID(id)
 }
 
-func (r *DatasetRepository) GetAllData(id int) (*models.DatasetDataResponse, error) {
+// GetTransformations returns the dataset's transformation pipeline in order.
+// Returns nil if the dataset does not exist.
+func (r *DatasetRepository) GetTransformations(id int) ([]models.DatasetTransformation, error) {
 	info, err := r.GetDatasetInfo(id)
 	if err != nil {
 		return nil, err
@@ -333,18 +671,785 @@ func (r *DatasetRepository) GetAllData(id int) (*models.DatasetDataResponse, err
 	if info == nil {
 		return nil, nil
 	}
+	return r.syncService.LoadTransformations(id)
+}
 
-	dataPage, err := r.storage.GetAllData(id, info.TableName)
+// CreateTransformation appends a step to the dataset's pipeline after checking the
+// whole pipeline against the folder's columns, then resyncs the dataset. Returns
+// nil if the dataset does not exist.
+func (r *DatasetRepository) CreateTransformation(id int, req *models.CreateTransformationRequest) (*models.DatasetTransformation, error) {
+	info, err := r.GetDatasetInfo(id)
 	if err != nil {
 		return nil, err
 	}
+	if info == nil {
+		return nil, nil
+	}
 
-	return &models.DatasetDataResponse{
-		Columns:  dataPage.Columns,
-		Rows:     dataPage.Rows,
-		Total:    dataPage.Total,
-		Page:     1,
-		PageSize: dataPage.Total,
-		Syncing:  false,
-	}, nil
+	steps, err := r.syncService.LoadTransformations(id)
+	if err != nil {
+		return nil, err
+	}
+	step := models.DatasetTransformation{
+		DatasetID: id,
+		Operation: req.Operation,
+		Column:    req.Column,
+		To:        req.To,
+		Op:        req.Op,
+		Value:     req.Value,
+		Left:      req.Left,
+		Right:     req.Right,
+		Columns:   req.Columns,
+	}
+	if err := r.validatePipeline(info, append(steps, step)); err != nil {
+		return nil, err
+	}
+
+	configJSON, err := json.Marshal(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode transformation: %w", err)
+	}
+	err = r.db.QueryRow(`
+		INSERT INTO dataset_transformations (dataset_id, position, operation, config)
+		VALUES ($1, (SELECT COALESCE(MAX(position), 0) + 1 FROM dataset_transformations WHERE dataset_id = $1), $2, $3)
+		RETURNING id, position, created_at
+	`, id, req.Operation, configJSON).Scan(&step.ID, &step.Position, &step.CreatedAt)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create transformation: %w", err)
+	}
+
+	// Failures are recorded in the dataset's status
+	r.syncService.Resync(info)
+
+	return &step, nil
+}
+
+// DeleteTransformation removes a step from the dataset's pipeline, provided the
+// remaining steps are still valid, then resyncs the dataset. Returns false if the
+// dataset or step does not exist.
+func (r *DatasetRepository) DeleteTransformation(id, stepID int) (bool, error) {
+	info, err := r.GetDatasetInfo(id)
+	if err != nil {
+		return false, err
+	}
+	if info == nil {
+		return false, nil
+	}
+
+	steps, err := r.syncService.LoadTransformations(id)
+	if err != nil {
+		return false, err
+	}
+	remaining := []models.DatasetTransformation{}
+	for _, s := range steps {
+		if s.ID != stepID {
+			remaining = append(remaining, s)
+		}
+	}
+	if len(remaining) == len(steps) {
+		return false, nil
+	}
+	if err := r.validatePipeline(info, remaining); err != nil {
+		return false, err
+	}
+
+	_, err = r.db.Exec("DELETE FROM dataset_transformations WHERE id = $1 AND dataset_id = $2", stepID, id)
+	if err != nil {
+		return false, fmt.Errorf("failed to delete transformation: %w", err)
+	}
+
+	// Failures are recorded in the dataset's status
+	r.syncService.Resync(info)
+
+	return true, nil
+}
+
+// UpdateTransformationPositions reorders the dataset's pipeline, provided the new
+// order is valid, renumbers the steps to 1..n, and resyncs the dataset. Returns
+// nil if the dataset does not exist.
+func (r *DatasetRepository) UpdateTransformationPositions(id int, positions []models.TransformationPosition) ([]models.DatasetTransformation, error) {
+	info, err := r.GetDatasetInfo(id)
+	if err != nil {
+		return nil, err
+	}
+	if info == nil {
+		return nil, nil
+	}
+
+	steps, err := r.syncService.LoadTransformations(id)
+	if err != nil {
+		return nil, err
+	}
+	requested := make(map[int]int)
+	for _, p := range positions {
+		requested[p.ID] = p.Position
+	}
+	for i := range steps {
+		if pos, ok := requested[steps[i].ID]; ok {
+			steps[i].Position = pos
+			delete(requested, steps[i].ID)
+		}
+	}
+	for stepID := range requested {
+		return nil, &ValidationError{Message: fmt.Sprintf("Transformation %d does not belong to this dataset", stepID)}
+	}
+	sort.SliceStable(steps, func(i, j int) bool { return steps[i].Position < steps[j].Position })
+	if err := r.validatePipeline(info, steps); err != nil {
+		return nil, err
+	}
+
+	tx, err := r.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	for i := range steps {
+		steps[i].Position = i + 1
+		_, err := tx.Exec("UPDATE dataset_transformations SET position = $1 WHERE id = $2", steps[i].Position, steps[i].ID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to update position for transformation %d: %w", steps[i].ID, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	// Failures are recorded in the dataset's status
+	r.syncService.Resync(info)
+
+	return steps, nil
+}
+
+// validatePipeline checks the steps against the columns the dataset's folder yields
+func (r *DatasetRepository) validatePipeline(info *service.DatasetInfo, steps []models.DatasetTransformation) error {
+	if info.FolderPath == "" {
+		return &ValidationError{Message: "Dataset has no folder path configured"}
+	}
+	columns, err := r.syncService.SourceColumns(info)
+	if err != nil {
+		return &ValidationError{Message: fmt.Sprintf("Failed to read folder columns: %v", err)}
+	}
+	columns, err = service.ValidateTransformations(columns, steps)
+	if err != nil {
+		return &ValidationError{Message: fmt.Sprintf("Invalid transformation: %v", err)}
+	}
+	if _, err := service.ValidateDerivedColumns(columns, info.DerivedColumns); err != nil {
+		return &ValidationError{Message: fmt.Sprintf("Transformations would break derived column %v", err)}
+	}
+	return nil
+}
+
+// UpdateDedupe configures dropping duplicate rows and resyncs the dataset so the
+// data reflects the setting. Returns nil if the dataset does not exist.
+func (r *DatasetRepository) UpdateDedupe(id int, req *models.UpdateDedupeRequest) (*models.Dataset, error) {
+	info, err := r.GetDatasetInfo(id)
+	if err != nil {
+		return nil, err
+	}
+	if info == nil {
+		return nil, nil
+	}
+
+	dedupeKeys, err := r.validateDedupeKeys(info, req.DedupeKeys)
+	if err != nil {
+		return nil, err
+	}
+	dedupeKeysJSON, _ := json.Marshal(dedupeKeys)
+
+	_, err = r.db.Exec(`
+		UPDATE datasets
+		SET dedupe = $1, dedupe_keys = $2, updated_at = NOW()
+		WHERE id = $3
+	`, req.Dedupe, dedupeKeysJSON, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update dedupe settings: %w", err)
+	}
+
+	info.Dedupe = req.Dedupe
+	info.DedupeKeys = dedupeKeys
+	if info.FolderPath != "" {
+		// Failures are recorded in the dataset's status
+		r.syncService.Resync(info)
+	}
+
+	return r.GetByID(id)
+}
+
+// UpdateDerivedColumns replaces the dataset's derived columns, provided each
+// expression only uses columns produced by the transformation pipeline or earlier
+// derived columns, and resyncs the dataset. Returns nil if the dataset does not exist.
+func (r *DatasetRepository) UpdateDerivedColumns(id int, req *models.UpdateDerivedColumnsRequest) (*models.Dataset, error) {
+	info, err := r.GetDatasetInfo(id)
+	if err != nil {
+		return nil, err
+	}
+	if info == nil {
+		return nil, nil
+	}
+	if info.FolderPath == "" {
+		return nil, &ValidationError{Message: "Dataset has no folder path configured"}
+	}
+
+	derived := req.DerivedColumns
+	if derived == nil {
+		derived = []models.DerivedColumn{}
+	}
+	for i := range derived {
+		derived[i].Name = strings.TrimSpace(derived[i].Name)
+	}
+
+	columns, err := r.syncService.SourceColumns(info)
+	if err != nil {
+		return nil, &ValidationError{Message: fmt.Sprintf("Failed to read folder columns: %v", err)}
+	}
+	steps, err := r.syncService.LoadTransformations(id)
+	if err != nil {
+		return nil, err
+	}
+	columns, err = service.ValidateTransformations(columns, steps)
+	if err != nil {
+		return nil, &ValidationError{Message: fmt.Sprintf("Invalid transformation: %v", err)}
+	}
+	if _, err := service.ValidateDerivedColumns(columns, derived); err != nil {
+		return nil, &ValidationError{Message: fmt.Sprintf("Invalid derived column: %v", err)}
+	}
+
+	derivedJSON, _ := json.Marshal(derived)
+	_, err = r.db.Exec(`
+		UPDATE datasets
+		SET derived_columns = $1, updated_at = NOW()
+		WHERE id = $2
+	`, derivedJSON, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update derived columns: %w", err)
+	}
+
+	info.DerivedColumns = derived
+	// Failures are recorded in the dataset's status
+	r.syncService.Resync(info)
+
+	return r.GetByID(id)
+}
+
+// parseDerivedColumns decodes the derived_columns column, treating bad JSON as none
+func parseDerivedColumns(data []byte) []models.DerivedColumn {
+	derived := []models.DerivedColumn{}
+	if len(data) > 0 {
+		json.Unmarshal(data, &derived)
+	}
+	return derived
+}
+
+// validateDedupeKeys checks that each key is a distinct column of the folder data
+// and returns the keys, never nil
+func (r *DatasetRepository) validateDedupeKeys(info *service.DatasetInfo, keys []string) ([]string, error) {
+	if len(keys) == 0 {
+		return []string{}, nil
+	}
+	if info.FolderPath == "" {
+		return nil, &ValidationError{Message: "Dataset has no folder path configured"}
+	}
+	columns, err := r.syncService.SourceColumns(info)
+	if err != nil {
+		return nil, &ValidationError{Message: fmt.Sprintf("Failed to read folder columns: %v", err)}
+	}
+	known := make(map[string]bool)
+	for _, col := range columns {
+		known[col] = true
+	}
+	seen := make(map[string]bool)
+	for _, key := range keys {
+		if !known[key] {
+			return nil, &ValidationError{Message: fmt.Sprintf("Unknown dedupe key column: %s", key)}
+		}
+		if seen[key] {
+			return nil, &ValidationError{Message: fmt.Sprintf("Dedupe key listed more than once: %s", key)}
+		}
+		seen[key] = true
+	}
+	return keys, nil
+}
+
+// parseDedupeKeys decodes the dedupe_keys column, treating bad JSON as no keys
+func parseDedupeKeys(data []byte) []string {
+	keys := []string{}
+	if len(data) > 0 {
+		json.Unmarshal(data, &keys)
+	}
+	return keys
+}
+
+// validateSkipRows checks an optional count of records above each file's header
+func validateSkipRows(skipRows *int) error {
+	if skipRows != nil && (*skipRows < 0 || *skipRows > datasource.MaxSkipRows) {
+		return &ValidationError{Message: fmt.Sprintf("Skip rows must be between 0 and %d", datasource.MaxSkipRows)}
+	}
+	return nil
+}
+
+// validateDelimiter checks an optional field delimiter
+func validateDelimiter(delimiter *string) error {
+	if delimiter == nil {
+		return nil
+	}
+	if d := []rune(*delimiter); len(d) != 1 || !datasource.IsDelimiter(d[0]) {
+		return &ValidationError{Message: `Delimiter must be ",", ";", "|" or a tab`}
+	}
+	return nil
+}
+
+// validateCombineMode checks a combine mode and join key, defaulting the mode to
+// append. The join key is dropped in append mode.
+func validateCombineMode(combineMode, joinKey string) (string, string, error) {
+	joinKey = strings.TrimSpace(joinKey)
+	switch combineMode {
+	case "", datasource.CombineAppend:
+		return datasource.CombineAppend, "", nil
+	case datasource.CombineJoin:
+		if joinKey == "" {
+			return "", "", &ValidationError{Message: "Join key is required in join mode"}
+		}
+		return datasource.CombineJoin, joinKey, nil
+	default:
+		return "", "", &ValidationError{Message: fmt.Sprintf("Invalid combine mode: %s (must be append or join)", combineMode)}
+	}
+}
+
+// Recount recomputes row_count from the dataset's table and stores it.
+// Returns nil if the dataset does not exist.
+func (r *DatasetRepository) Recount(id int) (*models.Dataset, error) {
+	info, err := r.GetDatasetInfo(id)
+	if err != nil {
+		return nil, err
+	}
+	if info == nil {
+		return nil, nil
+	}
+
+	rowCount, err := r.storage.GetRowCount(info.TableName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count rows: %w", err)
+	}
+
+	_, err = r.db.Exec("UPDATE datasets SET row_count = $1, updated_at = NOW() WHERE id = $2", rowCount, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update row count: %w", err)
+	}
+
+	return r.GetByID(id)
+}
+
+// GetAggregationOptions returns each column's inferred type and the aggregation
+// operators valid for it. Returns nil if the dataset does not exist.
+func (r *DatasetRepository) GetAggregationOptions(id int) (*models.AggregationOptionsResponse, error) {
+	info, err := r.GetDatasetInfo(id)
+	if err != nil {
+		return nil, err
+	}
+	if info == nil {
+		return nil, nil
+	}
+
+	response := &models.AggregationOptionsResponse{Columns: []models.DatasetColumnOptions{}}
+
+	exists, err := r.storage.TableExists(info.TableName)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return response, nil
+	}
+
+	columns, err := r.storage.GetColumns(id, info.TableName)
+	if err != nil {
+		return nil, err
+	}
+	types, err := r.storage.InferColumnTypes(info.TableName, columns)
+	if err != nil {
+		return nil, err
+	}
+
+	descriptions, err := r.getColumnDescriptions(id)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, col := range columns {
+		response.Columns = append(response.Columns, models.DatasetColumnOptions{
+			Name:        col,
+			Type:        types[col],
+			Operators:   validation.AggregationOperators(types[col]),
+			Description: descriptions[col],
+		})
+	}
+	return response, nil
+}
+
+// GetDateRange returns the earliest and latest dates in a date-typed column.
+// Returns nil if the dataset does not exist.
+func (r *DatasetRepository) GetDateRange(id int, column string) (*models.DatasetDateRange, error) {
+	if column == "" {
+		return nil, &ValidationError{Message: "Column is required"}
+	}
+
+	info, err := r.GetDatasetInfo(id)
+	if err != nil {
+		return nil, err
+	}
+	if info == nil {
+		return nil, nil
+	}
+
+	columns, err := r.columnSet(id, info)
+	if err != nil {
+		return nil, err
+	}
+	if !columns[column] {
+		return nil, &ValidationError{Message: fmt.Sprintf("Unknown column: %s", column)}
+	}
+
+	types, err := r.storage.InferColumnTypes(info.TableName, []string{column})
+	if err != nil {
+		return nil, err
+	}
+	if types[column] != validation.ColumnTypeDate {
+		return nil, &ValidationError{Message: fmt.Sprintf("Column %s is %s, not date", column, types[column])}
+	}
+
+	minDate, maxDate, err := r.storage.GetDateRange(info.TableName, column)
+	if err != nil {
+		return nil, err
+	}
+
+	dateRange := &models.DatasetDateRange{Column: column}
+	if minDate != "" {
+		dateRange.Min = &minDate
+		dateRange.Max = &maxDate
+	}
+	return dateRange, nil
+}
+
+// DefaultStatsTopValues is how many of a column's most frequent values GetStats reports
+const DefaultStatsTopValues = 5
+
+// GetStats summarizes every column of a ready dataset. Returns nil if the dataset
+// does not exist and ErrDatasetNotReady if it is pending, syncing, or failed.
+func (r *DatasetRepository) GetStats(id, topN int) (*models.DatasetStatsResponse, error) {
+	info, err := r.GetDatasetInfo(id)
+	if err != nil {
+		return nil, err
+	}
+	if info == nil {
+		return nil, nil
+	}
+	if info.Status != "ready" || r.syncService.IsSyncing(id) {
+		return nil, fmt.Errorf("%w (status: %s)", ErrDatasetNotReady, info.Status)
+	}
+
+	response := &models.DatasetStatsResponse{DatasetID: id, Columns: []models.DatasetColumnStats{}}
+
+	exists, err := r.storage.TableExists(info.TableName)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return response, nil
+	}
+
+	response.RowCount, err = r.storage.GetRowCount(info.TableName)
+	if err != nil {
+		return nil, err
+	}
+	columns, err := r.storage.GetColumns(id, info.TableName)
+	if err != nil {
+		return nil, err
+	}
+	types, err := r.storage.InferColumnTypes(info.TableName, columns)
+	if err != nil {
+		return nil, err
+	}
+	stats, err := r.storage.GetColumnStats(info.TableName, columns, types, topN)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, s := range stats {
+		col := models.DatasetColumnStats{
+			Name:      s.Column,
+			Type:      s.Type,
+			NullCount: s.NullCount,
+			Min:       s.Min,
+			Max:       s.Max,
+			Avg:       s.Avg,
+			Sum:       s.Sum,
+		}
+		if s.Type != validation.ColumnTypeNumeric {
+			distinct := s.DistinctCount
+			col.DistinctCount = &distinct
+			col.TopValues = []models.DatasetValueCount{}
+			for _, vc := range s.TopValues {
+				col.TopValues = append(col.TopValues, models.DatasetValueCount{Value: vc.Value, Count: vc.Count})
+			}
+		}
+		response.Columns = append(response.Columns, col)
+	}
+	return response, nil
+}
+
+// MaxColumnDescriptionLength bounds the length of a column description
+const MaxColumnDescriptionLength = 1000
+
+// UpdateColumnDescription sets or, when empty, clears the description of a
+// dataset column. Returns nil if the dataset does not exist.
+func (r *DatasetRepository) UpdateColumnDescription(id int, column string, req *models.UpdateColumnDescriptionRequest) (*models.DatasetColumnDescription, error) {
+	description := strings.TrimSpace(req.Description)
+	if utf8.RuneCountInString(description) > MaxColumnDescriptionLength {
+		return nil, &ValidationError{Message: fmt.Sprintf("Description must be at most %d characters", MaxColumnDescriptionLength)}
+	}
+
+	info, err := r.GetDatasetInfo(id)
+	if err != nil {
+		return nil, err
+	}
+	if info == nil {
+		return nil, nil
+	}
+
+	columns, err := r.columnSet(id, info)
+	if err != nil {
+		return nil, err
+	}
+	if !columns[column] {
+		return nil, &ValidationError{Message: fmt.Sprintf("Unknown column: %s", column)}
+	}
+
+	if description == "" {
+		_, err = r.db.Exec("DELETE FROM dataset_column_descriptions WHERE dataset_id = $1 AND column_name = $2", id, column)
+	} else {
+		_, err = r.db.Exec(`
+			INSERT INTO dataset_column_descriptions (dataset_id, column_name, description)
+			VALUES ($1, $2, $3)
+			ON CONFLICT (dataset_id, column_name)
+			DO UPDATE SET description = EXCLUDED.description, updated_at = NOW()
+		`, id, column, description)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to update column description: %w", err)
+	}
+
+	return &models.DatasetColumnDescription{Column: column, Description: description}, nil
+}
+
+// MaxColumnDisplayNameLength bounds the length of a column's display name
+const MaxColumnDisplayNameLength = 255
+
+// UpdateColumnSettings replaces a dataset's column labels and ordering. The
+// physical column names are unchanged. Returns nil if the dataset does not exist.
+func (r *DatasetRepository) UpdateColumnSettings(id int, req *models.UpdateColumnSettingsRequest) ([]models.DatasetColumnSetting, error) {
+	info, err := r.GetDatasetInfo(id)
+	if err != nil {
+		return nil, err
+	}
+	if info == nil {
+		return nil, nil
+	}
+
+	columns, err := r.columnSet(id, info)
+	if err != nil {
+		return nil, err
+	}
+
+	settings := make([]models.DatasetColumnSetting, 0, len(req.Columns))
+	seen := make(map[string]bool)
+	for _, c := range req.Columns {
+		if !columns[c.Name] {
+			return nil, &ValidationError{Message: fmt.Sprintf("Unknown column: %s", c.Name)}
+		}
+		if seen[c.Name] {
+			return nil, &ValidationError{Message: fmt.Sprintf("Column listed more than once: %s", c.Name)}
+		}
+		seen[c.Name] = true
+
+		displayName := strings.TrimSpace(c.DisplayName)
+		if utf8.RuneCountInString(displayName) > MaxColumnDisplayNameLength {
+			return nil, &ValidationError{Message: fmt.Sprintf("Display name must be at most %d characters", MaxColumnDisplayNameLength)}
+		}
+		settings = append(settings, models.DatasetColumnSetting{Name: c.Name, DisplayName: displayName})
+	}
+
+	tx, err := r.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec("DELETE FROM dataset_column_settings WHERE dataset_id = $1", id); err != nil {
+		return nil, fmt.Errorf("failed to clear column settings: %w", err)
+	}
+	for i, s := range settings {
+		var displayName sql.NullString
+		if s.DisplayName != "" {
+			displayName = sql.NullString{String: s.DisplayName, Valid: true}
+		}
+		_, err := tx.Exec(`
+			INSERT INTO dataset_column_settings (dataset_id, column_name, display_name, position)
+			VALUES ($1, $2, $3, $4)
+		`, id, s.Name, displayName, i)
+		if err != nil {
+			return nil, fmt.Errorf("failed to save column setting: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return settings, nil
+}
+
+// columnSet returns the dataset's physical column names; it is empty if the
+// dataset table has not been created yet
+func (r *DatasetRepository) columnSet(id int, info *service.DatasetInfo) (map[string]bool, error) {
+	set := make(map[string]bool)
+	exists, err := r.storage.TableExists(info.TableName)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return set, nil
+	}
+	columns, err := r.storage.GetColumns(id, info.TableName)
+	if err != nil {
+		return nil, err
+	}
+	for _, col := range columns {
+		set[col] = true
+	}
+	return set, nil
+}
+
+// getColumnDescriptions returns the stored column descriptions keyed by column name
+func (r *DatasetRepository) getColumnDescriptions(id int) (map[string]string, error) {
+	rows, err := r.db.Query("SELECT column_name, description FROM dataset_column_descriptions WHERE dataset_id = $1", id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get column descriptions: %w", err)
+	}
+	defer rows.Close()
+
+	descriptions := make(map[string]string)
+	for rows.Next() {
+		var column, description string
+		if err := rows.Scan(&column, &description); err != nil {
+			return nil, fmt.Errorf("failed to scan column description: %w", err)
+		}
+		descriptions[column] = description
+	}
+	return descriptions, rows.Err()
+}
+
+// GetSyncOverview returns sync metadata and staleness for every folder-backed dataset
+func (r *DatasetRepository) GetSyncOverview() ([]models.DatasetSyncOverview, error) {
+	query := `
+		SELECT id, name, folder_path, status, COALESCE(error_message, ''), last_commit_hash, last_synced_at
+		FROM datasets
+		WHERE folder_path IS NOT NULL AND folder_path != ''
+		ORDER BY name ASC
+	`
+	rows, err := r.db.Query(query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query datasets: %w", err)
+	}
+	defer rows.Close()
+
+	var overview []models.DatasetSyncOverview
+	var infos []*service.DatasetInfo
+	for rows.Next() {
+		var o models.DatasetSyncOverview
+		var lastCommitHash sql.NullString
+		var lastSyncedAt sql.NullTime
+		if err := rows.Scan(&o.ID, &o.Name, &o.FolderPath, &o.Status, &o.ErrorMessage, &lastCommitHash, &lastSyncedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan dataset: %w", err)
+		}
+		if lastCommitHash.Valid {
+			o.LastCommitHash = lastCommitHash.String
+		}
+		if lastSyncedAt.Valid {
+			o.LastSyncedAt = &lastSyncedAt.Time
+			seconds := int64(time.Since(lastSyncedAt.Time).Seconds())
+			o.SecondsSinceLastSync = &seconds
+		}
+		overview = append(overview, o)
+		infos = append(infos, &service.DatasetInfo{
+			ID:             o.ID,
+			Name:           o.Name,
+			FolderPath:     o.FolderPath,
+			LastCommitHash: lastCommitHash,
+			Status:         o.Status,
+		})
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// Check each folder for changes since the last sync
+	for i := range overview {
+		overview[i].Syncing = r.syncService.IsSyncing(overview[i].ID)
+		hasChanges, err := r.syncService.NeedsSync(infos[i])
+		if err != nil {
+			overview[i].ChangeCheckError = err.Error()
+			continue
+		}
+		overview[i].HasChanges = hasChanges
+	}
+
+	return overview, nil
+}
+
+// GetFailures returns datasets in error status, most recent failure first
+func (r *DatasetRepository) GetFailures() ([]models.DatasetFailure, error) {
+	query := `
+		SELECT id, name, COALESCE(folder_path, ''), COALESCE(error_message, ''), COALESCE(last_error_at, updated_at),
+		       last_synced_at, COALESCE(last_commit_hash, '')
+		FROM datasets
+		WHERE status = 'error'
+		ORDER BY COALESCE(last_error_at, updated_at) DESC, id DESC
+	`
+	rows, err := r.db.Query(query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query failed datasets: %w", err)
+	}
+	defer rows.Close()
+
+	var failures []models.DatasetFailure
+	for rows.Next() {
+		var f models.DatasetFailure
+		var lastSyncedAt sql.NullTime
+		if err := rows.Scan(&f.ID, &f.Name, &f.FolderPath, &f.ErrorMessage, &f.FailedAt, &lastSyncedAt, &f.LastCommitHash); err != nil {
+			return nil, fmt.Errorf("failed to scan dataset: %w", err)
+		}
+		if lastSyncedAt.Valid {
+			f.LastSyncedAt = &lastSyncedAt.Time
+		}
+		failures = append(failures, f)
+	}
+	return failures, rows.Err()
+}
+
+func (r *DatasetRepository) IsSyncing(id int) bool {
+	return r.syncService.IsSyncing(id)
+}
+
+// StreamAllData streams every row of a dataset to the callbacks (see
+// storage.DatasetStorage.StreamAllData). Returns false if the dataset does not exist.
+func (r *DatasetRepository) StreamAllData(ctx context.Context, id int, onColumns func(columns []string) error, onRow func(row []any) error) (bool, error) {
+	info, err := r.GetDatasetInfo(id)
+	if err != nil {
+		return false, err
+	}
+	if info == nil {
+		return false, nil
+	}
+
+	return true, r.storage.StreamAllData(ctx, id, info.TableName, onColumns, onRow)
 }
@@ -0,0 +1,117 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"finance-tracker/internal/models"
+)
+
+type HistoryRepository struct {
+	db *sql.DB
+}
+
+func NewHistoryRepository(db *sql.DB) *HistoryRepository {
+	return &HistoryRepository{db: db}
+}
+
+// Downsample thins out balance history recorded before olderThan, keeping only the
+// latest entry per entity per bucket. keep is the bucket size: "day" or "week".
+// The most recent entry for each entity is always preserved regardless of age.
+// Returns the number of deleted rows.
+func (r *HistoryRepository) Downsample(olderThan time.Time, keep string) (int64, error) {
+	if keep != "day" && keep != "week" {
+		return 0, &ValidationError{Message: "keep must be 'day' or 'week'"}
+	}
+
+	tx, err := r.db.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	// Rows on either side of olderThan are ranked separately, so a bucket that
+	// straddles the cutoff still keeps its latest row from before the cutoff
+	query := `
+		WITH ranked AS (
+			SELECT id,
+			       ROW_NUMBER() OVER (
+			           PARTITION BY entity_type, entity_id, date_trunc($2, recorded_at), recorded_at < $1
+			           ORDER BY recorded_at DESC, id DESC
+			       ) AS bucket_rank,
+			       ROW_NUMBER() OVER (
+			           PARTITION BY entity_type, entity_id
+			           ORDER BY recorded_at DESC, id DESC
+			       ) AS recency_rank
+			FROM entity_balance_history
+		)
+		DELETE FROM entity_balance_history h
+		USING ranked
+		WHERE h.id = ranked.id
+		  AND h.recorded_at < $1
+		  AND ranked.bucket_rank > 1
+		  AND ranked.recency_rank > 1
+	`
+	result, err := tx.Exec(query, olderThan, keep)
+	if err != nil {
+		return 0, fmt.Errorf("failed to downsample history: %w", err)
+	}
+
+	deleted, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return deleted, nil
+}
+
+// GetStats reports history row counts per entity, split into rows written directly by
+// balance updates (regular accounts) and rows written by propagation (calculated
+// accounts, groups, institutions and dashboards). Entities are sorted by row count.
+func (r *HistoryRepository) GetStats() (*models.HistoryStatsResponse, error) {
+	query := `
+		SELECT h.entity_type, h.entity_id,
+		       COALESCE(a.account_name, g.name, d.name, MAX(h.entity_name_snapshot)),
+		       COUNT(*),
+		       COALESCE(h.entity_type = 'account' AND NOT a.is_calculated, false),
+		       MIN(h.recorded_at), MAX(h.recorded_at)
+		FROM entity_balance_history h
+		LEFT JOIN account_balances a ON h.entity_type = 'account' AND a.id = h.entity_id
+		LEFT JOIN account_groups g ON h.entity_type IN ('group', 'institution') AND g.id = h.entity_id
+		LEFT JOIN dashboards d ON h.entity_type = 'dashboard' AND d.id = h.entity_id
+		GROUP BY h.entity_type, h.entity_id, a.account_name, a.is_calculated, g.name, d.name
+		ORDER BY COUNT(*) DESC, h.entity_type, h.entity_id
+	`
+	rows, err := r.db.Query(query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query history stats: %w", err)
+	}
+	defer rows.Close()
+
+	stats := &models.HistoryStatsResponse{
+		RowsByType: make(map[string]int64),
+		Entities:   []models.EntityHistoryStats{},
+	}
+	for rows.Next() {
+		var e models.EntityHistoryStats
+		var direct bool
+		if err := rows.Scan(&e.EntityType, &e.EntityID, &e.Name, &e.Rows, &direct, &e.FirstRecordedAt, &e.LastRecordedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan history stats: %w", err)
+		}
+		if direct {
+			e.DirectRows = e.Rows
+		} else {
+			e.PropagatedRows = e.Rows
+		}
+		stats.TotalRows += e.Rows
+		stats.DirectRows += e.DirectRows
+		stats.PropagatedRows += e.PropagatedRows
+		stats.RowsByType[e.EntityType] += e.Rows
+		stats.Entities = append(stats.Entities, e)
+	}
+	return stats, rows.Err()
+}
@@ -3,14 +3,19 @@ package repository
 import (
 	"database/sql"
 	"fmt"
+
+	"finance-tracker/internal/models"
 )
 
 type MembershipRepository struct {
-	db *sql.DB
+	db                        *sql.DB
+	allowMultipleInstitutions bool
 }
 
-func NewMembershipRepository(db *sql.DB) *MembershipRepository {
-	return &MembershipRepository{db: db}
+// NewMembershipRepository creates a membership repository. When allowMultipleInstitutions
+// is false an account belongs to at most one institution.
+func NewMembershipRepository(db *sql.DB, allowMultipleInstitutions bool) *MembershipRepository {
+	return &MembershipRepository{db: db, allowMultipleInstitutions: allowMultipleInstitutions}
 }
 
 // GetGroupsForAccount returns all group IDs for an account (excludes institutions)
@@ -263,12 +268,13 @@ func (r *MembershipRepository) UpdatePositionsInGroup(groupID int, positions []s
 	return nil
 }
 
-// GetInstitutionForAccount returns the institution ID for an account (if any)
+// GetInstitutionForAccount returns the primary (lowest ID) institution for an account (if any)
 func (r *MembershipRepository) GetInstitutionForAccount(accountID int) (*int, error) {
 	query := `
 		SELECT m.group_id FROM account_group_memberships m
 		JOIN account_groups g ON m.group_id = g.id
 		WHERE m.account_id = $1 AND g.entity_type = 'institution'
+		ORDER BY m.group_id
 		LIMIT 1
 	`
 	var institutionID int
@@ -282,13 +288,13 @@ func (r *MembershipRepository) GetInstitutionForAccount(accountID int) (*int, er
 	return &institutionID, nil
 }
 
-// GetAllInstitutionMemberships returns a map of account ID -> institution ID
-func (r *MembershipRepository) GetAllInstitutionMemberships() (map[int]int, error) {
+// GetAllInstitutionMemberships returns a map of account ID -> institution IDs
+func (r *MembershipRepository) GetAllInstitutionMemberships() (map[int][]int, error) {
 	query := `
 		SELECT m.account_id, m.group_id FROM account_group_memberships m
 		JOIN account_groups g ON m.group_id = g.id
 		WHERE g.entity_type = 'institution'
-		ORDER BY m.account_id
+		ORDER BY m.account_id, m.group_id
 	`
 	rows, err := r.db.Query(query)
 	if err != nil {
@@ -296,26 +302,34 @@ func (r *MembershipRepository) GetAllInstitutionMemberships() (map[int]int, erro
 	}
 	defer rows.Close()
 
-	result := make(map[int]int)
+	result := make(map[int][]int)
 	for rows.Next() {
 		var accountID, institutionID int
 		if err := rows.Scan(&accountID, &institutionID); err != nil {
 			return nil, fmt.Errorf("failed to scan institution membership: %w", err)
 		}
-		result[accountID] = institutionID
+		result[accountID] = append(result[accountID], institutionID)
 	}
 	return result, nil
 }
 
-// SetInstitution sets or clears the institution for an account
-// An account can only belong to one institution at a time
-func (r *MembershipRepository) SetInstitution(accountID int, institutionID *int) error {
+// SetInstitution sets or clears the institution for an account, replacing any
+// institutions it already belongs to. History snapshots are recorded for both the
+// previous and the new institution in the same transaction, and their new totals
+// are returned.
+func (r *MembershipRepository) SetInstitution(accountID int, institutionID *int) (*models.SetInstitutionResponse, error) {
 	tx, err := r.db.Begin()
 	if err != nil {
-		return fmt.Errorf("failed to begin transaction: %w", err)
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
 	}
 	defer tx.Rollback()
 
+	// Remember the current institutions so their history can be updated too
+	previousIDs, err := r.getInstitutionIDsTx(tx, accountID)
+	if err != nil {
+		return nil, err
+	}
+
 	// First, remove account from any existing institutions
 	_, err = tx.Exec(`
 		DELETE FROM account_group_memberships
@@ -324,46 +338,223 @@ func (r *MembershipRepository) SetInstitution(accountID int, institutionID *int)
 		)
 	`, accountID)
 	if err != nil {
-		return fmt.Errorf("failed to remove existing institution membership: %w", err)
+		return nil, fmt.Errorf("failed to remove existing institution membership: %w", err)
 	}
 
 	// If institutionID is provided, add the account to that institution
 	if institutionID != nil {
-		// Verify the target is actually an institution
-		var entityType string
-		err := tx.QueryRow("SELECT entity_type FROM account_groups WHERE id = $1", *institutionID).Scan(&entityType)
-		if err == sql.ErrNoRows {
-			return fmt.Errorf("institution not found")
+		if err := r.addInstitutionTx(tx, accountID, *institutionID); err != nil {
+			return nil, err
 		}
-		if err != nil {
-			return fmt.Errorf("failed to verify institution: %w", err)
-		}
-		if entityType != "institution" {
-			return fmt.Errorf("target is not an institution")
+	}
+
+	// Only snapshot history for institutions whose membership actually changed
+	var changedIDs []int
+	unchanged := false
+	for _, id := range previousIDs {
+		if institutionID != nil && id == *institutionID {
+			unchanged = true
+			continue
 		}
+		changedIDs = append(changedIDs, id)
+	}
+	if institutionID != nil && !unchanged {
+		changedIDs = append(changedIDs, *institutionID)
+	}
 
-		// Get max position for this institution
-		var maxPos sql.NullInt64
-		err = tx.QueryRow("SELECT MAX(position_in_group) FROM account_group_memberships WHERE group_id = $1", *institutionID).Scan(&maxPos)
-		if err != nil {
-			return fmt.Errorf("failed to get max position: %w", err)
+	totalIDs := changedIDs
+	if institutionID != nil && unchanged {
+		totalIDs = append(totalIDs, *institutionID)
+	}
+	totals, err := r.institutionTotalsTx(tx, totalIDs, changedIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	response := &models.SetInstitutionResponse{}
+	for _, id := range previousIDs {
+		if institutionID == nil || id != *institutionID {
+			response.PreviousInstitution = &models.InstitutionTotal{ID: id, TotalBalance: totals[id]}
+			break
 		}
-		newPos := 1
-		if maxPos.Valid {
-			newPos = int(maxPos.Int64) + 1
+	}
+	if institutionID != nil {
+		response.Institution = &models.InstitutionTotal{ID: *institutionID, TotalBalance: totals[*institutionID]}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return response, nil
+}
+
+// AddInstitution adds an account to an additional institution, keeping its existing ones.
+// Only allowed when multiple institutions per account are enabled.
+func (r *MembershipRepository) AddInstitution(accountID, institutionID int) (*models.InstitutionTotal, error) {
+	if !r.allowMultipleInstitutions {
+		return nil, &ValidationError{Message: "accounts can only belong to one institution; use PATCH /accounts/{id}/institution"}
+	}
+
+	tx, err := r.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	currentIDs, err := r.getInstitutionIDsTx(tx, accountID)
+	if err != nil {
+		return nil, err
+	}
+	alreadyMember := false
+	for _, id := range currentIDs {
+		if id == institutionID {
+			alreadyMember = true
 		}
+	}
 
-		_, err = tx.Exec(`
-			INSERT INTO account_group_memberships (account_id, group_id, position_in_group)
-			VALUES ($1, $2, $3)
-		`, accountID, *institutionID, newPos)
-		if err != nil {
-			return fmt.Errorf("failed to add institution membership: %w", err)
+	var changedIDs []int
+	if !alreadyMember {
+		if err := r.addInstitutionTx(tx, accountID, institutionID); err != nil {
+			return nil, err
 		}
+		changedIDs = []int{institutionID}
+	}
+
+	totals, err := r.institutionTotalsTx(tx, []int{institutionID}, changedIDs)
+	if err != nil {
+		return nil, err
 	}
 
 	if err := tx.Commit(); err != nil {
-		return fmt.Errorf("failed to commit transaction: %w", err)
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return &models.InstitutionTotal{ID: institutionID, TotalBalance: totals[institutionID]}, nil
+}
+
+// RemoveInstitution removes an account from one of its institutions
+func (r *MembershipRepository) RemoveInstitution(accountID, institutionID int) (*models.InstitutionTotal, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(`
+		DELETE FROM account_group_memberships
+		WHERE account_id = $1 AND group_id = $2 AND group_id IN (
+			SELECT id FROM account_groups WHERE entity_type = 'institution'
+		)
+	`, accountID, institutionID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to remove institution membership: %w", err)
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	var changedIDs []int
+	if rowsAffected > 0 {
+		changedIDs = []int{institutionID}
+	}
+	totals, err := r.institutionTotalsTx(tx, []int{institutionID}, changedIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return &models.InstitutionTotal{ID: institutionID, TotalBalance: totals[institutionID]}, nil
+}
+
+// getInstitutionIDsTx returns the IDs of all institutions an account belongs to
+func (r *MembershipRepository) getInstitutionIDsTx(tx *sql.Tx, accountID int) ([]int, error) {
+	rows, err := tx.Query(`
+		SELECT m.group_id FROM account_group_memberships m
+		JOIN account_groups g ON m.group_id = g.id
+		WHERE m.account_id = $1 AND g.entity_type = 'institution'
+		ORDER BY m.group_id
+	`, accountID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get current institutions: %w", err)
+	}
+	defer rows.Close()
+
+	var ids []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("failed to scan institution: %w", err)
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// addInstitutionTx verifies the target is an institution and appends the account to it
+func (r *MembershipRepository) addInstitutionTx(tx *sql.Tx, accountID, institutionID int) error {
+	// Verify the target is actually an institution
+	var entityType string
+	err := tx.QueryRow("SELECT entity_type FROM account_groups WHERE id = $1", institutionID).Scan(&entityType)
+	if err == sql.ErrNoRows {
+		return fmt.Errorf("institution not found")
+	}
+	if err != nil {
+		return fmt.Errorf("failed to verify institution: %w", err)
+	}
+	if entityType != "institution" {
+		return fmt.Errorf("target is not an institution")
+	}
+
+	// Get max position for this institution
+	var maxPos sql.NullInt64
+	err = tx.QueryRow("SELECT MAX(position_in_group) FROM account_group_memberships WHERE group_id = $1", institutionID).Scan(&maxPos)
+	if err != nil {
+		return fmt.Errorf("failed to get max position: %w", err)
+	}
+	newPos := 1
+	if maxPos.Valid {
+		newPos = int(maxPos.Int64) + 1
+	}
+
+	_, err = tx.Exec(`
+		INSERT INTO account_group_memberships (account_id, group_id, position_in_group)
+		VALUES ($1, $2, $3)
+	`, accountID, institutionID, newPos)
+	if err != nil {
+		return fmt.Errorf("failed to add institution membership: %w", err)
 	}
 	return nil
 }
+
+// institutionTotalsTx calculates the totals of institutionIDs and records a history
+// snapshot for each institution in historyIDs
+func (r *MembershipRepository) institutionTotalsTx(tx *sql.Tx, institutionIDs []int, historyIDs []int) (map[int]float64, error) {
+	if len(institutionIDs) == 0 {
+		return map[int]float64{}, nil
+	}
+
+	accountRepo := NewAccountRepository(r.db)
+	allAccounts, err := accountRepo.getAllAccountsTx(tx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch accounts for institution totals: %w", err)
+	}
+	ResolveCalculatedBalances(allAccounts)
+	balanceMap := make(map[int]float64)
+	for _, a := range allAccounts {
+		balanceMap[a.ID] = a.CurrentBalance
+	}
+
+	if len(historyIDs) > 0 {
+		if err := accountRepo.insertGroupHistoryRecordsTx(tx, historyIDs, balanceMap); err != nil {
+			return nil, fmt.Errorf("failed to insert institution history records: %w", err)
+		}
+	}
+
+	totals, err := accountRepo.calculateGroupBalances(tx, institutionIDs, balanceMap)
+	if err != nil {
+		return nil, fmt.Errorf("failed to calculate institution totals: %w", err)
+	}
+	return totals, nil
+}
@@ -0,0 +1,221 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+
+	"finance-tracker/internal/models"
+)
+
+type PositionRepository struct {
+	db *sql.DB
+}
+
+func NewPositionRepository(db *sql.DB) *PositionRepository {
+	return &PositionRepository{db: db}
+}
+
+// positionEntry is one positioned item within an ordering scope
+type positionEntry struct {
+	entityType string
+	id         int
+	name       string
+	position   int
+}
+
+// positionScope is one independent ordering: the main list, the institution list,
+// or the accounts within a single group or institution
+type positionScope struct {
+	name    string
+	scopeID *int
+	entries []positionEntry
+}
+
+// CheckHealth reports every account, group and institution whose position is
+// duplicated or out of sequence within its ordering scope
+func (r *PositionRepository) CheckHealth() (*models.PositionHealthResponse, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	scopes, err := r.getScopesTx(tx)
+	if err != nil {
+		return nil, err
+	}
+
+	issues := []models.PositionIssue{}
+	for _, scope := range scopes {
+		issues = append(issues, findPositionIssues(scope)...)
+	}
+	return &models.PositionHealthResponse{Healthy: len(issues) == 0, Issues: issues}, nil
+}
+
+// FixPositions renumbers every ordering scope to 1..n, keeping the current order
+// and breaking ties by type and ID. Returns the issues that were fixed.
+func (r *PositionRepository) FixPositions() (*models.FixPositionsResponse, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	scopes, err := r.getScopesTx(tx)
+	if err != nil {
+		return nil, err
+	}
+
+	fixed := []models.PositionIssue{}
+	for _, scope := range scopes {
+		issues := findPositionIssues(scope)
+		for _, issue := range issues {
+			if err := r.updatePositionTx(tx, scope, issue); err != nil {
+				return nil, err
+			}
+		}
+		fixed = append(fixed, issues...)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return &models.FixPositionsResponse{Fixed: fixed}, nil
+}
+
+// findPositionIssues compares each entry's position with its index in the scope.
+// Entries must already be sorted by position.
+func findPositionIssues(scope positionScope) []models.PositionIssue {
+	counts := make(map[int]int)
+	for _, e := range scope.entries {
+		counts[e.position]++
+	}
+
+	var issues []models.PositionIssue
+	for i, e := range scope.entries {
+		expected := i + 1
+		if e.position == expected && counts[e.position] == 1 {
+			continue
+		}
+		problem := "gap"
+		if counts[e.position] > 1 {
+			problem = "duplicate"
+		}
+		issues = append(issues, models.PositionIssue{
+			Scope:            scope.name,
+			ScopeID:          scope.scopeID,
+			EntityType:       e.entityType,
+			EntityID:         e.id,
+			Name:             e.name,
+			Position:         e.position,
+			ExpectedPosition: expected,
+			Problem:          problem,
+		})
+	}
+	return issues
+}
+
+func (r *PositionRepository) updatePositionTx(tx *sql.Tx, scope positionScope, issue models.PositionIssue) error {
+	var err error
+	switch {
+	case scope.scopeID != nil:
+		_, err = tx.Exec(`
+			UPDATE account_group_memberships SET position_in_group = $1
+			WHERE account_id = $2 AND group_id = $3
+		`, issue.ExpectedPosition, issue.EntityID, *scope.scopeID)
+	case issue.EntityType == "account":
+		_, err = tx.Exec("UPDATE account_balances SET position = $1 WHERE id = $2", issue.ExpectedPosition, issue.EntityID)
+	default:
+		_, err = tx.Exec("UPDATE account_groups SET position = $1 WHERE id = $2", issue.ExpectedPosition, issue.EntityID)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to update position for %s %d: %w", issue.EntityType, issue.EntityID, err)
+	}
+	return nil
+}
+
+// getScopesTx loads every ordering scope with its entries sorted by position
+func (r *PositionRepository) getScopesTx(tx *sql.Tx) ([]positionScope, error) {
+	var scopes []positionScope
+
+	// Accounts and groups share one position space in the main list
+	mainList, err := r.queryEntriesTx(tx, `
+		SELECT entity_type, id, name, position FROM (
+			SELECT 'account' AS entity_type, id, account_name AS name, position
+			FROM account_balances WHERE is_archived = false
+			UNION ALL
+			SELECT 'group' AS entity_type, id, name, position
+			FROM account_groups WHERE is_archived = false AND entity_type = 'group'
+		) positions
+		ORDER BY position, entity_type, id
+	`)
+	if err != nil {
+		return nil, err
+	}
+	scopes = append(scopes, positionScope{name: "main_list", entries: mainList})
+
+	institutions, err := r.queryEntriesTx(tx, `
+		SELECT 'institution', id, name, position
+		FROM account_groups WHERE is_archived = false AND entity_type = 'institution'
+		ORDER BY position, id
+	`)
+	if err != nil {
+		return nil, err
+	}
+	scopes = append(scopes, positionScope{name: "institutions", entries: institutions})
+
+	// Accounts within each group and institution
+	rows, err := tx.Query("SELECT id, entity_type FROM account_groups WHERE is_archived = false AND entity_type IN ('group', 'institution') ORDER BY id")
+	if err != nil {
+		return nil, fmt.Errorf("failed to query groups: %w", err)
+	}
+	type groupRef struct {
+		id         int
+		entityType string
+	}
+	var groups []groupRef
+	for rows.Next() {
+		var g groupRef
+		if err := rows.Scan(&g.id, &g.entityType); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to scan group: %w", err)
+		}
+		groups = append(groups, g)
+	}
+	rows.Close()
+
+	for _, g := range groups {
+		members, err := r.queryEntriesTx(tx, `
+			SELECT 'account', a.id, a.account_name, m.position_in_group
+			FROM account_group_memberships m
+			JOIN account_balances a ON a.id = m.account_id
+			WHERE m.group_id = $1 AND a.is_archived = false
+			ORDER BY m.position_in_group, a.id
+		`, g.id)
+		if err != nil {
+			return nil, err
+		}
+		groupID := g.id
+		scopes = append(scopes, positionScope{name: g.entityType, scopeID: &groupID, entries: members})
+	}
+
+	return scopes, nil
+}
+
+func (r *PositionRepository) queryEntriesTx(tx *sql.Tx, query string, args ...interface{}) ([]positionEntry, error) {
+	rows, err := tx.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query positions: %w", err)
+	}
+	defer rows.Close()
+
+	var entries []positionEntry
+	for rows.Next() {
+		var e positionEntry
+		if err := rows.Scan(&e.entityType, &e.id, &e.name, &e.position); err != nil {
+			return nil, fmt.Errorf("failed to scan position: %w", err)
+		}
+		entries = append(entries, e)
+	}
+	return entries, rows.Err()
+}
@@ -0,0 +1,91 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+)
+
+type TagRepository struct {
+	db *sql.DB
+}
+
+func NewTagRepository(db *sql.DB) *TagRepository {
+	return &TagRepository{db: db}
+}
+
+// NormalizeTagName trims and lowercases a tag name so equivalent tags match
+func NormalizeTagName(name string) string {
+	return strings.ToLower(strings.TrimSpace(name))
+}
+
+// AddTag attaches a tag to an account, creating the tag if it doesn't exist
+func (r *TagRepository) AddTag(accountID int, name string) error {
+	name = NormalizeTagName(name)
+	if name == "" {
+		return &ValidationError{Message: "Tag name is required"}
+	}
+
+	tx, err := r.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var tagID int
+	err = tx.QueryRow(`
+		INSERT INTO account_tags (name) VALUES ($1)
+		ON CONFLICT (name) DO UPDATE SET name = EXCLUDED.name
+		RETURNING id
+	`, name).Scan(&tagID)
+	if err != nil {
+		return fmt.Errorf("failed to create tag: %w", err)
+	}
+
+	_, err = tx.Exec(`
+		INSERT INTO account_tag_memberships (account_id, tag_id) VALUES ($1, $2)
+		ON CONFLICT (account_id, tag_id) DO NOTHING
+	`, accountID, tagID)
+	if err != nil {
+		return fmt.Errorf("failed to add tag: %w", err)
+	}
+
+	return tx.Commit()
+}
+
+// RemoveTag detaches a tag from an account
+func (r *TagRepository) RemoveTag(accountID int, name string) error {
+	_, err := r.db.Exec(`
+		DELETE FROM account_tag_memberships
+		WHERE account_id = $1 AND tag_id = (SELECT id FROM account_tags WHERE name = $2)
+	`, accountID, NormalizeTagName(name))
+	if err != nil {
+		return fmt.Errorf("failed to remove tag: %w", err)
+	}
+	return nil
+}
+
+// GetTags returns the tag names for an account in alphabetical order
+func (r *TagRepository) GetTags(accountID int) ([]string, error) {
+	query := `
+		SELECT t.name FROM account_tag_memberships m
+		JOIN account_tags t ON m.tag_id = t.id
+		WHERE m.account_id = $1
+		ORDER BY t.name
+	`
+	rows, err := r.db.Query(query, accountID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query tags: %w", err)
+	}
+	defer rows.Close()
+
+	tags := []string{}
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, fmt.Errorf("failed to scan tag: %w", err)
+		}
+		tags = append(tags, name)
+	}
+	return tags, nil
+}
@@ -0,0 +1,214 @@
+package router
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// responseCasingHeader lets a client choose the casing of JSON response keys
+const responseCasingHeader = "X-Response-Casing"
+
+// verbatimKeyFields hold maps keyed by data (e.g. dataset column names), not field
+// names, so their keys are left as is
+var verbatimKeyFields = map[string]bool{
+	"column_descriptions": true,
+}
+
+// WithResponseCasing rewrites JSON response keys from snake_case to camelCase for
+// clients that send "X-Response-Casing: camel". RESPONSE_CASING=camel makes camelCase
+// the default, which clients can override with "X-Response-Casing: snake".
+// File downloads (responses with a Content-Disposition) are never rewritten.
+func WithResponseCasing(next http.Handler) http.Handler {
+	defaultCamel := isCamel(os.Getenv("RESPONSE_CASING"))
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		camel := defaultCamel
+		if value := r.Header.Get(responseCasingHeader); value != "" {
+			camel = isCamel(value)
+		}
+		w.Header().Add("Vary", responseCasingHeader)
+		if !camel {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		cw := &casingResponseWriter{ResponseWriter: w}
+		defer cw.Close()
+		next.ServeHTTP(cw, r)
+	})
+}
+
+func isCamel(value string) bool {
+	value = strings.ToLower(strings.TrimSpace(value))
+	return value == "camel" || value == "camelcase"
+}
+
+// casingResponseWriter buffers JSON responses so their keys can be rewritten once the
+// body is complete; other responses are passed straight through
+type casingResponseWriter struct {
+	http.ResponseWriter
+	status      int
+	buf         bytes.Buffer
+	decided     bool
+	passthrough bool
+}
+
+func (w *casingResponseWriter) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+	w.decide()
+}
+
+func (w *casingResponseWriter) Write(p []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	w.decide()
+	if w.passthrough {
+		return w.ResponseWriter.Write(p)
+	}
+	return w.buf.Write(p)
+}
+
+// decide picks buffering or passthrough from the headers set so far
+func (w *casingResponseWriter) decide() {
+	if w.decided {
+		return
+	}
+	w.decided = true
+	header := w.Header()
+	if !strings.HasPrefix(header.Get("Content-Type"), "application/json") || header.Get("Content-Disposition") != "" {
+		w.passthrough = true
+		w.ResponseWriter.WriteHeader(w.status)
+	}
+}
+
+// Close rewrites and writes the buffered JSON. Bodies that fail to parse are sent
+// unchanged.
+func (w *casingResponseWriter) Close() error {
+	if w.passthrough || w.status == 0 {
+		return nil
+	}
+
+	body := w.buf.Bytes()
+	var out bytes.Buffer
+	if err := camelCaseKeys(json.NewDecoder(bytes.NewReader(body)), &out); err == nil {
+		body = out.Bytes()
+	}
+	w.Header().Del("Content-Length")
+	w.ResponseWriter.WriteHeader(w.status)
+	_, err := w.ResponseWriter.Write(body)
+	return err
+}
+
+// Hijack lets websocket-style handlers take over the connection
+func (w *casingResponseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	if h, ok := w.ResponseWriter.(http.Hijacker); ok {
+		return h.Hijack()
+	}
+	return nil, nil, http.ErrNotSupported
+}
+
+// camelCaseKeys copies every JSON value from dec to out, renaming object keys. Key
+// order and number formatting are preserved.
+func camelCaseKeys(dec *json.Decoder, out *bytes.Buffer) error {
+	dec.UseNumber()
+	for {
+		err := copyValue(dec, out, true)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		out.WriteByte('\n')
+	}
+}
+
+// copyValue copies one JSON value, renaming object keys when rename is set
+func copyValue(dec *json.Decoder, out *bytes.Buffer, rename bool) error {
+	tok, err := dec.Token()
+	if err != nil {
+		return err
+	}
+
+	switch t := tok.(type) {
+	case json.Delim:
+		switch t {
+		case '{':
+			out.WriteByte('{')
+			for i := 0; dec.More(); i++ {
+				keyTok, err := dec.Token()
+				if err != nil {
+					return err
+				}
+				key := keyTok.(string)
+				if i > 0 {
+					out.WriteByte(',')
+				}
+				name := key
+				if rename {
+					name = toCamel(key)
+				}
+				encodedName, err := json.Marshal(name)
+				if err != nil {
+					return err
+				}
+				out.Write(encodedName)
+				out.WriteByte(':')
+				if err := copyValue(dec, out, rename && !verbatimKeyFields[key]); err != nil {
+					return err
+				}
+			}
+			if _, err := dec.Token(); err != nil {
+				return err
+			}
+			out.WriteByte('}')
+		case '[':
+			out.WriteByte('[')
+			for i := 0; dec.More(); i++ {
+				if i > 0 {
+					out.WriteByte(',')
+				}
+				if err := copyValue(dec, out, rename); err != nil {
+					return err
+				}
+			}
+			if _, err := dec.Token(); err != nil {
+				return err
+			}
+			out.WriteByte(']')
+		}
+	default:
+		encoded, err := json.Marshal(t)
+		if err != nil {
+			return err
+		}
+		out.Write(encoded)
+	}
+	return nil
+}
+
+// toCamel converts snake_case to camelCase, e.g. "current_balance" to "currentBalance"
+func toCamel(key string) string {
+	if !strings.Contains(key, "_") {
+		return key
+	}
+	parts := strings.Split(key, "_")
+	var b strings.Builder
+	b.WriteString(parts[0])
+	for _, part := range parts[1:] {
+		if part == "" {
+			continue
+		}
+		b.WriteString(strings.ToUpper(part[:1]) + part[1:])
+	}
+	return b.String()
+}
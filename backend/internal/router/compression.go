@@ -0,0 +1,167 @@
+package router
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// defaultGzipMinBytes is the smallest response that is worth compressing
+const defaultGzipMinBytes = 1024
+
+// alreadyCompressedTypes are content types that gain nothing from gzip
+var alreadyCompressedTypes = []string{
+	"application/zip",
+	"application/gzip",
+	"application/x-gzip",
+	"application/vnd.openxmlformats",
+	"image/",
+	"video/",
+	"audio/",
+}
+
+// WithCompression gzips responses of at least GZIP_MIN_BYTES (default 1024) for clients
+// that accept gzip. Set GZIP_ENABLED=false to turn it off.
+func WithCompression(next http.Handler) http.Handler {
+	if os.Getenv("GZIP_ENABLED") == "false" {
+		return next
+	}
+	minBytes := defaultGzipMinBytes
+	if value := os.Getenv("GZIP_MIN_BYTES"); value != "" {
+		if n, err := strconv.Atoi(value); err == nil && n >= 0 {
+			minBytes = n
+		}
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.Contains(r.Header.Get("Accept-Encoding"), "gzip") || r.Header.Get("Upgrade") != "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		w.Header().Add("Vary", "Accept-Encoding")
+		gw := &gzipResponseWriter{ResponseWriter: w, minBytes: minBytes}
+		defer gw.Close()
+		next.ServeHTTP(gw, r)
+	})
+}
+
+// gzipResponseWriter buffers the start of a response until it knows whether the body
+// is large enough to compress, then either streams it through gzip or writes it as is
+type gzipResponseWriter struct {
+	http.ResponseWriter
+	minBytes    int
+	status      int
+	buf         bytes.Buffer
+	gz          *gzip.Writer
+	passthrough bool
+}
+
+func (w *gzipResponseWriter) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+}
+
+func (w *gzipResponseWriter) Write(p []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	if w.gz != nil {
+		return w.gz.Write(p)
+	}
+	if w.passthrough {
+		return w.ResponseWriter.Write(p)
+	}
+
+	w.buf.Write(p)
+	if w.buf.Len() < w.minBytes {
+		return len(p), nil
+	}
+	if err := w.start(true); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// start commits the headers and flushes the buffered bytes, compressed if requested
+// and the response is eligible
+func (w *gzipResponseWriter) start(compress bool) error {
+	header := w.Header()
+	if compress && w.compressible() {
+		header.Set("Content-Encoding", "gzip")
+		header.Del("Content-Length")
+		w.ResponseWriter.WriteHeader(w.status)
+		w.gz = gzip.NewWriter(w.ResponseWriter)
+		_, err := w.gz.Write(w.buf.Bytes())
+		w.buf.Reset()
+		return err
+	}
+
+	w.passthrough = true
+	w.ResponseWriter.WriteHeader(w.status)
+	_, err := w.ResponseWriter.Write(w.buf.Bytes())
+	w.buf.Reset()
+	return err
+}
+
+func (w *gzipResponseWriter) compressible() bool {
+	header := w.Header()
+	if header.Get("Content-Encoding") != "" {
+		return false
+	}
+	if w.status == http.StatusNoContent || w.status == http.StatusNotModified {
+		return false
+	}
+	contentType := header.Get("Content-Type")
+	for _, t := range alreadyCompressedTypes {
+		if strings.HasPrefix(contentType, t) {
+			return false
+		}
+	}
+	return true
+}
+
+// Close finishes the response: small bodies are written uncompressed
+func (w *gzipResponseWriter) Close() error {
+	if w.gz != nil {
+		return w.gz.Close()
+	}
+	if w.passthrough {
+		return nil
+	}
+	if w.status == 0 {
+		// Nothing was written; let net/http send its default response
+		return nil
+	}
+	return w.start(false)
+}
+
+// Flush sends buffered data to the client, compressing from here on if eligible
+func (w *gzipResponseWriter) Flush() {
+	if w.gz == nil && !w.passthrough {
+		if w.status == 0 {
+			w.status = http.StatusOK
+		}
+		w.start(true)
+	}
+	if w.gz != nil {
+		w.gz.Flush()
+	}
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Hijack lets websocket-style handlers take over the connection
+func (w *gzipResponseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	if h, ok := w.ResponseWriter.(http.Hijacker); ok {
+		return h.Hijack()
+	}
+	return nil, nil, http.ErrNotSupported
+}
@@ -3,7 +3,9 @@ package router
 import (
 	"database/sql"
 	"os"
+	"strconv"
 	"strings"
+	"time"
 
 	"finance-tracker/internal/handlers"
 	"finance-tracker/internal/repository"
@@ -18,20 +20,25 @@ func New(db *sql.DB) *mux.Router {
 	r := mux.NewRouter()
 
 	// Initialize storage and sync service
-	datasetStorage := storage.NewPostgresStorage(db)
-	syncService := service.NewDatasetSyncService(db, datasetStorage)
+	datasetStorage := storage.NewPostgresStorage(db, datasetQueryTimeout())
+	syncService := service.NewDatasetSyncService(db, datasetStorage, datasetMaxRows())
 
 	// Initialize repositories and handlers
 	accountRepo := repository.NewAccountRepository(db)
 	groupRepo := repository.NewAccountGroupRepository(db)
-	membershipRepo := repository.NewMembershipRepository(db)
+	membershipRepo := repository.NewMembershipRepository(db, os.Getenv("ALLOW_MULTIPLE_INSTITUTIONS") == "true")
 	dashboardRepo := repository.NewDashboardRepository(db)
 	datasetRepo := repository.NewDatasetRepository(db, datasetStorage, syncService)
-	accountHandler := handlers.NewAccountHandler(accountRepo, membershipRepo)
+	historyRepo := repository.NewHistoryRepository(db)
+	tagRepo := repository.NewTagRepository(db)
+	positionRepo := repository.NewPositionRepository(db)
+	accountHandler := handlers.NewAccountHandler(accountRepo, membershipRepo, tagRepo, os.Getenv("REVEAL_ACCOUNT_NUMBERS") == "true")
 	groupHandler := handlers.NewAccountGroupHandler(groupRepo)
 	institutionHandler := handlers.NewInstitutionHandler(groupRepo)
 	dashboardHandler := handlers.NewDashboardHandler(dashboardRepo)
 	datasetHandler := handlers.NewDatasetHandler(datasetRepo)
+	maintenanceHandler := handlers.NewMaintenanceHandler(historyRepo, positionRepo)
+	reportHandler := handlers.NewReportHandler(accountRepo, groupRepo)
 
 	// API routes
 	api := r.PathPrefix("/api").Subrouter()
@@ -43,18 +50,36 @@ func New(db *sql.DB) *mux.Router {
 	api.HandleFunc("/accounts", accountHandler.GetAll).Methods("GET")
 	api.HandleFunc("/accounts", accountHandler.Create).Methods("POST")
 	api.HandleFunc("/accounts/positions", accountHandler.UpdatePositions).Methods("PATCH")
+	api.HandleFunc("/accounts/bulk-archive", accountHandler.BulkArchive).Methods("POST")
+	api.HandleFunc("/accounts/bulk-unarchive", accountHandler.BulkUnarchive).Methods("POST")
+	api.HandleFunc("/accounts/formulas/bulk", accountHandler.BulkUpdateFormulas).Methods("POST")
+	api.HandleFunc("/accounts/by-tag", accountHandler.GetTotalsByTag).Methods("GET")
 	api.HandleFunc("/accounts/{id}/unarchive", accountHandler.Unarchive).Methods("PATCH")
+	api.HandleFunc("/accounts/{id}/duplicate", accountHandler.Duplicate).Methods("POST")
 	api.HandleFunc("/accounts/{id}", accountHandler.GetByID).Methods("GET")
 	api.HandleFunc("/accounts/{id}", accountHandler.Delete).Methods("DELETE")
 	api.HandleFunc("/accounts/{id}/name", accountHandler.UpdateName).Methods("PATCH")
 	api.HandleFunc("/accounts/{id}/info", accountHandler.UpdateInfo).Methods("PATCH")
+	api.HandleFunc("/accounts/{id}/account-number", accountHandler.GetAccountNumber).Methods("GET")
+	api.HandleFunc("/accounts/{id}/account-number", accountHandler.UpdateAccountNumber).Methods("PATCH")
 	api.HandleFunc("/accounts/{id}/balance", accountHandler.UpdateBalance).Methods("PATCH")
 	api.HandleFunc("/accounts/{id}/archive", accountHandler.Archive).Methods("PATCH")
 	api.HandleFunc("/accounts/{id}/history", accountHandler.GetHistory).Methods("GET")
+	api.HandleFunc("/accounts/{id}/forecast", accountHandler.GetForecast).Methods("GET")
+	api.HandleFunc("/accounts/{id}/references", accountHandler.GetReferences).Methods("GET")
+	api.HandleFunc("/accounts/{id}/move", accountHandler.Move).Methods("PATCH")
 	api.HandleFunc("/accounts/{id}/membership", accountHandler.ModifyGroupMembership).Methods("PATCH")
 	api.HandleFunc("/accounts/{id}/groups", accountHandler.SetGroupMemberships).Methods("PUT")
 	api.HandleFunc("/accounts/{id}/formula", accountHandler.UpdateFormula).Methods("PATCH")
+	api.HandleFunc("/accounts/{id}/formula/repair", accountHandler.RepairFormula).Methods("POST")
+	api.HandleFunc("/accounts/{id}/broken-references", accountHandler.GetBrokenReferences).Methods("GET")
+	api.HandleFunc("/accounts/{id}/effective-formula", accountHandler.GetEffectiveFormula).Methods("GET")
 	api.HandleFunc("/accounts/{id}/institution", accountHandler.SetInstitution).Methods("PATCH")
+	api.HandleFunc("/accounts/{id}/institutions", accountHandler.AddInstitution).Methods("POST")
+	api.HandleFunc("/accounts/{id}/institutions/{institutionId}", accountHandler.RemoveInstitution).Methods("DELETE")
+	api.HandleFunc("/accounts/{id}/tags", accountHandler.GetTags).Methods("GET")
+	api.HandleFunc("/accounts/{id}/tags", accountHandler.AddTag).Methods("POST")
+	api.HandleFunc("/accounts/{id}/tags/{tag}", accountHandler.RemoveTag).Methods("DELETE")
 
 	// Group routes - /all must come before /{id} routes
 	api.HandleFunc("/groups/all", groupHandler.GetAllIncludingArchived).Methods("GET")
@@ -66,11 +91,16 @@ func New(db *sql.DB) *mux.Router {
 	api.HandleFunc("/groups/{id}", groupHandler.Update).Methods("PATCH")
 	api.HandleFunc("/groups/{id}", groupHandler.Delete).Methods("DELETE")
 	api.HandleFunc("/groups/{id}/archive", groupHandler.Archive).Methods("PATCH")
+	api.HandleFunc("/groups/{id}/parent", groupHandler.SetParent).Methods("PATCH")
+	api.HandleFunc("/groups/{id}/target", groupHandler.SetTarget).Methods("PATCH")
+	api.HandleFunc("/groups/{id}/duplicate", groupHandler.Duplicate).Methods("POST")
 	api.HandleFunc("/groups/{id}/account-positions", groupHandler.UpdateAccountPositionsInGroup).Methods("PATCH")
 	api.HandleFunc("/groups/{id}/history", groupHandler.GetHistory).Methods("GET")
+	api.HandleFunc("/groups/{id}/movers", groupHandler.GetMovers).Methods("GET")
 
 	// Institution routes - /all must come before /{id} routes
 	api.HandleFunc("/institutions/all", institutionHandler.GetAllIncludingArchived).Methods("GET")
+	api.HandleFunc("/institutions/accounts-map", institutionHandler.GetAccountsMap).Methods("GET")
 	api.HandleFunc("/institutions", institutionHandler.GetAll).Methods("GET")
 	api.HandleFunc("/institutions", institutionHandler.Create).Methods("POST")
 	api.HandleFunc("/institutions/{id}/unarchive", institutionHandler.Unarchive).Methods("PATCH")
@@ -81,19 +111,27 @@ func New(db *sql.DB) *mux.Router {
 	api.HandleFunc("/institutions/{id}/account-positions", institutionHandler.UpdateAccountPositionsInInstitution).Methods("PATCH")
 	api.HandleFunc("/institutions/{id}/history", institutionHandler.GetHistory).Methods("GET")
 
+	// Net worth routes
+	api.HandleFunc("/net-worth/history", accountHandler.GetNetWorthHistory).Methods("GET")
+	api.HandleFunc("/total/excluding", accountHandler.GetTotalExcluding).Methods("GET")
 
 	// Dashboard routes
 	api.HandleFunc("/dashboards", dashboardHandler.GetAll).Methods("GET")
 	api.HandleFunc("/dashboards", dashboardHandler.Create).Methods("POST")
 	api.HandleFunc("/dashboards/main", dashboardHandler.GetMain).Methods("GET")
+	api.HandleFunc("/dashboards/positions", dashboardHandler.UpdatePositions).Methods("PATCH")
 	api.HandleFunc("/dashboards/{id}", dashboardHandler.GetByID).Methods("GET")
 	api.HandleFunc("/dashboards/{id}", dashboardHandler.Update).Methods("PATCH")
 	api.HandleFunc("/dashboards/{id}", dashboardHandler.Delete).Methods("DELETE")
 	api.HandleFunc("/dashboards/{id}/main", dashboardHandler.SetMain).Methods("PATCH")
+	api.HandleFunc("/dashboards/{id}/clone", dashboardHandler.Clone).Methods("POST")
 	api.HandleFunc("/dashboards/{id}/item-positions", dashboardHandler.UpdateItemPositions).Methods("PATCH")
 	api.HandleFunc("/dashboards/{id}/history", dashboardHandler.GetHistory).Methods("GET")
+	api.HandleFunc("/dashboards/{id}/compare", dashboardHandler.Compare).Methods("GET")
 
-	// Dataset routes
+	// Dataset routes - /sync-overview must come before /{id} routes
+	api.HandleFunc("/datasets/sync-overview", datasetHandler.GetSyncOverview).Methods("GET")
+	api.HandleFunc("/datasets/failures", datasetHandler.GetFailures).Methods("GET")
 	api.HandleFunc("/datasets", datasetHandler.GetAll).Methods("GET")
 	api.HandleFunc("/datasets", datasetHandler.Create).Methods("POST")
 	api.HandleFunc("/datasets/{id}", datasetHandler.GetByID).Methods("GET")
@@ -102,10 +140,66 @@ func New(db *sql.DB) *mux.Router {
 	api.HandleFunc("/datasets/{id}/export", datasetHandler.Export).Methods("GET")
 	api.HandleFunc("/datasets/{id}/sync", datasetHandler.Sync).Methods("POST")
 	api.HandleFunc("/datasets/{id}/sync-status", datasetHandler.GetSyncStatus).Methods("GET")
+	api.HandleFunc("/datasets/{id}/auto-sync", datasetHandler.UpdateAutoSync).Methods("PATCH")
+	api.HandleFunc("/datasets/{id}/combine-mode", datasetHandler.UpdateCombineMode).Methods("PATCH")
+	api.HandleFunc("/datasets/{id}/skip-rows", datasetHandler.UpdateSkipRows).Methods("PATCH")
+	api.HandleFunc("/datasets/{id}/delimiter", datasetHandler.UpdateDelimiter).Methods("PATCH")
+	api.HandleFunc("/datasets/{id}/dedupe", datasetHandler.UpdateDedupe).Methods("PATCH")
+	api.HandleFunc("/datasets/{id}/derived-columns", datasetHandler.UpdateDerivedColumns).Methods("PATCH")
+	api.HandleFunc("/datasets/{id}/transformations", datasetHandler.GetTransformations).Methods("GET")
+	api.HandleFunc("/datasets/{id}/transformations", datasetHandler.CreateTransformation).Methods("POST")
+	api.HandleFunc("/datasets/{id}/transformations/positions", datasetHandler.UpdateTransformationPositions).Methods("PATCH")
+	api.HandleFunc("/datasets/{id}/transformations/{stepId}", datasetHandler.DeleteTransformation).Methods("DELETE")
+	api.HandleFunc("/datasets/{id}/recount", datasetHandler.Recount).Methods("POST")
+	api.HandleFunc("/datasets/{id}/aggregation-options", datasetHandler.GetAggregationOptions).Methods("GET")
+	api.HandleFunc("/datasets/{id}/date-range", datasetHandler.GetDateRange).Methods("GET")
+	api.HandleFunc("/datasets/{id}/stats", datasetHandler.GetStats).Methods("GET")
+	api.HandleFunc("/datasets/{id}/columns", datasetHandler.UpdateColumnSettings).Methods("PATCH")
+	api.HandleFunc("/datasets/{id}/columns/{column}/description", datasetHandler.UpdateColumnDescription).Methods("PATCH")
+
+	// Allocation routes
+	api.HandleFunc("/allocation/drift", groupHandler.GetAllocationDrift).Methods("GET")
+
+	// Report routes
+	api.HandleFunc("/list/export", reportHandler.ExportList).Methods("GET")
+	api.HandleFunc("/reports/period", reportHandler.GetPeriodReport).Methods("GET")
+
+	// Maintenance routes
+	api.HandleFunc("/maintenance/downsample-history", maintenanceHandler.DownsampleHistory).Methods("POST")
+	api.HandleFunc("/maintenance/history-stats", maintenanceHandler.HistoryStats).Methods("GET")
+	api.HandleFunc("/maintenance/position-health", maintenanceHandler.PositionHealth).Methods("GET")
+	api.HandleFunc("/maintenance/fix-positions", maintenanceHandler.FixPositions).Methods("POST")
 
 	return r
 }
 
+// maxDatasetQueryTimeout caps DATASET_QUERY_TIMEOUT_SECONDS so runaway queries stay bounded
+const maxDatasetQueryTimeout = 10 * time.Minute
+
+// datasetQueryTimeout reads DATASET_QUERY_TIMEOUT_SECONDS, falling back to the storage
+// default when unset or invalid
+func datasetQueryTimeout() time.Duration {
+	seconds, err := strconv.Atoi(os.Getenv("DATASET_QUERY_TIMEOUT_SECONDS"))
+	if err != nil || seconds <= 0 {
+		return storage.DefaultQueryTimeout
+	}
+	timeout := time.Duration(seconds) * time.Second
+	if timeout > maxDatasetQueryTimeout {
+		return maxDatasetQueryTimeout
+	}
+	return timeout
+}
+
+// datasetMaxRows reads DATASET_MAX_ROWS, falling back to the sync service default
+// when unset or invalid
+func datasetMaxRows() int {
+	maxRows, err := strconv.Atoi(os.Getenv("DATASET_MAX_ROWS"))
+	if err != nil || maxRows <= 0 {
+		return service.DefaultMaxDatasetRows
+	}
+	return maxRows
+}
+
 func WithCORS(r *mux.Router) *cors.Cors {
 	allowedOrigins := []string{"http://localhost:5173", "http://localhost:3000"}
 
@@ -124,7 +218,7 @@ func WithCORS(r *mux.Router) *cors.Cors {
 	return cors.New(cors.Options{
 		AllowedOrigins:   allowedOrigins,
 		AllowedMethods:   []string{"GET", "POST", "PATCH", "DELETE", "OPTIONS"},
-		AllowedHeaders:   []string{"Content-Type", "Authorization"},
+		AllowedHeaders:   []string{"Content-Type", "Authorization", responseCasingHeader},
 		AllowCredentials: true,
 	})
 }
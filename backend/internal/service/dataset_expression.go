@@ -0,0 +1,291 @@
+package service
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"finance-tracker/internal/datasource"
+	"finance-tracker/internal/models"
+)
+
+// expression is a parsed derived column expression. It supports numbers, column
+// references, + - * /, unary minus and parentheses. Columns are referenced by bare
+// name (letters, digits and underscores) or in double quotes, e.g. "Unit Price";
+// a double quote inside a quoted name is written twice.
+type expression interface {
+	eval(row []any, columns map[string]int) (float64, bool)
+}
+
+type numberExpr float64
+
+type columnExpr string
+
+type negateExpr struct {
+	operand expression
+}
+
+type binaryExpr struct {
+	op          byte
+	left, right expression
+}
+
+func (e numberExpr) eval(row []any, columns map[string]int) (float64, bool) {
+	return float64(e), true
+}
+
+func (e columnExpr) eval(row []any, columns map[string]int) (float64, bool) {
+	value, err := strconv.ParseFloat(strings.TrimSpace(cellString(row[columns[string(e)]])), 64)
+	return value, err == nil
+}
+
+func (e negateExpr) eval(row []any, columns map[string]int) (float64, bool) {
+	value, ok := e.operand.eval(row, columns)
+	return -value, ok
+}
+
+func (e binaryExpr) eval(row []any, columns map[string]int) (float64, bool) {
+	left, ok := e.left.eval(row, columns)
+	if !ok {
+		return 0, false
+	}
+	right, ok := e.right.eval(row, columns)
+	if !ok {
+		return 0, false
+	}
+	switch e.op {
+	case '+':
+		return left + right, true
+	case '-':
+		return left - right, true
+	case '*':
+		return left * right, true
+	default:
+		if right == 0 {
+			return 0, false
+		}
+		return left / right, true
+	}
+}
+
+// parseExpression parses expr and returns it with the columns it references
+func parseExpression(expr string) (expression, []string, error) {
+	p := &expressionParser{input: expr}
+	if err := p.next(); err != nil {
+		return nil, nil, err
+	}
+	if p.kind == tokenEOF {
+		return nil, nil, fmt.Errorf("expression is empty")
+	}
+	e, err := p.parseSum()
+	if err != nil {
+		return nil, nil, err
+	}
+	if p.kind != tokenEOF {
+		return nil, nil, fmt.Errorf("unexpected %q at position %d", p.text, p.start+1)
+	}
+	return e, p.columns, nil
+}
+
+const (
+	tokenEOF = iota
+	tokenNumber
+	tokenColumn
+	tokenOperator
+)
+
+// expressionParser is a recursive descent parser holding the current token
+type expressionParser struct {
+	input   string
+	pos     int
+	kind    int
+	text    string
+	start   int
+	columns []string
+}
+
+// next reads the following token into kind and text
+func (p *expressionParser) next() error {
+	for p.pos < len(p.input) && unicode.IsSpace(rune(p.input[p.pos])) {
+		p.pos++
+	}
+	p.start = p.pos
+	if p.pos >= len(p.input) {
+		p.kind, p.text = tokenEOF, ""
+		return nil
+	}
+
+	c := p.input[p.pos]
+	switch {
+	case strings.IndexByte("+-*/()", c) >= 0:
+		p.pos++
+		p.kind, p.text = tokenOperator, string(c)
+	case c >= '0' && c <= '9' || c == '.':
+		for p.pos < len(p.input) && (p.input[p.pos] >= '0' && p.input[p.pos] <= '9' || p.input[p.pos] == '.') {
+			p.pos++
+		}
+		p.kind, p.text = tokenNumber, p.input[p.start:p.pos]
+	case c == '"':
+		var name strings.Builder
+		p.pos++
+		for {
+			if p.pos >= len(p.input) {
+				return fmt.Errorf("unterminated column name at position %d", p.start+1)
+			}
+			if p.input[p.pos] == '"' {
+				if p.pos+1 < len(p.input) && p.input[p.pos+1] == '"' {
+					name.WriteByte('"')
+					p.pos += 2
+					continue
+				}
+				p.pos++
+				break
+			}
+			name.WriteByte(p.input[p.pos])
+			p.pos++
+		}
+		p.kind, p.text = tokenColumn, name.String()
+	case c == '_' || unicode.IsLetter(rune(c)):
+		for p.pos < len(p.input) && (p.input[p.pos] == '_' || unicode.IsLetter(rune(p.input[p.pos])) || unicode.IsDigit(rune(p.input[p.pos]))) {
+			p.pos++
+		}
+		p.kind, p.text = tokenColumn, p.input[p.start:p.pos]
+	default:
+		return fmt.Errorf("unexpected %q at position %d", string(c), p.start+1)
+	}
+	return nil
+}
+
+// parseSum parses terms joined by + and -
+func (p *expressionParser) parseSum() (expression, error) {
+	left, err := p.parseProduct()
+	if err != nil {
+		return nil, err
+	}
+	for p.kind == tokenOperator && (p.text == "+" || p.text == "-") {
+		op := p.text[0]
+		if err := p.next(); err != nil {
+			return nil, err
+		}
+		right, err := p.parseProduct()
+		if err != nil {
+			return nil, err
+		}
+		left = binaryExpr{op: op, left: left, right: right}
+	}
+	return left, nil
+}
+
+// parseProduct parses factors joined by * and /
+func (p *expressionParser) parseProduct() (expression, error) {
+	left, err := p.parseFactor()
+	if err != nil {
+		return nil, err
+	}
+	for p.kind == tokenOperator && (p.text == "*" || p.text == "/") {
+		op := p.text[0]
+		if err := p.next(); err != nil {
+			return nil, err
+		}
+		right, err := p.parseFactor()
+		if err != nil {
+			return nil, err
+		}
+		left = binaryExpr{op: op, left: left, right: right}
+	}
+	return left, nil
+}
+
+// parseFactor parses a number, column, negation or parenthesized expression
+func (p *expressionParser) parseFactor() (expression, error) {
+	kind, text, start := p.kind, p.text, p.start
+	switch {
+	case kind == tokenNumber:
+		value, err := strconv.ParseFloat(text, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid number %q at position %d", text, start+1)
+		}
+		return numberExpr(value), p.next()
+	case kind == tokenColumn:
+		p.columns = append(p.columns, text)
+		return columnExpr(text), p.next()
+	case kind == tokenOperator && text == "-":
+		if err := p.next(); err != nil {
+			return nil, err
+		}
+		operand, err := p.parseFactor()
+		if err != nil {
+			return nil, err
+		}
+		return negateExpr{operand: operand}, nil
+	case kind == tokenOperator && text == "(":
+		if err := p.next(); err != nil {
+			return nil, err
+		}
+		inner, err := p.parseSum()
+		if err != nil {
+			return nil, err
+		}
+		if p.kind != tokenOperator || p.text != ")" {
+			return nil, fmt.Errorf("missing closing parenthesis for position %d", start+1)
+		}
+		return inner, p.next()
+	case kind == tokenEOF:
+		return nil, fmt.Errorf("expression ends unexpectedly")
+	default:
+		return nil, fmt.Errorf("unexpected %q at position %d", text, start+1)
+	}
+}
+
+// ValidateDerivedColumns checks each derived column against the columns before it,
+// starting from the pipeline's output, and returns the columns with the derived ones
+// appended
+func ValidateDerivedColumns(columns []string, derived []models.DerivedColumn) ([]string, error) {
+	current := append([]string{}, columns...)
+	for _, dc := range derived {
+		if strings.TrimSpace(dc.Name) == "" {
+			return nil, fmt.Errorf("derived column name is required")
+		}
+		if columnIndex(current, dc.Name) >= 0 {
+			return nil, fmt.Errorf("column %q already exists", dc.Name)
+		}
+		_, refs, err := parseExpression(dc.Expression)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", dc.Name, err)
+		}
+		for _, ref := range refs {
+			if columnIndex(current, ref) < 0 {
+				return nil, fmt.Errorf("%s: unknown column %q", dc.Name, ref)
+			}
+		}
+		current = append(current, dc.Name)
+	}
+	return current, nil
+}
+
+// ApplyDerivedColumns appends each derived column to the folder data in order, so
+// later expressions can use earlier derived columns. Rows where a referenced cell is
+// not numeric, or that divide by zero, get an empty cell.
+func ApplyDerivedColumns(data *datasource.FolderData, derived []models.DerivedColumn) error {
+	if _, err := ValidateDerivedColumns(data.Columns, derived); err != nil {
+		return err
+	}
+
+	for _, dc := range derived {
+		expr, _, _ := parseExpression(dc.Expression)
+		columns := make(map[string]int, len(data.Columns))
+		for i, col := range data.Columns {
+			columns[col] = i
+		}
+		for i, row := range data.Rows {
+			cell := ""
+			if value, ok := expr.eval(row, columns); ok {
+				cell = strconv.FormatFloat(value, 'f', -1, 64)
+			}
+			data.Rows[i] = append(row, cell)
+		}
+		data.Columns = append(append([]string{}, data.Columns...), dc.Name)
+	}
+	return nil
+}
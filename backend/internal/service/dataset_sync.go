@@ -2,15 +2,22 @@ package service
 
 import (
 	"database/sql"
+	"errors"
 	"fmt"
 	"sync"
 	"time"
+	"unicode/utf8"
 
 	"finance-tracker/internal/datasource"
 	"finance-tracker/internal/git"
+	"finance-tracker/internal/models"
 	"finance-tracker/internal/storage"
 )
 
+// ErrAlreadySyncing is returned when a sync is requested for a dataset that is
+// already being synced
+var ErrAlreadySyncing = errors.New("dataset is already being synced")
+
 // DatasetInfo contains the dataset information needed for sync operations
 type DatasetInfo struct {
 	ID             int
@@ -19,6 +26,27 @@ type DatasetInfo struct {
 	FolderPath     string
 	LastCommitHash sql.NullString
 	Status         string
+
+	// AutoSync enables background syncs triggered by reads
+	AutoSync bool
+	// AutoSyncInterval is the minimum time between change checks triggered by reads
+	AutoSyncInterval time.Duration
+
+	// CombineMode is datasource.CombineAppend or datasource.CombineJoin
+	CombineMode string
+	// JoinKey is the column files are merged on in join mode
+	JoinKey string
+	// SkipRows is how many records precede each file's header; nil detects title lines
+	SkipRows *int
+	// Delimiter is the field delimiter of every file; nil detects it
+	Delimiter *string
+
+	// Dedupe drops rows duplicating an earlier row on DedupeKeys (every column if empty)
+	Dedupe     bool
+	DedupeKeys []string
+
+	// DerivedColumns are computed after the transformation pipeline
+	DerivedColumns []models.DerivedColumn
 }
 
 // DatasetSyncService handles syncing datasets from their source folders
@@ -31,16 +59,29 @@ type DatasetSyncService struct {
 	// Track datasets currently being synced to prevent concurrent syncs
 	syncingMu sync.Mutex
 	syncing   map[int]bool
+
+	// Settings to sync a dataset with again once its running sync finishes, for
+	// settings that changed after that sync read them
+	resyncs map[int]*DatasetInfo
+
+	// Track when each dataset was last checked for changes by a read
+	lastChecked map[int]time.Time
 }
 
-// NewDatasetSyncService creates a new sync service
-func NewDatasetSyncService(db *sql.DB, storage storage.DatasetStorage) *DatasetSyncService {
+// DefaultMaxDatasetRows is the default limit on rows synced into a single dataset
+const DefaultMaxDatasetRows = 5000000
+
+// NewDatasetSyncService creates a new sync service. Syncs fail, leaving the
+// dataset in error status, if the folder holds more than maxRows data rows.
+func NewDatasetSyncService(db *sql.DB, storage storage.DatasetStorage, maxRows int) *DatasetSyncService {
 	return &DatasetSyncService{
 		gitManager:   git.NewManager(),
-		folderReader: datasource.NewFolderReader(),
+		folderReader: datasource.NewFolderReader(maxRows),
 		storage:      storage,
 		db:           db,
 		syncing:      make(map[int]bool),
+		resyncs:      make(map[int]*DatasetInfo),
+		lastChecked:  make(map[int]time.Time),
 	}
 }
 
@@ -74,21 +115,78 @@ func (s *DatasetSyncService) NeedsSync(dataset *DatasetInfo) (bool, error) {
 // SyncDataset syncs the dataset from its source folder
 // Returns the new commit hash and row count, or an error
 func (s *DatasetSyncService) SyncDataset(dataset *DatasetInfo) (commitHash string, rowCount int, err error) {
-	// Mark as syncing
+	if !s.startSync(dataset.ID) {
+		return "", 0, ErrAlreadySyncing
+	}
+	defer s.finishSync(dataset.ID)
+
+	return s.runSync(dataset)
+}
+
+// SyncInBackground starts a sync in a goroutine and returns immediately. The dataset
+// is marked as syncing before returning, so a concurrent sync request is rejected
+// with ErrAlreadySyncing. Failures are recorded in the dataset's status.
+func (s *DatasetSyncService) SyncInBackground(dataset *DatasetInfo) error {
+	if !s.startSync(dataset.ID) {
+		return ErrAlreadySyncing
+	}
+
+	go func() {
+		defer s.finishSync(dataset.ID)
+		s.runSync(dataset)
+	}()
+	return nil
+}
+
+// Resync syncs a dataset after its settings change. A sync that is already running
+// read the old settings, so the dataset is instead synced again in the background
+// once that sync finishes. Failures are recorded in the dataset's status.
+func (s *DatasetSyncService) Resync(dataset *DatasetInfo) {
 	s.syncingMu.Lock()
 	if s.syncing[dataset.ID] {
+		s.resyncs[dataset.ID] = dataset
 		s.syncingMu.Unlock()
-		return "", 0, fmt.Errorf("dataset is already being synced")
+		return
 	}
 	s.syncing[dataset.ID] = true
 	s.syncingMu.Unlock()
 
-	defer func() {
-		s.syncingMu.Lock()
-		delete(s.syncing, dataset.ID)
-		s.syncingMu.Unlock()
-	}()
+	defer s.finishSync(dataset.ID)
+	s.runSync(dataset)
+}
+
+// startSync marks a dataset as syncing. Returns false if it already was.
+func (s *DatasetSyncService) startSync(datasetID int) bool {
+	s.syncingMu.Lock()
+	defer s.syncingMu.Unlock()
+	if s.syncing[datasetID] {
+		return false
+	}
+	s.syncing[datasetID] = true
+	return true
+}
+
+// finishSync clears a dataset's syncing mark, or keeps it to run a resync queued
+// by Resync during the sync
+func (s *DatasetSyncService) finishSync(datasetID int) {
+	s.syncingMu.Lock()
+	next := s.resyncs[datasetID]
+	delete(s.resyncs, datasetID)
+	if next == nil {
+		delete(s.syncing, datasetID)
+	}
+	s.syncingMu.Unlock()
+
+	if next != nil {
+		go func() {
+			defer s.finishSync(datasetID)
+			s.runSync(next)
+		}()
+	}
+}
 
+// runSync does the work of a sync; the caller must hold the dataset's syncing mark
+func (s *DatasetSyncService) runSync(dataset *DatasetInfo) (commitHash string, rowCount int, err error) {
 	// Update status to syncing
 	if err := s.updateStatus(dataset.ID, "syncing", ""); err != nil {
 		return "", 0, fmt.Errorf("failed to update status: %w", err)
@@ -102,12 +200,44 @@ func (s *DatasetSyncService) SyncDataset(dataset *DatasetInfo) (commitHash strin
 	}
 
 	// Read all CSV data from folder
-	folderData, err := s.folderReader.ReadFolder(dataset.FolderPath)
+	var folderData *datasource.FolderData
+	if dataset.CombineMode == datasource.CombineJoin {
+		folderData, err = s.folderReader.JoinFolder(dataset.FolderPath, dataset.JoinKey, dataset.readOptions())
+	} else {
+		folderData, err = s.folderReader.ReadFolder(dataset.FolderPath, dataset.readOptions())
+	}
 	if err != nil {
 		s.updateStatus(dataset.ID, "error", fmt.Sprintf("Failed to read folder: %v", err))
 		return "", 0, fmt.Errorf("failed to read folder data: %w", err)
 	}
 
+	// Drop duplicate source rows, e.g. where monthly exports overlap
+	duplicatesDropped := 0
+	if dataset.Dedupe {
+		for _, key := range dataset.DedupeKeys {
+			if columnIndex(folderData.Columns, key) < 0 {
+				s.updateStatus(dataset.ID, "error", fmt.Sprintf("Dedupe key column %q is missing from the folder data", key))
+				return "", 0, fmt.Errorf("dedupe key column %q not found", key)
+			}
+		}
+		duplicatesDropped = dedupeRows(folderData, dataset.DedupeKeys)
+	}
+
+	// Replay the dataset's transformation pipeline
+	steps, err := s.LoadTransformations(dataset.ID)
+	if err != nil {
+		s.updateStatus(dataset.ID, "error", fmt.Sprintf("Failed to load transformations: %v", err))
+		return "", 0, err
+	}
+	if err := ApplyTransformations(folderData, steps); err != nil {
+		s.updateStatus(dataset.ID, "error", fmt.Sprintf("Failed to apply transformations: %v", err))
+		return "", 0, fmt.Errorf("failed to apply transformations: %w", err)
+	}
+	if err := ApplyDerivedColumns(folderData, dataset.DerivedColumns); err != nil {
+		s.updateStatus(dataset.ID, "error", fmt.Sprintf("Failed to compute derived columns: %v", err))
+		return "", 0, fmt.Errorf("failed to compute derived columns: %w", err)
+	}
+
 	// Store data using the storage interface
 	if err := s.storage.StoreData(dataset.ID, dataset.TableName, folderData.Columns, folderData.Rows); err != nil {
 		s.updateStatus(dataset.ID, "error", fmt.Sprintf("Failed to store data: %v", err))
@@ -117,7 +247,7 @@ func (s *DatasetSyncService) SyncDataset(dataset *DatasetInfo) (commitHash strin
 	rowCount = len(folderData.Rows)
 
 	// Update dataset with new sync info
-	if err := s.updateSyncInfo(dataset.ID, commitHash, rowCount); err != nil {
+	if err := s.updateSyncInfo(dataset.ID, commitHash, rowCount, duplicatesDropped); err != nil {
 		return "", 0, fmt.Errorf("failed to update sync info: %w", err)
 	}
 
@@ -144,6 +274,48 @@ func (s *DatasetSyncService) SyncIfNeeded(dataset *DatasetInfo) (synced bool, er
 	return true, nil
 }
 
+// SyncInBackgroundIfNeeded starts a background sync if the dataset's folder has changed.
+// The folder is checked at most once per minInterval so frequent reads stay fast.
+// Returns true if a sync is running when the call returns.
+func (s *DatasetSyncService) SyncInBackgroundIfNeeded(dataset *DatasetInfo, minInterval time.Duration) bool {
+	s.syncingMu.Lock()
+	if s.syncing[dataset.ID] {
+		s.syncingMu.Unlock()
+		return true
+	}
+	if last, ok := s.lastChecked[dataset.ID]; ok && time.Since(last) < minInterval {
+		s.syncingMu.Unlock()
+		return false
+	}
+	s.lastChecked[dataset.ID] = time.Now()
+	s.syncingMu.Unlock()
+
+	needsSync, err := s.NeedsSync(dataset)
+	if err != nil || !needsSync {
+		return false
+	}
+
+	go s.SyncDataset(dataset)
+	return true
+}
+
+// SourceColumns returns the columns a dataset's folder yields before transformations
+func (s *DatasetSyncService) SourceColumns(dataset *DatasetInfo) ([]string, error) {
+	return s.folderReader.ReadColumns(dataset.FolderPath, dataset.CombineMode, dataset.readOptions())
+}
+
+// readOptions is how the folder reader parses the dataset's files
+func (d *DatasetInfo) readOptions() datasource.ReadOptions {
+	opts := datasource.DetectOptions
+	if d.SkipRows != nil {
+		opts.SkipRows = *d.SkipRows
+	}
+	if d.Delimiter != nil {
+		opts.Delimiter, _ = utf8.DecodeRuneInString(*d.Delimiter)
+	}
+	return opts
+}
+
 // InitializeDataset initializes git tracking for a new dataset
 // Returns the initial commit hash
 func (s *DatasetSyncService) InitializeDataset(folderPath string) (string, error) {
@@ -164,7 +336,13 @@ func (s *DatasetSyncService) InitializeDataset(folderPath string) (string, error
 // updateStatus updates the dataset status in the database
 func (s *DatasetSyncService) updateStatus(datasetID int, status, errorMessage string) error {
 	var err error
-	if errorMessage != "" {
+	if status == "error" {
+		_, err = s.db.Exec(`
+			UPDATE datasets
+			SET status = $1, error_message = $2, last_error_at = NOW(), updated_at = NOW()
+			WHERE id = $3
+		`, status, errorMessage, datasetID)
+	} else if errorMessage != "" {
 		_, err = s.db.Exec(`
 			UPDATE datasets
 			SET status = $1, error_message = $2, updated_at = NOW()
@@ -181,7 +359,7 @@ func (s *DatasetSyncService) updateStatus(datasetID int, status, errorMessage st
 }
 
 // updateSyncInfo updates the dataset with sync results
-func (s *DatasetSyncService) updateSyncInfo(datasetID int, commitHash string, rowCount int) error {
+func (s *DatasetSyncService) updateSyncInfo(datasetID int, commitHash string, rowCount, duplicatesDropped int) error {
 	_, err := s.db.Exec(`
 		UPDATE datasets
 		SET status = 'ready',
@@ -189,8 +367,9 @@ func (s *DatasetSyncService) updateSyncInfo(datasetID int, commitHash string, ro
 		    last_commit_hash = $1,
 		    last_synced_at = NOW(),
 		    row_count = $2,
+		    duplicates_dropped = $3,
 		    updated_at = NOW()
-		WHERE id = $3
-	`, commitHash, rowCount, datasetID)
+		WHERE id = $4
+	`, commitHash, rowCount, duplicatesDropped, datasetID)
 	return err
 }
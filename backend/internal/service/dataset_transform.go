@@ -0,0 +1,287 @@
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"finance-tracker/internal/datasource"
+	"finance-tracker/internal/models"
+)
+
+// Compute operators for TransformCompute steps
+const (
+	ComputeAdd      = "add"
+	ComputeSubtract = "subtract"
+	ComputeMultiply = "multiply"
+	ComputeDivide   = "divide"
+	ComputeConcat   = "concat"
+)
+
+// LoadTransformations returns a dataset's pipeline in position order
+func (s *DatasetSyncService) LoadTransformations(datasetID int) ([]models.DatasetTransformation, error) {
+	rows, err := s.db.Query(`
+		SELECT id, dataset_id, position, operation, config, created_at
+		FROM dataset_transformations
+		WHERE dataset_id = $1
+		ORDER BY position ASC, id ASC
+	`, datasetID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query transformations: %w", err)
+	}
+	defer rows.Close()
+
+	steps := []models.DatasetTransformation{}
+	for rows.Next() {
+		var t models.DatasetTransformation
+		var configJSON []byte
+		if err := rows.Scan(&t.ID, &t.DatasetID, &t.Position, &t.Operation, &configJSON, &t.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan transformation: %w", err)
+		}
+		var config models.CreateTransformationRequest
+		if err := json.Unmarshal(configJSON, &config); err != nil {
+			return nil, fmt.Errorf("failed to parse transformation %d: %w", t.ID, err)
+		}
+		t.Column, t.To, t.Op, t.Value = config.Column, config.To, config.Op, config.Value
+		t.Left, t.Right, t.Columns = config.Left, config.Right, config.Columns
+		steps = append(steps, t)
+	}
+	return steps, rows.Err()
+}
+
+// ValidateTransformations checks each step against the columns it will see, starting
+// from the source columns, and returns the columns the pipeline produces
+func ValidateTransformations(columns []string, steps []models.DatasetTransformation) ([]string, error) {
+	current := append([]string{}, columns...)
+	for i, step := range steps {
+		next, err := validateStep(current, step)
+		if err != nil {
+			return nil, fmt.Errorf("step %d (%s): %w", i+1, step.Operation, err)
+		}
+		current = next
+	}
+	return current, nil
+}
+
+// validateStep checks one step and returns the columns after it
+func validateStep(columns []string, step models.DatasetTransformation) ([]string, error) {
+	has := func(col string) bool {
+		return columnIndex(columns, col) >= 0
+	}
+
+	switch step.Operation {
+	case models.TransformRename:
+		if !has(step.Column) {
+			return nil, fmt.Errorf("unknown column %q", step.Column)
+		}
+		if step.To == "" {
+			return nil, fmt.Errorf("new column name is required")
+		}
+		if step.To != step.Column && has(step.To) {
+			return nil, fmt.Errorf("column %q already exists", step.To)
+		}
+		renamed := append([]string{}, columns...)
+		renamed[columnIndex(columns, step.Column)] = step.To
+		return renamed, nil
+
+	case models.TransformCompute:
+		if step.Column == "" {
+			return nil, fmt.Errorf("new column name is required")
+		}
+		if has(step.Column) {
+			return nil, fmt.Errorf("column %q already exists", step.Column)
+		}
+		if !has(step.Left) {
+			return nil, fmt.Errorf("unknown column %q", step.Left)
+		}
+		if !has(step.Right) {
+			return nil, fmt.Errorf("unknown column %q", step.Right)
+		}
+		switch step.Op {
+		case ComputeAdd, ComputeSubtract, ComputeMultiply, ComputeDivide, ComputeConcat:
+		default:
+			return nil, fmt.Errorf("unknown compute operator %q", step.Op)
+		}
+		return append(append([]string{}, columns...), step.Column), nil
+
+	case models.TransformFilter:
+		if !has(step.Column) {
+			return nil, fmt.Errorf("unknown column %q", step.Column)
+		}
+		switch step.Op {
+		case models.FilterEq, models.FilterNeq, models.FilterContains,
+			models.FilterGt, models.FilterGte, models.FilterLt, models.FilterLte:
+		default:
+			return nil, fmt.Errorf("unknown filter operator %q", step.Op)
+		}
+		return columns, nil
+
+	case models.TransformDedup:
+		for _, col := range step.Columns {
+			if !has(col) {
+				return nil, fmt.Errorf("unknown column %q", col)
+			}
+		}
+		return columns, nil
+
+	default:
+		return nil, fmt.Errorf("unknown operation %q", step.Operation)
+	}
+}
+
+// ApplyTransformations runs the pipeline over the folder data in place. The steps are
+// revalidated first since the source columns may have changed since they were saved.
+func ApplyTransformations(data *datasource.FolderData, steps []models.DatasetTransformation) error {
+	if _, err := ValidateTransformations(data.Columns, steps); err != nil {
+		return err
+	}
+
+	for _, step := range steps {
+		switch step.Operation {
+		case models.TransformRename:
+			columns := append([]string{}, data.Columns...)
+			columns[columnIndex(columns, step.Column)] = step.To
+			data.Columns = columns
+
+		case models.TransformCompute:
+			left := columnIndex(data.Columns, step.Left)
+			right := columnIndex(data.Columns, step.Right)
+			for i, row := range data.Rows {
+				data.Rows[i] = append(row, compute(step.Op, cellString(row[left]), cellString(row[right])))
+			}
+			data.Columns = append(append([]string{}, data.Columns...), step.Column)
+
+		case models.TransformFilter:
+			col := columnIndex(data.Columns, step.Column)
+			kept := data.Rows[:0]
+			for _, row := range data.Rows {
+				if matchesFilter(step.Op, cellString(row[col]), step.Value) {
+					kept = append(kept, row)
+				}
+			}
+			data.Rows = kept
+
+		case models.TransformDedup:
+			dedupeRows(data, step.Columns)
+		}
+	}
+	return nil
+}
+
+// dedupeRows drops rows whose values in keyColumns (every column if empty) repeat an
+// earlier row's, keeping the first. keyColumns must exist. Returns the number dropped.
+func dedupeRows(data *datasource.FolderData, keyColumns []string) int {
+	var indexes []int
+	for _, col := range keyColumns {
+		indexes = append(indexes, columnIndex(data.Columns, col))
+	}
+	if len(indexes) == 0 {
+		for i := range data.Columns {
+			indexes = append(indexes, i)
+		}
+	}
+
+	seen := make(map[string]bool)
+	kept := data.Rows[:0]
+	for _, row := range data.Rows {
+		var key strings.Builder
+		for _, i := range indexes {
+			key.WriteString(strconv.Quote(cellString(row[i])))
+		}
+		if !seen[key.String()] {
+			seen[key.String()] = true
+			kept = append(kept, row)
+		}
+	}
+	dropped := len(data.Rows) - len(kept)
+	data.Rows = kept
+	return dropped
+}
+
+// compute applies a compute operator to two cells. Arithmetic on a non-numeric
+// cell, or division by zero, yields an empty cell.
+func compute(op, left, right string) string {
+	if op == ComputeConcat {
+		return left + right
+	}
+
+	l, lErr := strconv.ParseFloat(strings.TrimSpace(left), 64)
+	r, rErr := strconv.ParseFloat(strings.TrimSpace(right), 64)
+	if lErr != nil || rErr != nil {
+		return ""
+	}
+
+	var result float64
+	switch op {
+	case ComputeAdd:
+		result = l + r
+	case ComputeSubtract:
+		result = l - r
+	case ComputeMultiply:
+		result = l * r
+	case ComputeDivide:
+		if r == 0 {
+			return ""
+		}
+		result = l / r
+	}
+	return strconv.FormatFloat(result, 'f', -1, 64)
+}
+
+// matchesFilter mirrors the dataset data filter: ordering comparisons are numeric
+// when the value is a number (non-numeric cells never match) and textual otherwise
+func matchesFilter(op, cell, value string) bool {
+	switch op {
+	case models.FilterEq:
+		return cell == value
+	case models.FilterNeq:
+		return cell != value
+	case models.FilterContains:
+		return strings.Contains(strings.ToLower(cell), strings.ToLower(value))
+	}
+
+	var cmp int
+	if number, err := strconv.ParseFloat(strings.TrimSpace(value), 64); err == nil {
+		cellNumber, err := strconv.ParseFloat(strings.TrimSpace(cell), 64)
+		if err != nil {
+			return false
+		}
+		switch {
+		case cellNumber < number:
+			cmp = -1
+		case cellNumber > number:
+			cmp = 1
+		}
+	} else {
+		cmp = strings.Compare(cell, value)
+	}
+
+	switch op {
+	case models.FilterGt:
+		return cmp > 0
+	case models.FilterGte:
+		return cmp >= 0
+	case models.FilterLt:
+		return cmp < 0
+	case models.FilterLte:
+		return cmp <= 0
+	}
+	return false
+}
+
+func columnIndex(columns []string, column string) int {
+	for i, col := range columns {
+		if col == column {
+			return i
+		}
+	}
+	return -1
+}
+
+func cellString(v any) string {
+	if v == nil {
+		return ""
+	}
+	return fmt.Sprintf("%v", v)
+}
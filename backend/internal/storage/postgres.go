@@ -1,20 +1,53 @@
 package storage
 
 import (
+	"context"
 	"database/sql"
+	"errors"
 	"fmt"
+	"strconv"
 	"strings"
+	"time"
 	"unicode"
+	"unicode/utf8"
 )
 
+// DefaultQueryTimeout bounds dataset read queries when no timeout is configured
+const DefaultQueryTimeout = 60 * time.Second
+
+// ErrQueryTimeout is returned when a dataset read query runs longer than the storage's timeout
+var ErrQueryTimeout = errors.New("query timed out")
+
+// ErrInvalidFilter is returned when a DataFilter names an unknown column or operator
+var ErrInvalidFilter = errors.New("invalid filter")
+
 // PostgresStorage implements DatasetStorage using PostgreSQL
 type PostgresStorage struct {
-	db *sql.DB
+	db           *sql.DB
+	queryTimeout time.Duration
+}
+
+// NewPostgresStorage creates a new PostgreSQL storage instance. Read queries are
+// cancelled after queryTimeout; zero or less uses DefaultQueryTimeout.
+func NewPostgresStorage(db *sql.DB, queryTimeout time.Duration) *PostgresStorage {
+	if queryTimeout <= 0 {
+		queryTimeout = DefaultQueryTimeout
+	}
+	return &PostgresStorage{db: db, queryTimeout: queryTimeout}
 }
 
-// NewPostgresStorage creates a new PostgreSQL storage instance
-func NewPostgresStorage(db *sql.DB) *PostgresStorage {
-	return &PostgresStorage{db: db}
+// queryContext returns a context that expires after the storage's query timeout
+func (s *PostgresStorage) queryContext() (context.Context, context.CancelFunc) {
+	return context.WithTimeout(context.Background(), s.queryTimeout)
+}
+
+// wrapQueryError reports a cancelled query as ErrQueryTimeout so callers can tell it
+// apart from other failures
+func wrapQueryError(ctx context.Context, action string, err error) error {
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return fmt.Errorf("%s: %w", action, ErrQueryTimeout)
+	}
+	return fmt.Errorf("%s: %w", action, err)
 }
 
 // ToTableName converts a dataset name to a valid PostgreSQL table name.
@@ -56,9 +89,27 @@ func ToTableName(datasetName string) string {
 		result = "ds_" + result
 	}
 
+	// PostgreSQL silently truncates identifiers to 63 bytes, which would make long
+	// names collide; leave room for a uniqueness suffix
+	if len(result) > maxTableNameLength {
+		result = strings.TrimRight(truncateUTF8(result, maxTableNameLength), "_")
+	}
+
 	return result
 }
 
+// maxTableNameLength keeps generated table names under PostgreSQL's 63 byte
+// identifier limit with room for a "_<n>" suffix
+const maxTableNameLength = 54
+
+// truncateUTF8 cuts s to at most n bytes without splitting a character
+func truncateUTF8(s string, n int) string {
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return s[:n]
+}
+
 // fullyQualifiedTableName returns the schema-qualified table name
 func fullyQualifiedTableName(tableName string) string {
 	return fmt.Sprintf("dataset_data.%s", tableName)
@@ -255,7 +306,7 @@ func (s *PostgresStorage) AppendData(datasetID int, tableName string, rows [][]a
 }
 
 // GetData retrieves paginated data for a dataset
-func (s *PostgresStorage) GetData(datasetID int, tableName string, page, pageSize int, sortColumn, sortDirection string) (*DataPage, error) {
+func (s *PostgresStorage) GetData(datasetID int, tableName string, page, pageSize int, sortColumn, sortDirection string, filter DataFilter) (*DataPage, error) {
 	fqTableName := fullyQualifiedTableName(tableName)
 
 	// Get columns
@@ -264,12 +315,27 @@ func (s *PostgresStorage) GetData(datasetID int, tableName string, page, pageSiz
 		return nil, err
 	}
 
-	// Get total count
-	total, err := s.GetRowCount(tableName)
+	whereClause, args, err := buildFilterClause(columns, filter)
 	if err != nil {
 		return nil, err
 	}
 
+	// Get total count of the filtered rows
+	var total int
+	if whereClause == "" {
+		total, err = s.GetRowCount(tableName)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		countCtx, countCancel := s.queryContext()
+		defer countCancel()
+		countQuery := fmt.Sprintf("SELECT COUNT(*) FROM %s %s", fqTableName, whereClause)
+		if err := s.db.QueryRowContext(countCtx, countQuery, args...).Scan(&total); err != nil {
+			return nil, wrapQueryError(countCtx, "failed to count rows", err)
+		}
+	}
+
 	// Build column select list
 	var selectCols []string
 	for _, col := range columns {
@@ -297,14 +363,19 @@ func (s *PostgresStorage) GetData(datasetID int, tableName string, page, pageSiz
 	}
 
 	offset := (page - 1) * pageSize
-	query := fmt.Sprintf("SELECT %s FROM %s ORDER BY %s LIMIT $1 OFFSET $2",
+	query := fmt.Sprintf("SELECT %s FROM %s %s ORDER BY %s LIMIT $%d OFFSET $%d",
 		strings.Join(selectCols, ", "),
 		fqTableName,
-		orderClause)
+		whereClause,
+		orderClause,
+		len(args)+1, len(args)+2)
 
-	dbRows, err := s.db.Query(query, pageSize, offset)
+	ctx, cancel := s.queryContext()
+	defer cancel()
+
+	dbRows, err := s.db.QueryContext(ctx, query, append(args, pageSize, offset)...)
 	if err != nil {
-		return nil, fmt.Errorf("failed to query data: %w", err)
+		return nil, wrapQueryError(ctx, "failed to query data", err)
 	}
 	defer dbRows.Close()
 
@@ -321,19 +392,15 @@ func (s *PostgresStorage) GetData(datasetID int, tableName string, page, pageSiz
 			return nil, fmt.Errorf("failed to scan row: %w", err)
 		}
 
-		// Convert sql.NullString or other types to plain values
 		row := make([]any, len(columns))
 		for i, v := range values {
-			if v == nil {
-				row[i] = nil
-			} else if b, ok := v.([]byte); ok {
-				row[i] = string(b)
-			} else {
-				row[i] = v
-			}
+			row[i] = normalizeValue(v)
 		}
 		rows = append(rows, row)
 	}
+	if err := dbRows.Err(); err != nil {
+		return nil, wrapQueryError(ctx, "failed to read data", err)
+	}
 
 	return &DataPage{
 		Columns:  columns,
@@ -344,20 +411,99 @@ func (s *PostgresStorage) GetData(datasetID int, tableName string, page, pageSiz
 	}, nil
 }
 
-// GetAllData retrieves all data for a dataset (for export)
-func (s *PostgresStorage) GetAllData(datasetID int, tableName string) (*DataPage, error) {
+// numericPattern matches the TEXT values treated as numbers by filters and type inference
+const numericPattern = `^\s*-?[0-9]+(\.[0-9]+)?\s*$`
+
+// datePattern matches the TEXT values treated as dates by type inference
+const datePattern = `^\s*[0-9]{4}-[0-9]{2}-[0-9]{2}`
+
+// buildFilterClause turns a DataFilter into a WHERE clause with positional
+// parameters starting at $1. Returns an empty clause for an empty filter.
+func buildFilterClause(columns []string, filter DataFilter) (string, []any, error) {
+	if filter.IsEmpty() {
+		return "", nil, nil
+	}
+
+	var conditions []string
+	var args []any
+	param := func(v any) string {
+		args = append(args, v)
+		return fmt.Sprintf("$%d", len(args))
+	}
+
+	if filter.Column != "" || filter.Op != "" {
+		if filter.Column == "" {
+			return "", nil, fmt.Errorf("%w: filter_op requires filter_column", ErrInvalidFilter)
+		}
+		known := false
+		for _, col := range columns {
+			if col == filter.Column {
+				known = true
+				break
+			}
+		}
+		if !known {
+			return "", nil, fmt.Errorf("%w: unknown column %q", ErrInvalidFilter, filter.Column)
+		}
+
+		col := sanitizeColumnName(filter.Column)
+		op := filter.Op
+		if op == "" {
+			op = FilterEq
+		}
+		switch op {
+		case FilterEq:
+			conditions = append(conditions, fmt.Sprintf("%s = %s", col, param(filter.Value)))
+		case FilterNeq:
+			conditions = append(conditions, fmt.Sprintf("%s IS DISTINCT FROM %s", col, param(filter.Value)))
+		case FilterContains:
+			conditions = append(conditions, fmt.Sprintf("%s ILIKE %s", col, param("%"+escapeLike(filter.Value)+"%")))
+		case FilterGt, FilterGte, FilterLt, FilterLte:
+			comparison := map[string]string{FilterGt: ">", FilterGte: ">=", FilterLt: "<", FilterLte: "<="}[op]
+			// Columns are TEXT: compare numerically when the value is a number,
+			// skipping cells that aren't numeric, otherwise compare as text
+			if number, err := strconv.ParseFloat(strings.TrimSpace(filter.Value), 64); err == nil {
+				conditions = append(conditions, fmt.Sprintf("(CASE WHEN %[1]s ~ '%[2]s' THEN CAST(%[1]s AS NUMERIC) END) %[3]s %[4]s",
+					col, numericPattern, comparison, param(number)))
+			} else {
+				conditions = append(conditions, fmt.Sprintf("%s %s %s", col, comparison, param(filter.Value)))
+			}
+		default:
+			return "", nil, fmt.Errorf("%w: unknown operator %q", ErrInvalidFilter, op)
+		}
+	}
+
+	if filter.Query != "" && len(columns) > 0 {
+		pattern := param("%" + escapeLike(filter.Query) + "%")
+		var matches []string
+		for _, col := range columns {
+			matches = append(matches, fmt.Sprintf("%s ILIKE %s", sanitizeColumnName(col), pattern))
+		}
+		conditions = append(conditions, "("+strings.Join(matches, " OR ")+")")
+	}
+
+	if len(conditions) == 0 {
+		return "", nil, nil
+	}
+	return "WHERE " + strings.Join(conditions, " AND "), args, nil
+}
+
+// escapeLike escapes LIKE wildcards so the value matches literally
+func escapeLike(value string) string {
+	return strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(value)
+}
+
+// StreamAllData reads every row of a dataset in row order, handing each row to onRow
+// as it is scanned so exports don't hold the whole table in memory. It is bound by
+// ctx (normally the request's) instead of the query timeout, which would cut large
+// exports off part way through the body.
+func (s *PostgresStorage) StreamAllData(ctx context.Context, datasetID int, tableName string, onColumns func(columns []string) error, onRow func(row []any) error) error {
 	fqTableName := fullyQualifiedTableName(tableName)
 
 	// Get columns
 	columns, err := s.GetColumns(datasetID, tableName)
 	if err != nil {
-		return nil, err
-	}
-
-	// Get total count
-	total, err := s.GetRowCount(tableName)
-	if err != nil {
-		return nil, err
+		return err
 	}
 
 	// Build column select list
@@ -370,44 +516,50 @@ func (s *PostgresStorage) GetAllData(datasetID int, tableName string) (*DataPage
 		strings.Join(selectCols, ", "),
 		fqTableName)
 
-	dbRows, err := s.db.Query(query)
+	dbRows, err := s.db.QueryContext(ctx, query)
 	if err != nil {
-		return nil, fmt.Errorf("failed to query data: %w", err)
+		return wrapQueryError(ctx, "failed to query data", err)
 	}
 	defer dbRows.Close()
 
-	var rows [][]any
-	for dbRows.Next() {
-		values := make([]any, len(columns))
-		valuePtrs := make([]any, len(columns))
-		for i := range values {
-			valuePtrs[i] = &values[i]
-		}
+	if err := onColumns(columns); err != nil {
+		return err
+	}
 
+	values := make([]any, len(columns))
+	valuePtrs := make([]any, len(columns))
+	for i := range values {
+		valuePtrs[i] = &values[i]
+	}
+	row := make([]any, len(columns))
+	for dbRows.Next() {
 		if err := dbRows.Scan(valuePtrs...); err != nil {
-			return nil, fmt.Errorf("failed to scan row: %w", err)
+			return fmt.Errorf("failed to scan row: %w", err)
 		}
 
-		row := make([]any, len(columns))
+		// row is reused between calls; onRow must not retain it
 		for i, v := range values {
-			if v == nil {
-				row[i] = nil
-			} else if b, ok := v.([]byte); ok {
-				row[i] = string(b)
-			} else {
-				row[i] = v
-			}
+			row[i] = normalizeValue(v)
+		}
+		if err := onRow(row); err != nil {
+			return err
 		}
-		rows = append(rows, row)
+	}
+	if err := dbRows.Err(); err != nil {
+		return wrapQueryError(ctx, "failed to read data", err)
 	}
 
-	return &DataPage{
-		Columns:  columns,
-		Rows:     rows,
-		Total:    total,
-		Page:     1,
-		PageSize: total,
-	}, nil
+	return nil
+}
+
+// normalizeValue converts a scanned column value to a plain value.
+// NULL stays nil (JSON null) and []byte becomes a string, so both data paths
+// represent missing values the same way.
+func normalizeValue(v any) any {
+	if b, ok := v.([]byte); ok {
+		return string(b)
+	}
+	return v
 }
 
 // GetRowCount returns the total number of rows for a dataset
@@ -423,10 +575,13 @@ func (s *PostgresStorage) GetRowCount(tableName string) (int, error) {
 		return 0, nil
 	}
 
+	ctx, cancel := s.queryContext()
+	defer cancel()
+
 	var count int
-	err = s.db.QueryRow(fmt.Sprintf("SELECT COUNT(*) FROM %s", fqTableName)).Scan(&count)
+	err = s.db.QueryRowContext(ctx, fmt.Sprintf("SELECT COUNT(*) FROM %s", fqTableName)).Scan(&count)
 	if err != nil {
-		return 0, fmt.Errorf("failed to count rows: %w", err)
+		return 0, wrapQueryError(ctx, "failed to count rows", err)
 	}
 	return count, nil
 }
@@ -459,3 +614,139 @@ func (s *PostgresStorage) GetColumns(datasetID int, tableName string) ([]string,
 	}
 	return columns, nil
 }
+
+// GetDateRange returns the earliest and latest YYYY-MM-DD dates in a column. Values
+// with an out-of-range month or day are skipped. ISO dates sort chronologically as
+// text, so no cast is needed.
+func (s *PostgresStorage) GetDateRange(tableName, column string) (string, string, error) {
+	ctx, cancel := s.queryContext()
+	defer cancel()
+
+	query := fmt.Sprintf(`
+		SELECT COALESCE(MIN(d), ''), COALESCE(MAX(d), '')
+		FROM (
+			SELECT substring(%[1]s from '[0-9]{4}-[0-9]{2}-[0-9]{2}') AS d
+			FROM %[2]s
+			WHERE %[1]s ~ '^\s*[0-9]{4}-(0[1-9]|1[0-2])-(0[1-9]|[12][0-9]|3[01])'
+		) dates
+	`, sanitizeColumnName(column), fullyQualifiedTableName(tableName))
+
+	var minDate, maxDate string
+	if err := s.db.QueryRowContext(ctx, query).Scan(&minDate, &maxDate); err != nil {
+		return "", "", wrapQueryError(ctx, "failed to get date range", err)
+	}
+	return minDate, maxDate, nil
+}
+
+// GetColumnStats summarizes each column with aggregate queries. Numeric columns are
+// cast per value, so a stray non-numeric value is ignored rather than failing.
+func (s *PostgresStorage) GetColumnStats(tableName string, columns []string, types map[string]string, topN int) ([]ColumnStats, error) {
+	fqTableName := fullyQualifiedTableName(tableName)
+
+	// One deadline covers every column, as in InferColumnTypes
+	ctx, cancel := s.queryContext()
+	defer cancel()
+
+	stats := make([]ColumnStats, 0, len(columns))
+	for _, col := range columns {
+		quoted := sanitizeColumnName(col)
+		cs := ColumnStats{Column: col, Type: types[col]}
+
+		if cs.Type == "numeric" {
+			query := fmt.Sprintf(`
+				SELECT COUNT(*) FILTER (WHERE v IS NULL), MIN(v), MAX(v), AVG(v), SUM(v)
+				FROM (
+					SELECT CASE WHEN %[1]s ~ '%[3]s' THEN trim(%[1]s)::numeric END AS v
+					FROM %[2]s
+				) vals
+			`, quoted, fqTableName, numericPattern)
+
+			var min, max, avg, sum sql.NullFloat64
+			if err := s.db.QueryRowContext(ctx, query).Scan(&cs.NullCount, &min, &max, &avg, &sum); err != nil {
+				return nil, wrapQueryError(ctx, "failed to get stats for column "+col, err)
+			}
+			if min.Valid {
+				cs.Min, cs.Max, cs.Avg, cs.Sum = &min.Float64, &max.Float64, &avg.Float64, &sum.Float64
+			}
+			stats = append(stats, cs)
+			continue
+		}
+
+		query := fmt.Sprintf(`
+			SELECT COUNT(*) FILTER (WHERE %[1]s IS NULL OR %[1]s = ''), COUNT(DISTINCT NULLIF(%[1]s, ''))
+			FROM %[2]s
+		`, quoted, fqTableName)
+		if err := s.db.QueryRowContext(ctx, query).Scan(&cs.NullCount, &cs.DistinctCount); err != nil {
+			return nil, wrapQueryError(ctx, "failed to get stats for column "+col, err)
+		}
+
+		query = fmt.Sprintf(`
+			SELECT %[1]s, COUNT(*)
+			FROM %[2]s
+			WHERE %[1]s IS NOT NULL AND %[1]s != ''
+			GROUP BY %[1]s
+			ORDER BY COUNT(*) DESC, %[1]s ASC
+			LIMIT $1
+		`, quoted, fqTableName)
+		rows, err := s.db.QueryContext(ctx, query, topN)
+		if err != nil {
+			return nil, wrapQueryError(ctx, "failed to get top values for column "+col, err)
+		}
+		cs.TopValues = []ValueCount{}
+		for rows.Next() {
+			var vc ValueCount
+			if err := rows.Scan(&vc.Value, &vc.Count); err != nil {
+				rows.Close()
+				return nil, fmt.Errorf("failed to scan top value: %w", err)
+			}
+			cs.TopValues = append(cs.TopValues, vc)
+		}
+		err = rows.Err()
+		rows.Close()
+		if err != nil {
+			return nil, wrapQueryError(ctx, "failed to read top values for column "+col, err)
+		}
+		stats = append(stats, cs)
+	}
+	return stats, nil
+}
+
+// InferColumnTypes classifies each column from its non-empty values. Columns are stored
+// as TEXT, so a column is "numeric" or "date" only if every non-empty value matches;
+// empty columns are "text".
+func (s *PostgresStorage) InferColumnTypes(tableName string, columns []string) (map[string]string, error) {
+	fqTableName := fullyQualifiedTableName(tableName)
+
+	// One deadline covers the whole inference, not each column
+	ctx, cancel := s.queryContext()
+	defer cancel()
+
+	types := make(map[string]string)
+	for _, col := range columns {
+		quoted := sanitizeColumnName(col)
+		query := fmt.Sprintf(`
+			SELECT
+				COUNT(*),
+				COALESCE(bool_and(%[1]s ~ '%[3]s'), false),
+				COALESCE(bool_and(%[1]s ~ '%[4]s'), false)
+			FROM %[2]s
+			WHERE %[1]s IS NOT NULL AND %[1]s != ''
+		`, quoted, fqTableName, numericPattern, datePattern)
+
+		var count int
+		var isNumeric, isDate bool
+		if err := s.db.QueryRowContext(ctx, query).Scan(&count, &isNumeric, &isDate); err != nil {
+			return nil, wrapQueryError(ctx, "failed to infer type for column "+col, err)
+		}
+
+		switch {
+		case count > 0 && isNumeric:
+			types[col] = "numeric"
+		case count > 0 && isDate:
+			types[col] = "date"
+		default:
+			types[col] = "text"
+		}
+	}
+	return types, nil
+}
@@ -0,0 +1,29 @@
+package storage
+
+import (
+	"reflect"
+	"testing"
+)
+
+// GetData and StreamAllData both pass scanned values through normalizeValue, so
+// NULL must stay nil and text must come back as a string on either path
+func TestNormalizeValue(t *testing.T) {
+	tests := []struct {
+		name string
+		in   any
+		want any
+	}{
+		{"null", nil, nil},
+		{"bytes", []byte("12.50"), "12.50"},
+		{"empty bytes", []byte{}, ""},
+		{"string", "abc", "abc"},
+		{"integer", int64(3), int64(3)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := normalizeValue(tt.in); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("normalizeValue(%#v) = %#v, want %#v", tt.in, got, tt.want)
+			}
+		})
+	}
+}
@@ -1,5 +1,7 @@
 package storage
 
+import "context"
+
 // DatasetStorage defines the interface for storing and retrieving dataset data.
 // Different implementations can use different storage backends (PostgreSQL, files, etc.)
 // The tableName parameter is the sanitized table name derived from the dataset name.
@@ -11,11 +13,14 @@ type DatasetStorage interface {
 	// AppendData appends rows to an existing dataset
 	AppendData(datasetID int, tableName string, rows [][]any) error
 
-	// GetData retrieves paginated data for a dataset
-	GetData(datasetID int, tableName string, page, pageSize int, sortColumn, sortDirection string) (*DataPage, error)
+	// GetData retrieves paginated data for a dataset, restricted to rows matching filter
+	GetData(datasetID int, tableName string, page, pageSize int, sortColumn, sortDirection string, filter DataFilter) (*DataPage, error)
 
-	// GetAllData retrieves all data for a dataset (for export)
-	GetAllData(datasetID int, tableName string) (*DataPage, error)
+	// StreamAllData reads every row of a dataset in order (for export). onColumns is
+	// called once before any rows; onRow is called per row and may stop the stream
+	// by returning an error. Rows are not buffered. The stream runs until ctx is
+	// done rather than under the read-query timeout, so large exports can finish.
+	StreamAllData(ctx context.Context, datasetID int, tableName string, onColumns func(columns []string) error, onRow func(row []any) error) error
 
 	// GetRowCount returns the total number of rows for a dataset
 	GetRowCount(tableName string) (int, error)
@@ -26,6 +31,18 @@ type DatasetStorage interface {
 	// GetColumns returns the column names for a dataset by querying the table schema
 	GetColumns(datasetID int, tableName string) ([]string, error)
 
+	// InferColumnTypes classifies each column as "numeric", "date", or "text" from its values
+	InferColumnTypes(tableName string, columns []string) (map[string]string, error)
+
+	// GetDateRange returns the earliest and latest dates (YYYY-MM-DD) in a column;
+	// both are empty if the column has no date values
+	GetDateRange(tableName, column string) (min, max string, err error)
+
+	// GetColumnStats summarizes each column: numeric columns get min/max/avg/sum,
+	// others a distinct count and their topN most frequent values. types maps each
+	// column to its inferred type.
+	GetColumnStats(tableName string, columns []string, types map[string]string, topN int) ([]ColumnStats, error)
+
 	// CreateDatasetTable creates a new table for a dataset with the given columns
 	CreateDatasetTable(tableName string, columns []string) error
 
@@ -36,6 +53,51 @@ type DatasetStorage interface {
 	TableExists(tableName string) (bool, error)
 }
 
+// Filter operators supported by DataFilter
+const (
+	FilterEq       = "eq"
+	FilterNeq      = "neq"
+	FilterContains = "contains"
+	FilterGt       = "gt"
+	FilterGte      = "gte"
+	FilterLt       = "lt"
+	FilterLte      = "lte"
+)
+
+// DataFilter restricts the rows returned by GetData. The zero value matches every row.
+type DataFilter struct {
+	Column string // Column compared against Value with Op; empty disables the column filter
+	Op     string // One of the Filter* operators; defaults to FilterEq
+	Value  string
+	Query  string // Case-insensitive text searched for in every column
+}
+
+// IsEmpty reports whether the filter matches every row
+func (f DataFilter) IsEmpty() bool {
+	return f.Column == "" && f.Op == "" && f.Query == ""
+}
+
+// ColumnStats summarizes the values of one column. Empty and NULL values count as
+// nulls and are excluded from every other figure.
+type ColumnStats struct {
+	Column    string
+	Type      string
+	NullCount int
+
+	// Numeric columns only; nil when the column has no values
+	Min, Max, Avg, Sum *float64
+
+	// Non-numeric columns only
+	DistinctCount int
+	TopValues     []ValueCount
+}
+
+// ValueCount is a value and the number of rows holding it
+type ValueCount struct {
+	Value string
+	Count int
+}
+
 // DataPage represents a page of dataset data
 type DataPage struct {
 	Columns  []string
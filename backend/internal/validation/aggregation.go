@@ -0,0 +1,31 @@
+package validation
+
+// Column types inferred for dataset columns
+const (
+	ColumnTypeNumeric = "numeric"
+	ColumnTypeDate    = "date"
+	ColumnTypeText    = "text"
+)
+
+// Aggregation operators
+const (
+	AggCount         = "count"
+	AggCountDistinct = "count_distinct"
+	AggSum           = "sum"
+	AggAvg           = "avg"
+	AggMin           = "min"
+	AggMax           = "max"
+)
+
+// AggregationOperators returns the aggregation operators that are valid for a column type.
+// COUNT works on anything; SUM/AVG need numbers; MIN/MAX need an ordering.
+func AggregationOperators(columnType string) []string {
+	switch columnType {
+	case ColumnTypeNumeric:
+		return []string{AggCount, AggCountDistinct, AggSum, AggAvg, AggMin, AggMax}
+	case ColumnTypeDate:
+		return []string{AggCount, AggCountDistinct, AggMin, AggMax}
+	default:
+		return []string{AggCount, AggCountDistinct}
+	}
+}
@@ -58,6 +58,125 @@ func ValidateFormulaForCycles(accountID int, formula []models.FormulaItem, allAc
 	return nil
 }
 
+// ValidateBulkFormulasForCycles checks a set of formula changes together: every change is
+// applied to allAccounts first, so cycles that only appear through several of the new
+// formulas are also found. Returns the cycle error for each account that is part of one.
+func ValidateBulkFormulasForCycles(updates []models.BulkFormulaUpdate, allAccounts []models.Account) map[int]error {
+	byID := make(map[int]models.BulkFormulaUpdate)
+	for _, u := range updates {
+		byID[u.AccountID] = u
+	}
+
+	combined := make([]models.Account, len(allAccounts))
+	copy(combined, allAccounts)
+	for i := range combined {
+		if u, ok := byID[combined[i].ID]; ok {
+			combined[i].IsCalculated = u.IsCalculated
+			combined[i].Formula = u.Formula
+		}
+	}
+
+	errs := make(map[int]error)
+	for _, u := range updates {
+		if !u.IsCalculated || len(u.Formula) == 0 {
+			continue
+		}
+		if err := ValidateFormulaForCycles(u.AccountID, u.Formula, combined); err != nil {
+			errs[u.AccountID] = err
+		}
+	}
+	return errs
+}
+
+// ValidateFormulaOperations checks that every formula item uses a supported operation.
+// An empty operation is treated as "add".
+func ValidateFormulaOperations(formula []models.FormulaItem) error {
+	for _, item := range formula {
+		switch item.Operation {
+		case "", models.FormulaOpAdd, models.FormulaOpSubtract, models.FormulaOpMultiply, models.FormulaOpDivide:
+		default:
+			return fmt.Errorf("invalid formula operation %q: must be 'add', 'subtract', 'multiply', or 'divide'", item.Operation)
+		}
+	}
+	return nil
+}
+
+// ValidateDashboardFormulaForCycles checks that a dashboard formula does not reference
+// dashboards that (directly or transitively) reference dashboardID back
+func ValidateDashboardFormulaForCycles(dashboardID int, formula []models.DashboardFormulaItem, allDashboards []models.Dashboard) error {
+	// Build dependency graph: dashboardID -> list of dashboard IDs its formula references
+	graph := make(map[int][]int)
+	dashboardNames := make(map[int]string)
+
+	for _, dashboard := range allDashboards {
+		dashboardNames[dashboard.ID] = dashboard.Name
+		if dashboard.IsCalculated {
+			for _, item := range dashboard.Formula {
+				if item.Type == "dashboard" {
+					graph[dashboard.ID] = append(graph[dashboard.ID], item.ID)
+				}
+			}
+		}
+	}
+
+	for _, item := range formula {
+		if item.Type != "dashboard" {
+			continue
+		}
+		if item.ID == dashboardID && dashboardID != 0 {
+			return fmt.Errorf("circular dependency: dashboard cannot reference itself")
+		}
+		// New dashboards can't be referenced by anything yet
+		if dashboardID == 0 {
+			continue
+		}
+
+		visited := make(map[int]bool)
+		path := []int{item.ID}
+		if canReach(item.ID, dashboardID, graph, visited, &path) {
+			cyclePath := dashboardNames[dashboardID] + " -> " + dashboardNames[item.ID]
+			for i := 0; i < len(path)-1; i++ {
+				cyclePath += " -> " + dashboardNames[path[i]]
+			}
+			cyclePath += " -> " + dashboardNames[dashboardID]
+			return fmt.Errorf("circular dependency detected: %s", cyclePath)
+		}
+	}
+
+	return nil
+}
+
+// ValidateGroupParentForCycles checks that making parentID the parent of groupID
+// would not create a cycle in the group hierarchy
+func ValidateGroupParentForCycles(groupID, parentID int, allGroups []models.AccountGroup) error {
+	if groupID == parentID {
+		return fmt.Errorf("circular dependency: group cannot be its own parent")
+	}
+
+	parents := make(map[int]*int)
+	groupNames := make(map[int]string)
+	for _, group := range allGroups {
+		parents[group.ID] = group.ParentGroupID
+		groupNames[group.ID] = group.Name
+	}
+
+	// Walk up from the proposed parent; reaching groupID means a cycle
+	cyclePath := groupNames[groupID] + " -> " + groupNames[parentID]
+	visited := map[int]bool{parentID: true}
+	for current := parents[parentID]; current != nil; current = parents[*current] {
+		cyclePath += " -> " + groupNames[*current]
+		if *current == groupID {
+			return fmt.Errorf("circular dependency detected: %s", cyclePath)
+		}
+		if visited[*current] {
+			break
+		}
+		visited[*current] = true
+	}
+
+	return nil
+}
+
 // canReach uses DFS to determine if 'from' can reach 'target' through the dependency graph
 func canReach(from, target int, graph map[int][]int, visited map[int]bool, path *[]int) bool {
 	if from == target {
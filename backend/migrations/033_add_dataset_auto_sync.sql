@@ -0,0 +1,7 @@
+-- Migration: Add per-dataset auto-sync settings
+-- When auto_sync is enabled, reads trigger a background sync if the source folder changed.
+-- auto_sync_interval_seconds throttles how often reads check the folder for changes.
+
+ALTER TABLE datasets ADD COLUMN IF NOT EXISTS auto_sync BOOLEAN NOT NULL DEFAULT TRUE;
+ALTER TABLE datasets ADD COLUMN IF NOT EXISTS auto_sync_interval_seconds INTEGER NOT NULL DEFAULT 60
+    CHECK (auto_sync_interval_seconds >= 0);
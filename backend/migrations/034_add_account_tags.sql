@@ -0,0 +1,17 @@
+-- Migration: Add tags for cross-cutting account labels (e.g. "retirement")
+-- Tag names are stored normalized (trimmed, lowercased) so they stay unique.
+
+CREATE TABLE IF NOT EXISTS account_tags (
+    id SERIAL PRIMARY KEY,
+    name VARCHAR(100) NOT NULL UNIQUE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
+
+CREATE TABLE IF NOT EXISTS account_tag_memberships (
+    account_id INTEGER NOT NULL REFERENCES account_balances(id) ON DELETE CASCADE,
+    tag_id INTEGER NOT NULL REFERENCES account_tags(id) ON DELETE CASCADE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    PRIMARY KEY (account_id, tag_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_account_tag_memberships_tag ON account_tag_memberships(tag_id);
@@ -0,0 +1,8 @@
+-- Migration: Support nested groups
+-- A group may have a parent group; the parent's total includes its child groups' totals.
+-- Deleting a parent makes its children top-level again.
+
+ALTER TABLE account_groups ADD COLUMN IF NOT EXISTS parent_group_id INTEGER
+    REFERENCES account_groups(id) ON DELETE SET NULL;
+
+CREATE INDEX IF NOT EXISTS idx_account_groups_parent ON account_groups(parent_group_id);
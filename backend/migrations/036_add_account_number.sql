@@ -0,0 +1,4 @@
+-- Migration: Add a structured account number that is only returned masked
+-- account_info stays free text; the full number is only exposed when REVEAL_ACCOUNT_NUMBERS is set.
+
+ALTER TABLE account_balances ADD COLUMN IF NOT EXISTS account_number VARCHAR(64);
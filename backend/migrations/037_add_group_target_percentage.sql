@@ -0,0 +1,4 @@
+-- Migration: Target allocation percentage per group, used for rebalancing drift reports
+-- NULL means the group has no target.
+
+ALTER TABLE account_groups ADD COLUMN IF NOT EXISTS target_percentage NUMERIC(5,2);
@@ -0,0 +1,10 @@
+-- Migration: Human-readable descriptions of dataset column meanings
+-- Columns are derived from the dataset table, so descriptions are keyed by name.
+
+CREATE TABLE IF NOT EXISTS dataset_column_descriptions (
+    dataset_id INTEGER NOT NULL REFERENCES datasets(id) ON DELETE CASCADE,
+    column_name VARCHAR(255) NOT NULL,
+    description TEXT NOT NULL,
+    updated_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (dataset_id, column_name)
+);
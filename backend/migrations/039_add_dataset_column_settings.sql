@@ -0,0 +1,11 @@
+-- Migration: Per-column display labels and ordering for datasets
+-- Physical column names never change, so anything referencing them keeps working.
+-- Columns without a row keep their physical name and follow the positioned columns.
+
+CREATE TABLE IF NOT EXISTS dataset_column_settings (
+    dataset_id INTEGER NOT NULL REFERENCES datasets(id) ON DELETE CASCADE,
+    column_name VARCHAR(255) NOT NULL,
+    display_name VARCHAR(255),
+    position INTEGER NOT NULL,
+    PRIMARY KEY (dataset_id, column_name)
+);
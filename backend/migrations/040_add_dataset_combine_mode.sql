@@ -0,0 +1,7 @@
+-- Migration: How a dataset combines the CSV files in its folder
+-- 'append' stacks rows from files with identical columns; 'join' merges rows
+-- that share a join_key value, producing the union of the files' columns.
+
+ALTER TABLE datasets ADD COLUMN IF NOT EXISTS combine_mode VARCHAR(10) NOT NULL DEFAULT 'append'
+    CHECK (combine_mode IN ('append', 'join'));
+ALTER TABLE datasets ADD COLUMN IF NOT EXISTS join_key VARCHAR(255);
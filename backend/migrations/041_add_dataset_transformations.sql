@@ -0,0 +1,13 @@
+-- Migration: Ordered transformation pipeline replayed on a dataset's folder data at every sync
+-- config holds the step's parameters (columns, operator, value) as JSON.
+
+CREATE TABLE IF NOT EXISTS dataset_transformations (
+    id SERIAL PRIMARY KEY,
+    dataset_id INTEGER NOT NULL REFERENCES datasets(id) ON DELETE CASCADE,
+    position INTEGER NOT NULL,
+    operation VARCHAR(20) NOT NULL CHECK (operation IN ('rename', 'compute', 'filter', 'dedup')),
+    config JSONB NOT NULL DEFAULT '{}',
+    created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_dataset_transformations_dataset ON dataset_transformations(dataset_id, position);
@@ -0,0 +1,7 @@
+-- Migration: Drop duplicate rows when syncing a dataset
+-- dedupe_keys is a JSON array of column names compared to detect duplicates; empty compares every column.
+-- duplicates_dropped records how many rows the last sync dropped.
+
+ALTER TABLE datasets ADD COLUMN IF NOT EXISTS dedupe BOOLEAN NOT NULL DEFAULT FALSE;
+ALTER TABLE datasets ADD COLUMN IF NOT EXISTS dedupe_keys JSONB NOT NULL DEFAULT '[]';
+ALTER TABLE datasets ADD COLUMN IF NOT EXISTS duplicates_dropped INTEGER NOT NULL DEFAULT 0;
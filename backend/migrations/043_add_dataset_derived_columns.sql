@@ -0,0 +1,4 @@
+-- Migration: Derived columns computed from arithmetic expressions when a dataset syncs
+-- derived_columns is a JSON array of {"name", "expression"} objects, applied in order after the transformation pipeline.
+
+ALTER TABLE datasets ADD COLUMN IF NOT EXISTS derived_columns JSONB NOT NULL DEFAULT '[]';
@@ -0,0 +1,6 @@
+-- Migration: Record when a dataset sync last failed
+-- updated_at also moves on edits to a failed dataset, so the failures view needs its own timestamp.
+
+ALTER TABLE datasets ADD COLUMN IF NOT EXISTS last_error_at TIMESTAMP WITH TIME ZONE;
+
+UPDATE datasets SET last_error_at = updated_at WHERE status = 'error' AND last_error_at IS NULL;
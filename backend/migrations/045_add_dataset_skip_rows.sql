@@ -0,0 +1,4 @@
+-- Migration: Per-dataset override of header detection
+-- skip_rows is how many records precede the header in each CSV file; NULL detects title lines automatically.
+
+ALTER TABLE datasets ADD COLUMN IF NOT EXISTS skip_rows INTEGER;
@@ -0,0 +1,4 @@
+-- Migration: Per-dataset field delimiter
+-- delimiter overrides delimiter detection for every CSV file in the folder; NULL detects it (.tsv files are tab-delimited).
+
+ALTER TABLE datasets ADD COLUMN IF NOT EXISTS delimiter TEXT;